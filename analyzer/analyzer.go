@@ -0,0 +1,390 @@
+// Package analyzer provides a go/analysis pass that checks the placeholders
+// in structures passed to goenvsubst.Do, in templates passed to
+// goenvsubst.Expand and in struct tags against a declared list of
+// variables, and the tags of the fields that hold them.
+//
+// It can be run standalone or through go vet:
+//
+//	(cd analyzer && go install ./cmd/goenvsubstvet)
+//	go vet -vettool=$(which goenvsubstvet) -vars=DATABASE_URL,API_KEY ./...
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/internal/astscan"
+)
+
+// Analyzer reports placeholders whose variable is not declared with -vars
+// or -varsfile. It finds them where the scan command of goenvsubst does: in
+// string literals that are passed to goenvsubst.Do, DoPtr, DoCopy,
+// DoWithMap, DoWithReport, Check or the Do method of an Expander, either
+// directly or through a local variable initialized with a composite
+// literal, in string literals passed to Expand, ExpandWith or the Expand
+// method of an Expander, and in env, envsubst and envwhen struct tags.
+// Unless -tags=false is given, it also reports struct fields
+// that reference a variable without a default but are not tagged
+// envsubst:"required", and fields named like secrets, such as Password or
+// APIToken, that reference a variable but are not tagged envsubst:"secret".
+var Analyzer = &analysis.Analyzer{
+	Name:     "goenvsubst",
+	Doc:      "check placeholders in structures passed to goenvsubst.Do against declared variables",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var (
+	varsFlag     string
+	varsFileFlag string
+	tagsFlag     bool
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&varsFlag, "vars", "", "comma-separated list of declared variable names")
+	Analyzer.Flags.StringVar(&varsFileFlag, "varsfile", "", "file with one declared variable per line (NAME or NAME=value, # comments)")
+	Analyzer.Flags.BoolVar(&tagsFlag, "tags", true, "report fields that are missing required or secret tags")
+}
+
+// secretWords are the parts of field names that suggest the field holds a
+// secret
+var secretWords = []string{"password", "passwd", "secret", "token", "apikey", "privatekey", "credential"}
+
+func run(pass *analysis.Pass) (any, error) {
+	declared, err := declaredVars()
+	if err != nil {
+		return nil, err
+	}
+
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// Remember composite literals assigned to local variables, so that
+	// goenvsubst.Do(&cfg) can be traced back to cfg := Config{...}.
+	inits := map[types.Object][]*ast.CompositeLit{}
+	insp.Preorder([]ast.Node{(*ast.AssignStmt)(nil), (*ast.ValueSpec)(nil)}, func(n ast.Node) {
+		astscan.Assignments(n, func(ident *ast.Ident, value ast.Expr) {
+			lit, ok := astscan.Unwrap(value).(*ast.CompositeLit)
+			if !ok {
+				return
+			}
+			if obj := pass.TypesInfo.ObjectOf(ident); obj != nil {
+				inits[obj] = append(inits[obj], lit)
+			}
+		})
+	})
+
+	insp.Preorder([]ast.Node{(*ast.StructType)(nil), (*ast.CallExpr)(nil)}, func(n ast.Node) {
+		if st, ok := n.(*ast.StructType); ok {
+			checkTags(pass, st, declared)
+			return
+		}
+		call := n.(*ast.CallExpr)
+		if len(call.Args) == 0 {
+			return
+		}
+		arg := call.Args[0]
+		switch callKind(pass, call) {
+		case stringCall:
+			checkStrings(pass, arg, declared)
+		case structCall:
+			if lit, ok := astscan.Unwrap(arg).(*ast.CompositeLit); ok {
+				checkLit(pass, lit, declared)
+				return
+			}
+			if ident, ok := astscan.Unwrap(arg).(*ast.Ident); ok {
+				for _, lit := range inits[pass.TypesInfo.ObjectOf(ident)] {
+					checkLit(pass, lit, declared)
+				}
+			}
+		}
+	})
+
+	return nil, nil
+}
+
+// The kinds of calls whose first argument is checked
+const (
+	otherCall = iota
+	structCall
+	stringCall
+)
+
+// callKind reports whether call substitutes the structure or the template
+// it is passed first, through a function of the package or a method of a
+// goenvsubst.Expander
+func callKind(pass *analysis.Pass, call *ast.CallExpr) int {
+	var ident *ast.Ident
+	switch fun := astscan.UnwrapGeneric(call.Fun).(type) {
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	case *ast.Ident:
+		ident = fun
+	default:
+		return otherCall
+	}
+	fn, ok := pass.TypesInfo.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != astscan.PkgPath {
+		return otherCall
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	switch {
+	case recv == nil && slices.Contains(astscan.StructFuncs, fn.Name()):
+		return structCall
+	case recv == nil && slices.Contains(astscan.StringFuncs, fn.Name()):
+		return stringCall
+	case recv != nil && isExpander(recv.Type()) && fn.Name() == astscan.StructMethod:
+		return structCall
+	case recv != nil && isExpander(recv.Type()) && fn.Name() == astscan.StringMethod:
+		return stringCall
+	}
+	return otherCall
+}
+
+// isExpander reports whether t is goenvsubst.Expander or a pointer to it
+func isExpander(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Name() == "Expander"
+}
+
+// checkLit reports undeclared placeholders in all string literals inside
+// lit, and with -tags the fields of the struct literals inside it that miss
+// tags
+func checkLit(pass *analysis.Pass, lit *ast.CompositeLit, declared map[string]bool) {
+	if tagsFlag {
+		ast.Inspect(lit, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.CompositeLit); ok {
+				checkFields(pass, lit)
+			}
+			return true
+		})
+	}
+	checkStrings(pass, lit, declared)
+}
+
+// checkStrings reports undeclared placeholders in all string literals
+// inside n
+func checkStrings(pass *analysis.Pass, n ast.Node, declared map[string]bool) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		basic, ok := n.(*ast.BasicLit)
+		if !ok || basic.Kind != token.STRING {
+			return true
+		}
+		s, err := strconv.Unquote(basic.Value)
+		if err != nil {
+			return true
+		}
+		if err := checkTemplate(pass, basic, s, declared); err != nil {
+			pass.Reportf(basic.Pos(), "invalid placeholder: %v", err)
+		}
+		return true
+	})
+}
+
+// checkTags reports undeclared variables referenced by the tags of the
+// fields of st. A tag may be written in another syntax than the one
+// checked, so templates that do not parse are left to the library.
+func checkTags(pass *analysis.Pass, st *ast.StructType, declared map[string]bool) {
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		for _, template := range astscan.TagTemplates(reflect.StructTag(tag)) {
+			_ = checkTemplate(pass, field.Tag, template, declared)
+		}
+	}
+}
+
+// checkTemplate checks every reference in template s, including those inside
+// operator arguments such as ${A:-$B}
+func checkTemplate(pass *analysis.Pass, at ast.Node, s string, declared map[string]bool) error {
+	segments, err := goenvsubst.Parse(s)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.Kind != goenvsubst.PlaceholderSegment {
+			continue
+		}
+		checkName(pass, at, segment.Name, declared)
+		if err := checkTemplate(pass, at, segment.Arg, declared); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkName reports a single placeholder whose variable is not declared
+func checkName(pass *analysis.Pass, at ast.Node, name string, declared map[string]bool) {
+	if len(declared) == 0 || declared[name] {
+		return
+	}
+	if suggestion := closest(name, declared); suggestion != "" {
+		pass.Reportf(at.Pos(), "undeclared variable %s (did you mean %s?)", name, suggestion)
+		return
+	}
+	pass.Reportf(at.Pos(), "undeclared variable %s", name)
+}
+
+// checkFields reports the fields set to a string literal in lit, if it is a
+// struct literal, that reference variables but miss the tags they need
+func checkFields(pass *analysis.Pass, lit *ast.CompositeLit) {
+	t := pass.TypesInfo.TypeOf(lit)
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+
+	for i, elt := range lit.Elts {
+		index := i
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			key, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			index = fieldIndex(st, key.Name)
+			elt = kv.Value
+		}
+		basic, ok := elt.(*ast.BasicLit)
+		if !ok || basic.Kind != token.STRING || index < 0 || index >= st.NumFields() {
+			continue
+		}
+		s, err := strconv.Unquote(basic.Value)
+		if err != nil {
+			continue
+		}
+		segments, err := goenvsubst.Parse(s)
+		if err != nil {
+			continue
+		}
+		checkField(pass, basic, st.Field(index).Name(), st.Tag(index), segments)
+	}
+}
+
+// checkField reports a field named name, with struct tag tag, whose value
+// at has the segments of a parsed template but lacks the required or
+// secret tag
+func checkField(pass *analysis.Pass, at ast.Node, name, tag string, segments []goenvsubst.Segment) {
+	options := astscan.TagOptions(reflect.StructTag(tag))
+	hasDefault := slices.ContainsFunc(options, func(option string) bool { return strings.HasPrefix(option, "default=") })
+
+	referenced := false
+	for _, segment := range segments {
+		if segment.Kind != goenvsubst.PlaceholderSegment {
+			continue
+		}
+		referenced = true
+		if segment.Op == "" && !hasDefault && !slices.Contains(options, "required") {
+			pass.Reportf(at.Pos(), `field %s references %s without a default but is not tagged envsubst:"required"`, name, segment.Name)
+			break
+		}
+	}
+	if referenced && isSecretName(name) && !slices.Contains(options, "secret") {
+		pass.Reportf(at.Pos(), `field %s looks like a secret but is not tagged envsubst:"secret"`, name)
+	}
+}
+
+// isSecretName reports whether the field name suggests a secret
+func isSecretName(name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	return slices.ContainsFunc(secretWords, func(word string) bool { return strings.Contains(name, word) })
+}
+
+// fieldIndex returns the index of the field of st named name, or -1
+func fieldIndex(st *types.Struct, name string) int {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// declaredVars merges the names given with -vars and -varsfile
+func declaredVars() (map[string]bool, error) {
+	declared := map[string]bool{}
+	for _, name := range strings.Split(varsFlag, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			declared[name] = true
+		}
+	}
+
+	if varsFileFlag == "" {
+		return declared, nil
+	}
+	f, err := os.Open(varsFileFlag)
+	if err != nil {
+		return nil, fmt.Errorf("goenvsubst: read -varsfile: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, _, _ := strings.Cut(line, "=")
+		declared[strings.TrimSpace(strings.TrimPrefix(name, "export "))] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("goenvsubst: read -varsfile: %w", err)
+	}
+	return declared, nil
+}
+
+// closest returns the declared name nearest to name if it is within two
+// edits, which is what typos usually amount to
+func closest(name string, declared map[string]bool) string {
+	best, bestDist := "", 3
+	for candidate := range declared {
+		d := distance(name, candidate)
+		if d < bestDist || (d == bestDist && candidate < best) {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,29 @@
+package analyzer_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/iamolegga/goenvsubst/analyzer"
+)
+
+func TestAnalyzer(t *testing.T) {
+	if err := analyzer.Analyzer.Flags.Set("vars", "DATABASE_URL,API_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.Analyzer.Flags.Set("tags", "false"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, analysistest.TestData(), analyzer.Analyzer, "a")
+}
+
+func TestAnalyzerTags(t *testing.T) {
+	if err := analyzer.Analyzer.Flags.Set("vars", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.Analyzer.Flags.Set("tags", "true"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, analysistest.TestData(), analyzer.Analyzer, "b")
+}
@@ -0,0 +1,15 @@
+// Command goenvsubstvet runs the goenvsubst analyzer, standalone or as a
+// go vet tool:
+//
+//	go vet -vettool=$(which goenvsubstvet) -vars=DATABASE_URL,API_KEY ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/iamolegga/goenvsubst/analyzer"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
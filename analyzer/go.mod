@@ -0,0 +1,14 @@
+module github.com/iamolegga/goenvsubst/analyzer
+
+go 1.24.4
+
+require (
+	github.com/iamolegga/goenvsubst v1.0.0
+	golang.org/x/tools v0.38.0
+)
+
+require (
+	golang.org/x/mod v0.29.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
+)
+
@@ -0,0 +1,41 @@
+package a
+
+import "github.com/iamolegga/goenvsubst"
+
+type Config struct {
+	DatabaseURL string
+	APIKey      string
+	Static      string
+	Nested      struct{ Token string }
+}
+
+func direct() {
+	_ = goenvsubst.Do(&Config{
+		DatabaseURL: "$DATABASE_URL",
+		APIKey:      "$API_KEI",                       // want `undeclared variable API_KEI \(did you mean API_KEY\?\)`
+		Static:      "https://${API_KEY}@${HOSTNAME}", // want `undeclared variable HOSTNAME`
+	})
+}
+
+func throughVariable() {
+	cfg := Config{
+		DatabaseURL: "$DATABSE_URL", // want `undeclared variable DATABSE_URL \(did you mean DATABASE_URL\?\)`
+	}
+	cfg.Nested.Token = "$TOKEN"
+	_ = goenvsubst.Do(&cfg)
+}
+
+func slices() {
+	urls := []string{"$DATABASE_URL", "$COMPLETELY_DIFFERENT", "5$"} // want `undeclared variable COMPLETELY_DIFFERENT`
+	_ = goenvsubst.Do(&urls)
+}
+
+func inlineReferences() {
+	_ = goenvsubst.Do(&[]string{"postgres://$DATABASE_URL/$DB_NAME"}) // want `undeclared variable DB_NAME`
+	_ = goenvsubst.Do(&[]string{"${DATABASE_URL:-$FALLBACK_URL}"})    // want `undeclared variable FALLBACK_URL`
+	_ = goenvsubst.Do(&[]string{"${DATABASE_URL"})                    // want `invalid placeholder: goenvsubst: syntax error at 1:1: unterminated \$\{`
+}
+
+func notPassedToDo() {
+	_ = Config{DatabaseURL: "$NOT_CHECKED"}
+}
@@ -0,0 +1,30 @@
+package a
+
+import (
+	"fmt"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func entryPoints() {
+	cfg := Config{DatabaseURL: "$DATABASE_URLS"} // want `undeclared variable DATABASE_URLS \(did you mean DATABASE_URL\?\)`
+	_ = goenvsubst.DoPtr(&cfg)
+	_, _ = goenvsubst.DoCopy(Config{APIKey: "$APIKEY"})     // want `undeclared variable APIKEY \(did you mean API_KEY\?\)`
+	_, _ = goenvsubst.DoCopy[[]string]([]string{"$COPIED"}) // want `undeclared variable COPIED`
+	_ = goenvsubst.DoWithMap(&[]string{"$FROM_MAP"}, nil)   // want `undeclared variable FROM_MAP`
+	_ = goenvsubst.New().Do(&[]string{"$FROM_EXPANDER"})    // want `undeclared variable FROM_EXPANDER`
+	_, _ = goenvsubst.Check(&[]string{"$CHECKED"})          // want `undeclared variable CHECKED`
+	_, _ = goenvsubst.Expand("$EXPANDED")                   // want `undeclared variable EXPANDED`
+	_, _ = goenvsubst.ExpandWith("${API_KEY}$WITH", nil)    // want `undeclared variable WITH`
+	_, _ = goenvsubst.New().Expand("$FROM_EXPANDER_EXPAND") // want `undeclared variable FROM_EXPANDER_EXPAND`
+	_ = fmt.Sprint("$NOT_EXPANDED")
+}
+
+type Tagged struct {
+	URL     string `env:"DATABSE_URL"`                 // want `undeclared variable DATABSE_URL \(did you mean DATABASE_URL\?\)`
+	Hosts   string `envsubst:"${HOSTS:-a,b},required"` // want `undeclared variable HOSTS`
+	Key     string `envsubst:"required,default=$API_KEY"`
+	Port    string `env:"PORT,required"`
+	Debug   string `envwhen:"APP_ENV=dev,test"` // want `undeclared variable APP_ENV`
+	Skipped string `envsubst:"-"`
+}
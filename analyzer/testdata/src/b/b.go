@@ -0,0 +1,33 @@
+package b
+
+import "github.com/iamolegga/goenvsubst"
+
+type Config struct {
+	DatabaseURL string
+	Checked     string `envsubst:"required"`
+	Port        string `envsubst:"default=8080"`
+	APIToken    string `envsubst:"required,secret"`
+	DBPassword  string `envsubst:"required"`
+	Static      string
+}
+
+func tags() {
+	_ = goenvsubst.Do(&Config{
+		DatabaseURL: "postgres://$DATABASE_URL", // want `field DatabaseURL references DATABASE_URL without a default but is not tagged envsubst:"required"`
+		Checked:     "$DATABASE_URL",
+		Port:        "$PORT",
+		APIToken:    "$API_TOKEN",
+		DBPassword:  "$DB_PASSWORD", // want `field DBPassword looks like a secret but is not tagged envsubst:"secret"`
+		Static:      "${LOG_LEVEL:-info} plain",
+	})
+	_ = goenvsubst.Do(&Config{"${DATABASE_URL:?}", "", "", "", "", "$LOG_LEVEL"}) // want `field Static references LOG_LEVEL without a default but is not tagged envsubst:"required"`
+}
+
+// Options after default= belong to the default, as the library reads them
+type Defaults struct {
+	APISecret string `envsubst:"default=x,secret"`
+}
+
+func defaults() {
+	_ = goenvsubst.Do(&Defaults{APISecret: "$API_SECRET"}) // want `field APISecret looks like a secret but is not tagged envsubst:"secret"`
+}
@@ -0,0 +1,24 @@
+// Package goenvsubst is a stub of the real package for analyzer tests.
+package goenvsubst
+
+func Do(v any) error { return nil }
+
+func DoPtr[T any](v *T) error { return nil }
+
+func DoCopy[T any](v T) (T, error) { return v, nil }
+
+func DoWithMap(v any, vars map[string]string) error { return nil }
+
+func Check(v any) ([]string, error) { return nil, nil }
+
+type Expander struct{}
+
+func New() *Expander { return &Expander{} }
+
+func (e *Expander) Do(v any) error { return nil }
+
+func (e *Expander) Expand(s string) (string, error) { return s, nil }
+
+func Expand(s string) (string, error) { return s, nil }
+
+func ExpandWith(s string, r any) (string, error) { return s, nil }
@@ -0,0 +1,47 @@
+package goenvsubst
+
+import (
+	"reflect"
+	"strings"
+)
+
+// UnresolvedError is returned by AssertFullyResolved and lists the paths of
+// all values that still contain placeholders.
+type UnresolvedError struct {
+	// Paths holds locations such as Database.Replicas[2].DSN
+	Paths []string
+}
+
+func (e *UnresolvedError) Error() string {
+	return "goenvsubst: unresolved placeholders in " + strings.Join(e.Paths, ", ")
+}
+
+// AssertFullyResolved scans v after substitution and returns an
+// *UnresolvedError if any string value still contains a placeholder of the
+// syntax selected with WithSyntax, or one that does not parse. It never
+// modifies v and is meant as a cheap safety net before the configuration is
+// used. v is walked as Do walks it, with opts applied as in Do, so fields
+// tagged envsubst:"-" and types skipped with WithSkipTypes are not checked.
+// A literal produced by the $$ escape, such as $HOME from $$HOME, looks
+// like a placeholder and is reported as well.
+func AssertFullyResolved(v any, opts ...Option) error {
+	var paths []string
+	w := New(opts...).walker()
+	syntax := w.referenceSyntax()
+	s := &scanner{w: w, leaf: func(v reflect.Value, path string, _ []string) {
+		if v.Kind() != reflect.String {
+			return
+		}
+		// A malformed reference is left over as much as a well-formed one
+		if placeholders, err := syntax.Find(v.String()); err != nil || len(placeholders) > 0 {
+			paths = append(paths, displayPath(path))
+		}
+	}}
+	if err := s.value(reflect.ValueOf(v), "", nil); err != nil {
+		return err
+	}
+	if len(paths) > 0 {
+		return &UnresolvedError{Paths: paths}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// rawTemplate holds a template that is passed on without substitution
+type rawTemplate struct{ Text string }
+
+func TestAssertFullyResolved(t *testing.T) {
+	type Replica struct{ DSN string }
+
+	tests := []struct {
+		name     string
+		input    any
+		opts     []goenvsubst.Option
+		expected []string
+	}{
+		{
+			name:  "fully resolved",
+			input: &struct{ A, B string }{"value", "cost: 5$"},
+		},
+		{
+			name:     "top-level string",
+			input:    func() *string { s := "$MISSING"; return &s }(),
+			expected: []string{"(root)"},
+		},
+		{
+			name: "nested paths",
+			input: &struct {
+				Database struct {
+					URL      string
+					Replicas []Replica
+				}
+				Env map[string]string
+			}{
+				Database: struct {
+					URL      string
+					Replicas []Replica
+				}{"${DB_URL}", []Replica{{"ok"}, {"$DSN"}}},
+				Env: map[string]string{"b": "$B", "a": "plain"},
+			},
+			expected: []string{"Database.URL", "Database.Replicas[1].DSN", "Env[b]"},
+		},
+		{
+			name: "skipped fields are ignored",
+			input: &struct {
+				Price string `envsubst:"-"`
+				URL   string
+			}{"$5 per month", "$URL"},
+			expected: []string{"URL"},
+		},
+		{
+			name:  "non-string values are ignored",
+			input: &struct{ Port int }{8080},
+		},
+		{
+			name: "skipped types are ignored",
+			input: &struct {
+				Raw  rawTemplate
+				Name string
+			}{rawTemplate{"$RAW"}, "$NAME"},
+			opts:     []goenvsubst.Option{goenvsubst.WithSkipTypes(reflect.TypeFor[rawTemplate]())},
+			expected: []string{"Name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := goenvsubst.AssertFullyResolved(tt.input, tt.opts...)
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("AssertFullyResolved() error = %v", err)
+				}
+				return
+			}
+
+			var unresolved *goenvsubst.UnresolvedError
+			if !errors.As(err, &unresolved) {
+				t.Fatalf("AssertFullyResolved() error = %v, want *UnresolvedError", err)
+			}
+			if !reflect.DeepEqual(unresolved.Paths, tt.expected) {
+				t.Errorf("AssertFullyResolved() paths = %v, want %v", unresolved.Paths, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAssertFullyResolvedCycle(t *testing.T) {
+	type Node struct {
+		Name string
+		Next *Node
+	}
+	node := &Node{Name: "$NAME"}
+	node.Next = node
+
+	var unresolved *goenvsubst.UnresolvedError
+	if err := goenvsubst.AssertFullyResolved(node); !errors.As(err, &unresolved) || !reflect.DeepEqual(unresolved.Paths, []string{"Name"}) {
+		t.Errorf("AssertFullyResolved() error = %v, want unresolved Name", err)
+	}
+}
+
+func TestAssertFullyResolvedWithSyntax(t *testing.T) {
+	config := &struct {
+		Home  string
+		Data  string
+		Price string
+	}{Home: `%USERPROFILE%\app`, Data: "${{ env.DATA }}", Price: "$5 or $PRICE"}
+
+	var unresolved *goenvsubst.UnresolvedError
+	err := goenvsubst.AssertFullyResolved(config, goenvsubst.WithSyntax(goenvsubst.WindowsSyntax))
+	if !errors.As(err, &unresolved) || !reflect.DeepEqual(unresolved.Paths, []string{"Home"}) {
+		t.Errorf("AssertFullyResolved() error = %v, want unresolved Home", err)
+	}
+	err = goenvsubst.AssertFullyResolved(config, goenvsubst.WithSyntax(goenvsubst.ActionsSyntax))
+	if !errors.As(err, &unresolved) || !reflect.DeepEqual(unresolved.Paths, []string{"Data"}) {
+		t.Errorf("AssertFullyResolved() error = %v, want unresolved Data", err)
+	}
+}
@@ -0,0 +1,63 @@
+package goenvsubst
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// base64Encodings are the encodings a base64 field accepts, tried in order
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.RawStdEncoding,
+	base64.URLEncoding,
+	base64.RawURLEncoding,
+}
+
+// doBase64 expands a []byte field whose value is base64-encoded, such as a
+// binary secret delivered through the environment, and stores the decoded
+// bytes. The template is the field's source or env tag, or else the field's
+// own content. An empty result leaves the field unchanged.
+func (w *walker) doBase64(v reflect.Value, tag fieldTag, path string) error {
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+		return fmt.Errorf("goenvsubst: field %s: base64 option requires a []byte, got %s", path, v.Type())
+	}
+
+	var expanded string
+	var err error
+	if tag.source != "" || tag.env != "" {
+		expanded, err = w.expandSource(tag, path)
+	} else {
+		expanded, err = w.expand(string(v.Bytes()), path)
+	}
+	if err != nil || expanded == "" {
+		return err
+	}
+
+	decoded, err := decodeBase64(expanded)
+	if err != nil {
+		return fmt.Errorf("goenvsubst: field %s: decode base64: %w", path, err)
+	}
+	if !w.checking() {
+		v.SetBytes(decoded)
+	}
+	return nil
+}
+
+// decodeBase64 decodes s in the standard or URL alphabet, with or without
+// padding, ignoring the line breaks and spaces of wrapped values
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	var firstErr error
+	for _, enc := range base64Encodings {
+		decoded, err := enc.DecodeString(s)
+		if err == nil {
+			return decoded, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,74 @@
+package goenvsubst_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoBase64Tag(t *testing.T) {
+	key := []byte{0x00, 0xff, 0xfe, 'k', 'e', 'y'}
+	os.Setenv("TLS_KEY_B64", "AP/+a2V5")
+	os.Setenv("TLS_KEY_URL", "AP_-a2V5")
+	os.Setenv("TLS_KEY_WRAPPED", "AP/+\n a2V5\n")
+	defer func() {
+		os.Unsetenv("TLS_KEY_B64")
+		os.Unsetenv("TLS_KEY_URL")
+		os.Unsetenv("TLS_KEY_WRAPPED")
+	}()
+
+	config := &struct {
+		Content []byte `envsubst:"base64"`
+		Env     []byte `env:"TLS_KEY_B64" envsubst:"base64"`
+		Source  []byte `envsubst:"${TLS_KEY_URL},base64"`
+		Wrapped []byte `env:"TLS_KEY_WRAPPED" envsubst:"base64"`
+		Missing []byte `env:"MISSING_VAR" envsubst:"base64"`
+		Plain   []byte
+	}{
+		Content: []byte("$TLS_KEY_B64"),
+		Plain:   []byte("$TLS_KEY_B64"),
+	}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	for name, got := range map[string][]byte{"Content": config.Content, "Env": config.Env, "Source": config.Source, "Wrapped": config.Wrapped} {
+		if !bytes.Equal(got, key) {
+			t.Errorf("%s = %x, want %x", name, got, key)
+		}
+	}
+	if config.Missing != nil {
+		t.Errorf("Missing = %x, want nil", config.Missing)
+	}
+	if string(config.Plain) != "$TLS_KEY_B64" {
+		t.Errorf("Plain = %q, want it untouched", config.Plain)
+	}
+}
+
+func TestDoBase64TagErrors(t *testing.T) {
+	os.Setenv("TLS_KEY_B64", "not base64!")
+	defer os.Unsetenv("TLS_KEY_B64")
+
+	invalid := &struct {
+		Key []byte `env:"TLS_KEY_B64" envsubst:"base64"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "field Key") || strings.Contains(err.Error(), "not base64!") {
+		t.Errorf("Do() error = %v, want a decode error naming the field but not the value", err)
+	}
+
+	issues, err := goenvsubst.Check(invalid)
+	if err != nil || len(issues) != 1 {
+		t.Errorf("Check() = %v, %v, want one issue", issues, err)
+	}
+
+	wrongType := &struct {
+		Key string `env:"TLS_KEY_B64" envsubst:"base64"`
+	}{}
+	if err := goenvsubst.Do(wrongType); err == nil {
+		t.Error("Do() expected error for a field that is not []byte")
+	}
+}
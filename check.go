@@ -0,0 +1,110 @@
+package goenvsubst
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Issue is a problem Check found in a value.
+type Issue struct {
+	// Path locates the value, such as Database.Replicas[2].DSN; the
+	// outermost value is (root).
+	Path string
+	// Variable is the variable the issue concerns, if any.
+	Variable string
+	// Err describes the issue. It is nil when the issue is only that
+	// Variable is not set.
+	Err error
+}
+
+func (i Issue) String() string {
+	if i.Err == nil {
+		return i.Path + ": " + i.Variable + " is not set"
+	}
+	return strings.TrimPrefix(i.Err.Error(), "goenvsubst: ")
+}
+
+// Check is a dry run of Do: it walks v the same way but leaves it untouched,
+// and returns an Issue for every reference to an unset variable and every
+// value that Do would fail on, such as a malformed reference or a result
+// that does not convert to its field's type. References with a default, and
+// fields tagged with one, are not issues. opts apply as in Do. Since
+// nothing is modified, v may also be a struct passed by value.
+//
+// The returned error is reserved for failures that stop the walk, such as
+// a resolver error.
+func Check(v any, opts ...Option) ([]Issue, error) {
+	w := New(opts...).walker()
+	w.issues = &[]Issue{}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Kind() != reflect.Ptr {
+		// The walk skips values it could not set, so walk a settable copy
+		copied := reflect.New(rv.Type()).Elem()
+		copied.Set(rv)
+		rv = copied
+	}
+	err := w.doValue(rv, "")
+	return *w.issues, err
+}
+
+// resolveError is returned when the resolver fails. It always stops the
+// walk, also within Check.
+type resolveError struct {
+	name string
+	err  error
+}
+
+func (e *resolveError) Error() string {
+	return fmt.Sprintf("goenvsubst: resolve %s: %v", e.name, e.err)
+}
+
+func (e *resolveError) Unwrap() error {
+	return e.err
+}
+
+// checking reports whether the walker is running for Check, in which case
+// it must not modify the values it walks
+func (w *walker) checking() bool {
+	return w.issues != nil
+}
+
+// fail records err as an issue at path when checking, letting the walk go
+// on, and returns it otherwise
+func (w *walker) fail(path string, err error) error {
+	err = w.redact(err)
+	var rerr *resolveError
+	if err == nil || !w.checking() || errors.As(err, &rerr) {
+		return err
+	}
+	issue := Issue{Path: displayPath(path), Err: err}
+	var required *RequiredError
+	var denied *DeniedError
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &required):
+		issue.Variable = required.Name
+	case errors.As(err, &denied):
+		issue.Variable = denied.Name
+	case errors.As(err, &invalid):
+		issue.Variable = invalid.Name
+	}
+	*w.issues = append(*w.issues, issue)
+	return nil
+}
+
+// addIssue records a reference to the unset variable name at path, once per
+// path
+func (w *walker) addIssue(name, path string) {
+	path = displayPath(path)
+	for _, issue := range *w.issues {
+		if issue.Err == nil && issue.Path == path && issue.Variable == name {
+			return
+		}
+	}
+	*w.issues = append(*w.issues, Issue{Path: path, Variable: name})
+}
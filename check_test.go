@@ -0,0 +1,97 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+type checkConfig struct {
+	Database struct {
+		URL      string
+		Replicas []string
+	}
+	Port    int    `envsubst:"$CHECK_PORT"`
+	Region  string `envsubst:"default=eu-west-1"`
+	Labels  map[string]string
+	Broken  string
+	Message string
+}
+
+func TestCheck(t *testing.T) {
+	vars := map[string]string{"DB_HOST": "db.internal", "CHECK_PORT": "http"}
+
+	config := &checkConfig{}
+	config.Database.URL = "postgres://$DB_HOST/$DB_NAME"
+	config.Database.Replicas = []string{"$DB_HOST", "$REPLICA"}
+	config.Region = "$REGION"
+	config.Labels = map[string]string{"team": "$TEAM"}
+	config.Broken = "${UNTERMINATED"
+	config.Message = "${OWNER:?must be set}"
+	original := *config
+	original.Database.Replicas = append([]string(nil), config.Database.Replicas...)
+
+	issues, err := goenvsubst.Check(config, goenvsubst.WithResolver(goenvsubst.MapResolver(vars)))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	got := make([]string, len(issues))
+	for i, issue := range issues {
+		got[i] = issue.String()
+	}
+	expected := []string{
+		"Database.URL: DB_NAME is not set",
+		"Database.Replicas[1]: REPLICA is not set",
+		`field Port: parse int: strconv.ParseInt: parsing "http": invalid syntax`,
+		"Labels[team]: TEAM is not set",
+		"Broken: syntax error at 1:1: unterminated ${",
+		"Message: OWNER: must be set",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Check() issues =\n%q\nwant\n%q", got, expected)
+	}
+	if issues[5].Variable != "OWNER" {
+		t.Errorf("Check() issue variable = %q, want OWNER", issues[5].Variable)
+	}
+
+	if !reflect.DeepEqual(config.Database, original.Database) || config.Port != 0 || config.Region != "$REGION" ||
+		config.Labels["team"] != "$TEAM" {
+		t.Errorf("Check() modified the input: %+v", config)
+	}
+}
+
+func TestCheckByValue(t *testing.T) {
+	config := checkConfig{Broken: "$BROKEN_HOST"}
+	config.Labels = map[string]string{"team": "$TEAM"}
+
+	issues, err := goenvsubst.Check(config, goenvsubst.WithResolver(goenvsubst.MapResolver(nil)))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	got := make([]string, len(issues))
+	for i, issue := range issues {
+		got[i] = issue.String()
+	}
+	expected := []string{"Port: CHECK_PORT is not set", "Labels[team]: TEAM is not set", "Broken: BROKEN_HOST is not set"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Check() issues = %q, want %q", got, expected)
+	}
+	if config.Labels["team"] != "$TEAM" {
+		t.Errorf("Check() modified the input: %+v", config)
+	}
+}
+
+func TestCheckResolverError(t *testing.T) {
+	errUnavailable := errors.New("store unavailable")
+	resolver := goenvsubst.ResolverFunc(func(string) (string, bool, error) {
+		return "", false, errUnavailable
+	})
+
+	_, err := goenvsubst.Check(&[]string{"$REMOTE"}, goenvsubst.WithResolver(resolver))
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("Check() error = %v, want %v", err, errUnavailable)
+	}
+}
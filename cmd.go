@@ -0,0 +1,120 @@
+package goenvsubst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DoCmd replaces environment variable references in the arguments, working
+// directory and environment of cmd before it is started. For cmd.Env entries
+// only the part after the first = is processed, so variable names are never
+// modified. cmd.Path is left as resolved by exec.Command. opts apply as in
+// Do.
+func DoCmd(cmd *exec.Cmd, opts ...Option) error {
+	if cmd == nil {
+		return nil
+	}
+
+	w := New(opts...).walker()
+	if err := w.doValue(reflect.ValueOf(cmd.Args), "Args"); err != nil {
+		return err
+	}
+	dir, err := w.expand(cmd.Dir, "Dir")
+	if err != nil {
+		return err
+	}
+	cmd.Dir = dir
+
+	for i, kv := range cmd.Env {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		expanded, err := w.expand(value, indexPath("Env", i))
+		if err != nil {
+			return err
+		}
+		cmd.Env[i] = key + "=" + expanded
+	}
+	return w.finish()
+}
+
+// defaultCommandTimeout limits the commands of a CommandResolver without a
+// Timeout
+const defaultCommandTimeout = 10 * time.Second
+
+// CommandResolver runs the command that a name spells and resolves to its
+// standard output without the trailing newline, for values that must be
+// derived at startup, such as access tokens. Give it a scheme to reference
+// commands as ${cmd:gcloud auth print-access-token}:
+//
+//	goenvsubst.WithScheme("cmd", goenvsubst.CommandResolver{Allow: []string{"gcloud auth print-access-token"}})
+//
+// The name is split into the command and its arguments at spaces, without a
+// shell, so quotes, pipes and references have no special meaning. Only the
+// command lines listed in Allow may run, matched exactly, arguments
+// included; any other command line makes the call fail without running. A
+// command that exits with an error, or runs longer than Timeout, also makes
+// the call fail. Commands run each time they are referenced.
+type CommandResolver struct {
+	// Allow lists the command lines that may run, such as
+	// "gcloud auth print-access-token", with the command by name or by
+	// path. An entry whose last argument is ... allows any further
+	// arguments after the ones it lists, as in "vault kv get ...".
+	Allow []string
+	// Timeout limits how long each command may run; zero means 10 seconds.
+	Timeout time.Duration
+}
+
+// Resolve runs the command name.
+func (c CommandResolver) Resolve(name string) (string, bool, error) {
+	args := strings.Fields(name)
+	if len(args) == 0 {
+		return "", false, errors.New("empty command")
+	}
+	if !slices.ContainsFunc(c.Allow, func(allowed string) bool { return allowsCommand(allowed, args) }) {
+		return "", false, fmt.Errorf("command %s is not allowed", strings.Join(args, " "))
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Do not wait for children that keep the output open
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", false, fmt.Errorf("command %s timed out after %s", args[0], timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", false, fmt.Errorf("command %s: %w: %s", args[0], err, msg)
+		}
+		return "", false, fmt.Errorf("command %s: %w", args[0], err)
+	}
+
+	return trimNewline(stdout.String()), true, nil
+}
+
+// allowsCommand reports whether the Allow entry allowed permits the command
+// line args
+func allowsCommand(allowed string, args []string) bool {
+	fixed := strings.Fields(allowed)
+	if n := len(fixed); n > 0 && fixed[n-1] == "..." {
+		fixed = fixed[:n-1]
+		return len(args) >= len(fixed) && slices.Equal(args[:len(fixed)], fixed)
+	}
+	return slices.Equal(args, fixed)
+}
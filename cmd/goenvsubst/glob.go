@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inputs expands the file arguments: directories stand for the regular
+// files below them and glob patterns for the files they match, in lexical
+// order. - and plain file names are kept as they are.
+func inputs(args []string) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		switch {
+		case arg == "-":
+			names = append(names, arg)
+		case isPattern(arg):
+			matches, err := glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no files match", arg)
+			}
+			names = append(names, matches...)
+		default:
+			info, err := os.Stat(arg)
+			if err != nil || !info.IsDir() {
+				names = append(names, arg)
+				continue
+			}
+			files, err := walkFiles(arg, func(string) bool { return true })
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, files...)
+		}
+	}
+	return names, nil
+}
+
+// isPattern reports whether arg contains glob metacharacters
+func isPattern(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
+
+// glob returns the regular files matching pattern, which may contain ** to
+// match any number of directories
+func glob(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				files = append(files, match)
+			}
+		}
+		return files, nil
+	}
+
+	// Walk from the longest directory without metacharacters
+	parts := strings.Split(pattern, string(filepath.Separator))
+	n := 0
+	for n < len(parts)-1 && !isPattern(parts[n]) {
+		n++
+	}
+	root := filepath.Join(parts[:n]...)
+	if strings.HasPrefix(pattern, string(filepath.Separator)) {
+		root = string(filepath.Separator) + root
+	}
+	if root == "" {
+		root = "."
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	rest := parts[n:]
+	files, err := walkFiles(root, func(path string) bool {
+		rel, err := filepath.Rel(root, path)
+		return err == nil && matchParts(rest, strings.Split(rel, string(filepath.Separator)))
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return files, err
+}
+
+// walkFiles returns the regular files below root for which match is true
+func walkFiles(root string, match func(path string) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && match(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// matchParts matches the elements of a path against those of a pattern, in
+// which ** matches any number of elements
+func matchParts(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchParts(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	matched, _ := filepath.Match(pattern[0], path[0])
+	return matched && matchParts(pattern[1:], path[1:])
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.yaml", "deploy/a.yaml", "deploy/prod/b.yaml", "deploy/prod/c.json", "deploy/prod/eu/d.yaml"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	join := func(names ...string) []string {
+		for i, name := range names {
+			names[i] = filepath.Join(dir, name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"recursive pattern", join("deploy/**/*.yaml"), join("deploy/a.yaml", "deploy/prod/b.yaml", "deploy/prod/eu/d.yaml")},
+		{"pattern from the root", join("**/b.yaml"), join("deploy/prod/b.yaml")},
+		{"plain pattern", join("deploy/prod/*"), join("deploy/prod/b.yaml", "deploy/prod/c.json")},
+		{"directory", join("deploy/prod"), join("deploy/prod/b.yaml", "deploy/prod/c.json", "deploy/prod/eu/d.yaml")},
+		{"files and stdin", append(join("app.yaml"), "-"), append(join("app.yaml"), "-")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inputs(tt.args)
+			if err != nil {
+				t.Fatalf("inputs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := inputs(join("deploy/**/*.toml")); err == nil {
+		t.Error("inputs() with a pattern matching nothing succeeded")
+	}
+}
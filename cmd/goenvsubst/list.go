@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// reference is a variable referenced by the inputs
+type reference struct {
+	name string
+	// locations holds where the variable is referenced, as file:line:column
+	locations []string
+}
+
+// list prints every variable referenced by the inputs in syntax, in name
+// order, with whether it is set and where it is referenced. It fails if a
+// variable is unset and strict is set.
+func list(expander *goenvsubst.Expander, syntax goenvsubst.Syntax, names []string, stdin io.Reader, stdout, stderr io.Writer, strict bool) int {
+	var refs []*reference
+	for _, name := range names {
+		content, err := read(name, stdin)
+		if err == nil {
+			err = collect(&refs, syntax, displayName(name), content)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: %s: %s\n", displayName(name), message(err))
+			return 1
+		}
+	}
+	slices.SortFunc(refs, func(a, b *reference) int { return strings.Compare(a.name, b.name) })
+
+	var unset []string
+	for _, ref := range refs {
+		_, ok, err := expander.Lookup(ref.name)
+		if err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: %s\n", message(err))
+			return 1
+		}
+		state := "set"
+		if !ok {
+			state = "unset"
+			unset = append(unset, ref.name)
+		}
+		fmt.Fprintf(stdout, "%s\t%s\t%s\n", ref.name, state, strings.Join(ref.locations, " "))
+	}
+	if strict && len(unset) > 0 {
+		fmt.Fprintf(stderr, "goenvsubst: unset variables: %s\n", strings.Join(unset, ", "))
+		return 1
+	}
+	return 0
+}
+
+// collect adds the references in syntax in content, the content of file,
+// to refs
+func collect(refs *[]*reference, syntax goenvsubst.Syntax, file, content string) error {
+	return collectTemplate(refs, syntax, file, content, goenvsubst.Position{Line: 1, Column: 1})
+}
+
+// collectTemplate adds the references in template, which starts at start
+// within its file, to refs, including those in the arguments of operators
+func collectTemplate(refs *[]*reference, syntax goenvsubst.Syntax, file, template string, start goenvsubst.Position) error {
+	placeholders, err := syntax.Find(template)
+	if err != nil {
+		return err
+	}
+	// pos is the position of the latest reference within the file, which
+	// the next one is found from
+	pos := start
+	for _, segment := range placeholders {
+		i := slices.IndexFunc(*refs, func(r *reference) bool { return r.name == segment.Name })
+		if i < 0 {
+			*refs = append(*refs, &reference{name: segment.Name})
+			i = len(*refs) - 1
+		}
+		pos = pos.Advance(template[pos.Offset-start.Offset : segment.Start.Offset])
+		(*refs)[i].locations = append((*refs)[i].locations, fmt.Sprintf("%s:%d:%d", file, pos.Line, pos.Column))
+
+		if segment.Arg != "" {
+			// The argument ends just before the closing brace
+			argOffset := segment.End.Offset - len("}") - len(segment.Arg)
+			argStart := pos.Advance(template[segment.Start.Offset:argOffset])
+			if err := collectTemplate(refs, syntax, file, segment.Arg, argStart); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Command goenvsubst substitutes variable references in text, like the
+// envsubst command of GNU gettext, with the syntax of the goenvsubst
+// package: $VAR, ${VAR}, the operators such as ${VAR:-default} and the $$
+// escape. Like envsubst, it copies a $ that does not start a well-formed
+// reference, such as the one of a shell script's ${arr[@]}, as is. It reads
+// the named files, or standard input without any, and writes the result to
+// standard output:
+//
+//	goenvsubst < nginx.conf.tmpl > nginx.conf
+//	goenvsubst -strict -env-file .env config.yaml.tmpl > config.yaml
+//
+// Like envsubst, it takes an optional shell-format first argument that
+// restricts substitution to the variables it names and copies everything
+// else, such as nginx's own $variables, as is:
+//
+//	goenvsubst '$SERVER_NAME $PORT' < nginx.conf.tmpl > nginx.conf
+//
+// With -i it edits files in place. Arguments may then also be directories,
+// processed recursively, or glob patterns in which ** matches any number of
+// directories:
+//
+//	goenvsubst -i 'deploy/**/*.yaml'
+//
+// The watch subcommand renders a directory of templates into another and
+// renders them again whenever a template or an -env-file changes, for
+// development loops and configuration sidecars:
+//
+//	goenvsubst watch -src templates -dst out -env-file .env
+//
+// The scan subcommand parses Go source and lists the variables that the
+// code references through the goenvsubst package, with where it does:
+//
+//	goenvsubst scan ./...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+const usage = `usage: goenvsubst [flags] [shell-format] [file ...]
+
+Substitutes variable references in the files, or in standard input if none
+are given, and writes the result to standard output, or back to the files
+with -i. A $ that does not start a well-formed reference, as in ${arr[@]},
+is copied as is. With -list it prints every referenced variable instead, with
+whether it is set and the file:line:column of each reference; together
+with -strict it fails if any is unset. A file may be a directory, which
+stands for all files below it, or a glob pattern such as
+'deploy/**/*.yaml', where ** matches any number of directories.
+
+goenvsubst watch -src dir -dst dir renders the files below one directory
+into another whenever they or the -env-file files change; see
+goenvsubst watch -h. goenvsubst scan [dir ...] lists the variables that Go
+source references through the goenvsubst package; see goenvsubst scan -h.
+
+A first argument containing a $, such as '$HOST ${PORT}', is a shell-format
+as in GNU envsubst: only references to the variables it names are
+substituted, and any other text, including other references and $$, is
+copied as is.
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns its exit status: 0 on success, 1 if
+// substitution failed and 2 for invalid arguments
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "watch":
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return watch(ctx, args[1:], stderr)
+		case "scan":
+			return scan(args[1:], stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("goenvsubst", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	strict := flags.Bool("strict", false, "fail if a referenced variable is not set")
+	keepUnset := flags.Bool("keep-unset", false, "leave references to unset variables as written")
+	inPlace := flags.Bool("i", false, "edit the files in place instead of writing to standard output")
+	listOnly := flags.Bool("list", false, "list the referenced variables, whether they are set and where they are referenced, instead of substituting")
+	var envFiles []string
+	flags.Func("env-file", "read variables the environment does not set from a .env `file`; may be repeated", func(path string) error {
+		envFiles = append(envFiles, path)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	var opts []goenvsubst.Option
+	if *strict {
+		opts = append(opts, goenvsubst.Strict())
+	}
+	if *keepUnset {
+		opts = append(opts, goenvsubst.WithKeepUnset())
+	}
+	if len(envFiles) > 0 {
+		opts = append(opts, goenvsubst.WithDotenv(envFiles...))
+	}
+	syntax := goenvsubst.LenientShellSyntax
+	args = flags.Args()
+	if len(args) > 0 && strings.Contains(args[0], "$") {
+		vars, err := formatNames(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: invalid shell-format: %s\n", message(err))
+			return 2
+		}
+		syntax = goenvsubst.ShellFormatSyntax(vars...)
+		args = args[1:]
+	}
+	expander := goenvsubst.New(append(opts, goenvsubst.WithSyntax(syntax))...)
+
+	names, err := inputs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "goenvsubst: %s\n", message(err))
+		return 1
+	}
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	if *listOnly {
+		return list(expander, syntax, names, stdin, stdout, stderr, *strict)
+	}
+	if *inPlace {
+		if len(args) == 0 || slices.Contains(names, "-") {
+			fmt.Fprintln(stderr, "goenvsubst: -i needs files to edit, not standard input")
+			return 2
+		}
+		return editInPlace(expander, names, stderr)
+	}
+
+	for _, name := range names {
+		expanded, err := substitute(expander, name, stdin)
+		if err == nil {
+			_, err = io.WriteString(stdout, expanded)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: %s: %s\n", displayName(name), message(err))
+			return 1
+		}
+	}
+	return 0
+}
+
+// editInPlace substitutes the files and replaces each with its result. All
+// files are substituted before any is written, so an error leaves them all
+// as they were.
+func editInPlace(expander *goenvsubst.Expander, names []string, stderr io.Writer) int {
+	results := make([]string, len(names))
+	for i, name := range names {
+		expanded, err := substitute(expander, name, nil)
+		if err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: %s: %s\n", name, message(err))
+			return 1
+		}
+		results[i] = expanded
+	}
+	for i, name := range names {
+		if err := writeFile(name, results[i]); err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: %s: %s\n", name, message(err))
+			return 1
+		}
+	}
+	return 0
+}
+
+// substitute returns the substituted content of the file name, or of stdin
+// for -
+func substitute(expander *goenvsubst.Expander, name string, stdin io.Reader) (string, error) {
+	content, err := read(name, stdin)
+	if err != nil {
+		return "", err
+	}
+	return expander.Expand(content)
+}
+
+// read returns the content of the file name, or of stdin for -
+func read(name string, stdin io.Reader) (string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	return string(data), err
+}
+
+// writeFile atomically replaces the file name with content, keeping its
+// permissions: content is written to a temporary file in the same
+// directory, which is then renamed over the original
+func writeFile(name, content string) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.WriteString(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// displayName names the input name in messages
+func displayName(name string) string {
+	if name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+// message describes err without the package prefix and the file name,
+// listing only the names of unset variables, since a whole input has no
+// path
+func message(err error) string {
+	var unsetErr *goenvsubst.UnsetError
+	if errors.As(err, &unsetErr) {
+		return "unset variables: " + strings.Join(unsetErr.Names, ", ")
+	}
+	var fsErr *fs.PathError
+	if errors.As(err, &fsErr) {
+		return fsErr.Err.Error()
+	}
+	return strings.TrimPrefix(err.Error(), "goenvsubst: ")
+}
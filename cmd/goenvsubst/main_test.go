@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	os.Setenv("GOENVSUBST_CLI_HOST", "db.internal")
+	defer os.Unsetenv("GOENVSUBST_CLI_HOST")
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.tmpl")
+	if err := os.WriteFile(file, []byte("file: $GOENVSUBST_CLI_HOST\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envFile, []byte("GOENVSUBST_CLI_PORT=5432\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		stdout string
+		stderr string
+		status int
+	}{
+		{
+			name:   "stdin",
+			stdin:  "host: ${GOENVSUBST_CLI_HOST}, port: ${GOENVSUBST_CLI_PORT:-80}, cost: $$5",
+			stdout: "host: db.internal, port: 80, cost: $5",
+		},
+		{
+			name:   "files and stdin",
+			args:   []string{file, "-"},
+			stdin:  "stdin: $GOENVSUBST_CLI_HOST",
+			stdout: "file: db.internal\nstdin: db.internal",
+		},
+		{
+			name:   "env file",
+			args:   []string{"-env-file", envFile},
+			stdin:  "$GOENVSUBST_CLI_HOST:$GOENVSUBST_CLI_PORT",
+			stdout: "db.internal:5432",
+		},
+		{
+			name:   "keep unset",
+			args:   []string{"-keep-unset"},
+			stdin:  "$GOENVSUBST_CLI_MISSING",
+			stdout: "$GOENVSUBST_CLI_MISSING",
+		},
+		{
+			name:   "strict",
+			args:   []string{"-strict"},
+			stdin:  "$GOENVSUBST_CLI_MISSING ${GOENVSUBST_CLI_OTHER}",
+			stderr: "goenvsubst: <stdin>: unset variables: GOENVSUBST_CLI_MISSING, GOENVSUBST_CLI_OTHER\n",
+			status: 1,
+		},
+		{
+			name:   "malformed references are copied",
+			stdin:  "for h in ${hosts[@]}; do echo $h@$GOENVSUBST_CLI_HOST; done\n${BROKEN",
+			stdout: "for h in ${hosts[@]}; do echo @db.internal; done\n${BROKEN",
+		},
+		{
+			name:   "shell-format",
+			args:   []string{"$GOENVSUBST_CLI_HOST ${GOENVSUBST_CLI_PORT}"},
+			stdin:  "server_name $GOENVSUBST_CLI_HOST; proxy_set_header Host $host; port ${GOENVSUBST_CLI_PORT:-80}; $$ ${GOENVSUBST_CLI_OTHER}",
+			stdout: "server_name db.internal; proxy_set_header Host $host; port 80; $$ ${GOENVSUBST_CLI_OTHER}",
+		},
+		{
+			name:   "shell-format and shell scripts",
+			args:   []string{"$GOENVSUBST_CLI_HOST"},
+			stdin:  "for h in ${hosts[@]}; do ping ${h:-$GOENVSUBST_CLI_HOST}; done; echo $$GOENVSUBST_CLI_HOST ${BROKEN",
+			stdout: "for h in ${hosts[@]}; do ping ${h:-db.internal}; done; echo $db.internal ${BROKEN",
+		},
+		{
+			name:   "shell-format and files",
+			args:   []string{"${GOENVSUBST_CLI_HOST}", file},
+			stdout: "file: db.internal\n",
+		},
+		{
+			name:   "invalid shell-format",
+			args:   []string{"$"},
+			stderr: "goenvsubst: invalid shell-format: no variables in \"$\"\n",
+			status: 2,
+		},
+		{
+			name:   "list",
+			args:   []string{"-list"},
+			stdin:  "host: $GOENVSUBST_CLI_HOST\nport: ${GOENVSUBST_CLI_PORT:-$GOENVSUBST_CLI_HOST}",
+			stdout: "GOENVSUBST_CLI_HOST\tset\t<stdin>:1:7 <stdin>:2:30\nGOENVSUBST_CLI_PORT\tunset\t<stdin>:2:7\n",
+		},
+		{
+			name:   "list modifiers",
+			args:   []string{"-list"},
+			stdin:  "port: ${GOENVSUBST_CLI_PORT|trim:-$GOENVSUBST_CLI_HOST}",
+			stdout: "GOENVSUBST_CLI_HOST\tset\t<stdin>:1:35\nGOENVSUBST_CLI_PORT\tunset\t<stdin>:1:7\n",
+		},
+		{
+			name:   "list shell-format",
+			args:   []string{"-list", "$GOENVSUBST_CLI_HOST"},
+			stdin:  "${hosts[@]} $GOENVSUBST_CLI_PORT\n${GOENVSUBST_CLI_HOST}",
+			stdout: "GOENVSUBST_CLI_HOST\tset\t<stdin>:2:1\n",
+		},
+		{
+			name:   "list strict",
+			args:   []string{"-list", "-strict"},
+			stdin:  "$GOENVSUBST_CLI_MISSING",
+			stdout: "GOENVSUBST_CLI_MISSING\tunset\t<stdin>:1:1\n",
+			stderr: "goenvsubst: unset variables: GOENVSUBST_CLI_MISSING\n",
+			status: 1,
+		},
+		{
+			name:   "missing file",
+			args:   []string{filepath.Join(dir, "missing")},
+			stderr: "goenvsubst: " + filepath.Join(dir, "missing") + ": no such file or directory\n",
+			status: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			status := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if status != tt.status {
+				t.Errorf("run() = %d, want %d; stderr: %s", status, tt.status, stderr.String())
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.stdout)
+			}
+			if stderr.String() != tt.stderr {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+
+	var stderr strings.Builder
+	if status := run([]string{"-unknown"}, strings.NewReader(""), &strings.Builder{}, &stderr); status != 2 {
+		t.Errorf("run() with an unknown flag = %d, want 2", status)
+	}
+	if !strings.Contains(stderr.String(), "usage: goenvsubst") {
+		t.Errorf("stderr = %q, want the usage", stderr.String())
+	}
+}
+
+func TestRunInPlace(t *testing.T) {
+	os.Setenv("GOENVSUBST_CLI_HOST", "db.internal")
+	defer os.Unsetenv("GOENVSUBST_CLI_HOST")
+
+	dir := t.TempDir()
+	write := func(name, content string, perm os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("deploy/a.yaml", "host: $GOENVSUBST_CLI_HOST\n", 0o600)
+	b := write("deploy/prod/b.yaml", "url: http://${GOENVSUBST_CLI_HOST}\n", 0o644)
+	other := write("deploy/prod/c.json", `{"host": "$GOENVSUBST_CLI_HOST"}`, 0o644)
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{"-i", filepath.Join(dir, "deploy/**/*.yaml")}, strings.NewReader(""), &stdout, &stderr); status != 0 {
+		t.Fatalf("run() = %d; stderr: %s", status, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", stdout.String())
+	}
+	for path, want := range map[string]string{
+		a:     "host: db.internal\n",
+		b:     "url: http://db.internal\n",
+		other: `{"host": "$GOENVSUBST_CLI_HOST"}`,
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+	if info, err := os.Stat(a); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("mode of %s = %v, %v, want 0600", a, info.Mode().Perm(), err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "deploy"))
+	if len(entries) != 2 {
+		t.Errorf("deploy has %d entries, want no temporary files left", len(entries))
+	}
+
+	// An error in any file leaves all of them untouched
+	good := write("good.conf", "$GOENVSUBST_CLI_HOST", 0o644)
+	bad := write("bad.conf", "${GOENVSUBST_CLI_MISSING:?must be set}", 0o644)
+	stderr.Reset()
+	if status := run([]string{"-i", good, bad}, strings.NewReader(""), &stdout, &stderr); status != 1 {
+		t.Errorf("run() = %d, want 1", status)
+	}
+	if data, _ := os.ReadFile(good); string(data) != "$GOENVSUBST_CLI_HOST" {
+		t.Errorf("%s = %q, want it unchanged", good, data)
+	}
+
+	if status := run([]string{"-i"}, strings.NewReader(""), &stdout, &stderr); status != 2 {
+		t.Errorf("run() with -i and no files = %d, want 2", status)
+	}
+}
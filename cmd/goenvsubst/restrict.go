@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// formatNames returns the names of the variables referenced in format, the
+// SHELL-FORMAT argument of GNU envsubst, such as '$HOST ${PORT}'
+func formatNames(format string) ([]string, error) {
+	segments, err := goenvsubst.Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, segment := range segments {
+		if segment.Kind == goenvsubst.PlaceholderSegment && !slices.Contains(names, segment.Name) {
+			names = append(names, segment.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no variables in %q", format)
+	}
+	return names, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/internal/astscan"
+)
+
+const scanUsage = `usage: goenvsubst scan [dir|file ...]
+
+Parses the Go source files below the directories, which may also be given
+as dir/..., or the current directory if none are given, and prints every
+variable they reference through goenvsubst, in name order, with the
+file:line:column of each reference. References are found in the string
+literals of the structures passed to Do, DoPtr, DoCopy, DoWithMap,
+DoWithReport and Check, directly or through a variable initialized with a
+composite literal, in the strings passed to Expand and ExpandWith and to
+the Do and Expand methods of an Expander built with New, and in env,
+envsubst and envwhen struct tags. Test files and the vendor and testdata
+directories are skipped.
+
+Flags:
+`
+
+// scan runs the scan subcommand and returns its exit status
+func scan(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("goenvsubst scan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, scanUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	roots := flags.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	var refs []*reference
+	fset := token.NewFileSet()
+	for _, root := range roots {
+		// Directories are always scanned recursively, so ./... means .
+		if root = strings.TrimSuffix(root, "/..."); root == "" || root == "..." {
+			root = "."
+		}
+		files, err := goFiles(root)
+		if err != nil {
+			fmt.Fprintf(stderr, "goenvsubst: %v\n", err)
+			return 1
+		}
+		for _, name := range files {
+			file, err := parser.ParseFile(fset, name, nil, 0)
+			if err != nil {
+				fmt.Fprintf(stderr, "goenvsubst: %v\n", err)
+				return 1
+			}
+			scanFile(&refs, fset, file)
+		}
+	}
+	slices.SortFunc(refs, func(a, b *reference) int { return strings.Compare(a.name, b.name) })
+
+	for _, ref := range refs {
+		fmt.Fprintf(stdout, "%s\t%s\n", ref.name, strings.Join(ref.locations, " "))
+	}
+	return 0
+}
+
+// goFiles returns the Go source files below root, or root itself if it is
+// a file, without test files and the vendor, testdata and hidden
+// directories
+func goFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == root || strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// scanFile adds the variables that file references through goenvsubst to
+// refs
+func scanFile(refs *[]*reference, fset *token.FileSet, file *ast.File) {
+	add := func(at ast.Node, template string) {
+		location := fset.Position(at.Pos())
+		addReferences(refs, template, fmt.Sprintf("%s:%d:%d", location.Filename, location.Line, location.Column))
+	}
+
+	pkg := importName(file)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.StructType:
+			for _, field := range n.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				if tag, err := strconv.Unquote(field.Tag.Value); err == nil {
+					for _, template := range astscan.TagTemplates(reflect.StructTag(tag)) {
+						add(field.Tag, template)
+					}
+				}
+			}
+		case *ast.CallExpr:
+			scanCall(n, pkg, add)
+		}
+		return true
+	})
+}
+
+// importName returns the name file imports the package as, or "" if it
+// does not import it by name
+func importName(file *ast.File) string {
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path != astscan.PkgPath {
+			continue
+		}
+		if spec.Name == nil {
+			return "goenvsubst"
+		}
+		if spec.Name.Name != "_" && spec.Name.Name != "." {
+			return spec.Name.Name
+		}
+	}
+	return ""
+}
+
+// scanCall adds the references in the arguments of call if it substitutes
+// them, through the package imported as pkg or an Expander built with it
+func scanCall(call *ast.CallExpr, pkg string, add func(at ast.Node, template string)) {
+	sel, ok := astscan.UnwrapGeneric(call.Fun).(*ast.SelectorExpr)
+	if !ok || pkg == "" || len(call.Args) == 0 {
+		return
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return
+	}
+	method := x.Name != pkg
+	if method && !isExpander(x, pkg) {
+		return
+	}
+
+	arg := call.Args[0]
+	switch {
+	case method && sel.Sel.Name == astscan.StringMethod || !method && slices.Contains(astscan.StringFuncs, sel.Sel.Name):
+		scanStrings(arg, add)
+	case method && sel.Sel.Name == astscan.StructMethod || !method && slices.Contains(astscan.StructFuncs, sel.Sel.Name):
+		if ident, ok := astscan.Unwrap(arg).(*ast.Ident); ok {
+			for _, value := range initializers(ident) {
+				if lit, ok := astscan.Unwrap(value).(*ast.CompositeLit); ok {
+					scanStrings(lit, add)
+				}
+			}
+			return
+		}
+		if lit, ok := astscan.Unwrap(arg).(*ast.CompositeLit); ok {
+			scanStrings(lit, add)
+		}
+	}
+}
+
+// isExpander reports whether the variable ident is initialized with New of
+// the package imported as pkg
+func isExpander(ident *ast.Ident, pkg string) bool {
+	for _, value := range initializers(ident) {
+		call, ok := value.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "New" {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == pkg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// initializers returns the values the variable ident is declared with, as
+// resolved by the parser within its file
+func initializers(ident *ast.Ident) []ast.Expr {
+	if ident.Obj == nil {
+		return nil
+	}
+	var values []ast.Expr
+	if decl, ok := ident.Obj.Decl.(ast.Node); ok {
+		astscan.Assignments(decl, func(name *ast.Ident, value ast.Expr) {
+			if name.Name == ident.Name {
+				values = append(values, value)
+			}
+		})
+	}
+	return values
+}
+
+// scanStrings adds the references in every string literal inside n
+func scanStrings(n ast.Node, add func(at ast.Node, template string)) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				add(lit, s)
+			}
+		}
+		return true
+	})
+}
+
+// addReferences adds the variables referenced by template, including those
+// in the arguments of operators, to refs with location
+func addReferences(refs *[]*reference, template, location string) {
+	placeholders, err := goenvsubst.LenientShellSyntax.Find(template)
+	if err != nil {
+		return
+	}
+	for _, segment := range placeholders {
+		i := slices.IndexFunc(*refs, func(r *reference) bool { return r.name == segment.Name })
+		if i < 0 {
+			*refs = append(*refs, &reference{name: segment.Name})
+			i = len(*refs) - 1
+		}
+		if !slices.Contains((*refs)[i].locations, location) {
+			(*refs)[i].locations = append((*refs)[i].locations, location)
+		}
+		if segment.Arg != "" {
+			addReferences(refs, segment.Arg, location)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	want := `API_URL	testdata/scan/svc/config.go:19:23 testdata/scan/svc/config.go:27:41
+API_VERSION	testdata/scan/svc/config.go:19:23
+APP_ENV	testdata/scan/svc/config.go:10:17
+COPIED	testdata/scan/svc/config.go:23:52
+DB_HOST	testdata/scan/svc/config.go:8:17
+DB_HOSTS	testdata/scan/svc/config.go:11:17
+DB_PORT	testdata/scan/svc/config.go:9:17
+DEBUG_DEFAULT	testdata/scan/svc/config.go:10:17
+EXPANDER_VAR	testdata/scan/svc/config.go:25:27
+`
+	for _, args := range [][]string{{"scan", "testdata/scan"}, {"scan", "testdata/scan/..."}} {
+		var stdout, stderr bytes.Buffer
+		if status := run(args, nil, &stdout, &stderr); status != 0 {
+			t.Fatalf("run(%q) = %d, stderr %q", args, status, stderr.String())
+		}
+		if stdout.String() != want {
+			t.Errorf("run(%q) stdout = %q, want %q", args, stdout.String(), want)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"scan", "testdata/missing"}, nil, &stdout, &stderr); status != 1 {
+		t.Errorf("run() = %d for a missing directory, want 1", status)
+	}
+}
@@ -0,0 +1,30 @@
+package svc
+
+import (
+	envsubst "github.com/iamolegga/goenvsubst"
+)
+
+type Config struct {
+	Host  string   `env:"DB_HOST"`
+	Port  int      `envsubst:"${DB_PORT:-5432}"`
+	Debug bool     `envwhen:"APP_ENV=dev" envsubst:"default=$DEBUG_DEFAULT"`
+	Hosts []string `envsubst:"${DB_HOSTS:-a,b},split"`
+	Plain string
+	Other string `env:"OTHER,required"`
+}
+
+var expander = envsubst.New(envsubst.Strict())
+
+func Load() error {
+	cfg := Config{Plain: "$API_URL/${API_VERSION:-v1}"}
+	if err := envsubst.Do(&cfg); err != nil {
+		return err
+	}
+	copied, err := envsubst.DoCopy[[]string]([]string{"$COPIED"})
+	_, _ = copied, err
+	_, err = expander.Expand("${EXPANDER_VAR}")
+	_ = fmtLike("$NOT_SUBSTITUTED")
+	return expander.Do(&struct{ A string }{"${API_URL}"})
+}
+
+func fmtLike(s string) string { return s }
@@ -0,0 +1,5 @@
+package svc
+
+import "github.com/iamolegga/goenvsubst"
+
+var _ = goenvsubst.Expand("$TEST_ONLY")
@@ -0,0 +1,5 @@
+package skipped
+
+import "github.com/iamolegga/goenvsubst"
+
+var _, _ = goenvsubst.Expand("$SKIPPED")
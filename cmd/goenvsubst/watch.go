@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+const watchUsage = `usage: goenvsubst watch -src dir -dst dir [flags]
+
+Renders every file below -src to the same path below -dst, then renders
+them again whenever a file below -src or an -env-file changes, until
+interrupted. Errors are reported and watching goes on, so a broken template
+can be fixed while it is watched. Only files whose result changed are
+written.
+
+Flags:
+`
+
+// watch runs the watch subcommand until ctx is done and returns its exit
+// status
+func watch(ctx context.Context, args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("goenvsubst watch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, watchUsage)
+		flags.PrintDefaults()
+	}
+	src := flags.String("src", "", "render the templates below `dir`")
+	dst := flags.String("dst", "", "write the results below `dir`")
+	interval := flags.Duration("interval", time.Second/2, "check for changes every `interval`")
+	strict := flags.Bool("strict", false, "fail if a referenced variable is not set")
+	keepUnset := flags.Bool("keep-unset", false, "leave references to unset variables as written")
+	var envFiles []string
+	flags.Func("env-file", "read variables the environment does not set from a .env `file`, and watch it; may be repeated", func(path string) error {
+		envFiles = append(envFiles, path)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *src == "" || *dst == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	if within(*dst, *src) {
+		fmt.Fprintln(stderr, "goenvsubst: watch: -dst may not be inside -src")
+		return 2
+	}
+
+	opts := []goenvsubst.Option{goenvsubst.WithSyntax(goenvsubst.LenientShellSyntax)}
+	if *strict {
+		opts = append(opts, goenvsubst.Strict())
+	}
+	if *keepUnset {
+		opts = append(opts, goenvsubst.WithKeepUnset())
+	}
+	if len(envFiles) > 0 {
+		opts = append(opts, goenvsubst.WithDotenv(envFiles...))
+	}
+
+	last := ""
+	for {
+		state, err := fingerprint(*src, envFiles)
+		if err != nil {
+			state = err.Error()
+		}
+		if state != last {
+			last = state
+			if err == nil {
+				// A new Expander reads the dotenv files again
+				err = render(goenvsubst.New(opts...), *src, *dst)
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "goenvsubst: %v\n", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// fingerprint describes the files below src and the env files by their
+// names, sizes and modification times, so that it changes whenever one of
+// them does
+func fingerprint(src string, envFiles []string) (string, error) {
+	names, err := walkFiles(src, func(string) bool { return true })
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, name := range append(names, envFiles...) {
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// render substitutes the files below src and writes each result to the same
+// path below dst. All files are substituted before any is written, so an
+// error leaves dst as it was.
+func render(expander *goenvsubst.Expander, src, dst string) error {
+	names, err := walkFiles(src, func(string) bool { return true })
+	if err != nil {
+		return err
+	}
+	results := make([]string, len(names))
+	for i, name := range names {
+		if results[i], err = substitute(expander, name, nil); err != nil {
+			return fmt.Errorf("%s: %s", name, message(err))
+		}
+	}
+
+	for i, name := range names {
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		if err := writeResult(name, filepath.Join(dst, rel), results[i]); err != nil {
+			return fmt.Errorf("%s: %s", filepath.Join(dst, rel), message(err))
+		}
+	}
+	return nil
+}
+
+// writeResult writes content to the file target, with the permissions of
+// the template name, unless it already holds content
+func writeResult(name, target, content string) error {
+	if current, err := os.ReadFile(target); err == nil && string(current) == content {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); err == nil {
+		return writeFile(target, content)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, []byte(content), info.Mode().Perm())
+}
+
+// within reports whether the path dir is root or lies below it
+func within(dir, root string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absRoot, absDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "templates")
+	dst := filepath.Join(dir, "out")
+	envFile := filepath.Join(dir, ".env")
+	if err := os.MkdirAll(filepath.Join(src, "nginx"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "nginx", "site.conf"), []byte("listen $GOENVSUBST_WATCH_PORT;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(envFile, []byte("GOENVSUBST_WATCH_PORT=80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stderr bytes.Buffer
+	status := make(chan int)
+	go func() {
+		status <- watch(ctx, []string{"-src", src, "-dst", dst, "-env-file", envFile, "-interval", "10ms"}, &stderr)
+	}()
+
+	out := filepath.Join(dst, "nginx", "site.conf")
+	waitForContent(t, out, "listen 80;\n")
+
+	// Changes to the env file and to the templates are both picked up
+	if err := os.WriteFile(envFile, []byte("GOENVSUBST_WATCH_PORT=8080\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, out, "listen 8080;\n")
+	if err := os.WriteFile(filepath.Join(src, "nginx", "site.conf"), []byte("listen [::]:$GOENVSUBST_WATCH_PORT;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, out, "listen [::]:8080;\n")
+
+	cancel()
+	if got := <-status; got != 0 {
+		t.Errorf("watch() = %d, want 0", got)
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("stderr = %q, want nothing", stderr.String())
+	}
+}
+
+func TestWatchArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"-src", dir},
+		{"-src", dir, "-dst", filepath.Join(dir, "out")},
+		{"-src", dir, "-dst", t.TempDir(), "extra"},
+	} {
+		var stderr bytes.Buffer
+		if got := watch(context.Background(), args, &stderr); got != 2 {
+			t.Errorf("watch(%q) = %d, want 2", args, got)
+		}
+	}
+}
+
+// waitForContent waits until the file name holds want
+func waitForContent(t *testing.T, name, want string) {
+	t.Helper()
+	var got []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if got, _ = os.ReadFile(name); string(got) == want {
+			return
+		}
+	}
+	t.Fatalf("%s = %q, want %q", name, got, want)
+}
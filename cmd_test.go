@@ -0,0 +1,95 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoCmd(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("WORK_DIR", "/tmp")
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("WORK_DIR")
+	}()
+
+	cmd := exec.Command("echo", "$TEST_VAR", "static", "$MISSING_VAR")
+	cmd.Dir = "$WORK_DIR"
+	cmd.Env = []string{"VALUE=$TEST_VAR", "$TEST_VAR=kept", "STATIC=static", "MALFORMED"}
+
+	if err := goenvsubst.DoCmd(cmd); err != nil {
+		t.Fatalf("DoCmd() error = %v", err)
+	}
+
+	if want := []string{"echo", "test_value", "static", ""}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("Args = %q, want %q", cmd.Args, want)
+	}
+	if cmd.Dir != "/tmp" {
+		t.Errorf("Dir = %q, want %q", cmd.Dir, "/tmp")
+	}
+	if want := []string{"VALUE=test_value", "$TEST_VAR=kept", "STATIC=static", "MALFORMED"}; !reflect.DeepEqual(cmd.Env, want) {
+		t.Errorf("Env = %q, want %q", cmd.Env, want)
+	}
+}
+
+func TestDoCmdNil(t *testing.T) {
+	if err := goenvsubst.DoCmd(nil); err != nil {
+		t.Errorf("DoCmd(nil) error = %v", err)
+	}
+}
+
+func TestDoCmdStrict(t *testing.T) {
+	cmd := exec.Command("psql", "$DB_URL")
+	cmd.Dir = "$WORK_DIR"
+	cmd.Env = []string{"PGPASSWORD=$DB_PASSWORD"}
+
+	err := goenvsubst.DoCmd(cmd, goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("DoCmd() error = %v, want *UnsetError", err)
+	}
+	if want := []string{"DB_URL", "WORK_DIR", "DB_PASSWORD"}; !reflect.DeepEqual(unsetErr.Names, want) {
+		t.Errorf("Names = %v, want %v", unsetErr.Names, want)
+	}
+}
+
+func TestCommandResolver(t *testing.T) {
+	resolver := goenvsubst.CommandResolver{Allow: []string{"echo t0k3n", "echo", "false", "sleep ...", "printf %s ..."}, Timeout: 100 * time.Millisecond}
+	opt := goenvsubst.WithScheme("cmd", resolver)
+
+	config := &struct{ Token, Default string }{"Bearer ${cmd:echo t0k3n}", "${cmd:echo:-unused}"}
+	if err := goenvsubst.Do(config, opt); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Token != "Bearer t0k3n" || config.Default != "unused" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if got, err := goenvsubst.Expand("${cmd:printf %s any-arg}", opt); err != nil || got != "any-arg" {
+		t.Errorf("Expand() = %q, %v, want arguments after a ... prefix allowed", got, err)
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"${cmd:cat /etc/passwd}", "command cat /etc/passwd is not allowed"},
+		{"${cmd:echo t0k3n --extra}", "command echo t0k3n --extra is not allowed"},
+		{"${cmd:printf}", "command printf is not allowed"},
+		{"${cmd:false --force}", "command false --force is not allowed"},
+		{"${cmd:false}", "command false: exit status 1"},
+		{"${cmd:sleep 5}", "command sleep timed out after 100ms"},
+	}
+	for _, tt := range tests {
+		_, err := goenvsubst.Expand(tt.template, opt)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expand(%q) error = %v, want %q", tt.template, err, tt.expected)
+		}
+	}
+}
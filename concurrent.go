@@ -0,0 +1,69 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ConcurrentMap is implemented by concurrent containers, such as *sync.Map,
+// whose contents cannot be reached through their fields. When the pointer
+// to a struct implements ConcurrentMap, the struct is processed through
+// these methods instead of field by field: every value listed by Range is
+// processed like a value held by a map[any]any, and values that changed are
+// written back with Store. Other containers can support substitution by
+// implementing the two methods with the semantics of sync.Map.
+type ConcurrentMap interface {
+	Range(f func(key, value any) bool)
+	Store(key, value any)
+}
+
+// concurrentMapType is the reflect.Type of ConcurrentMap
+var concurrentMapType = reflect.TypeFor[ConcurrentMap]()
+
+// concurrentMap returns the ConcurrentMap implemented by the pointer to the
+// struct v, if any
+func concurrentMap(v reflect.Value) (ConcurrentMap, bool) {
+	if !v.CanAddr() || !v.Addr().Type().Implements(concurrentMapType) {
+		return nil, false
+	}
+	return v.Addr().Interface().(ConcurrentMap), true
+}
+
+// doConcurrentMap processes the values of m. The entries are collected
+// before any is processed and visited in the order of their keys' text,
+// like the keys of regular maps, and only changed values are stored, so
+// entries other goroutines write meanwhile are kept unless their previous
+// values needed substitution.
+func (w *walker) doConcurrentMap(m ConcurrentMap, path string) error {
+	if err := w.enter(path); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	type entry struct{ key, value any }
+	var entries []entry
+	m.Range(func(key, value any) bool {
+		entries = append(entries, entry{key, value})
+		return true
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return fmt.Sprint(entries[i].key) < fmt.Sprint(entries[j].key)
+	})
+
+	for _, e := range entries {
+		// Process the value as held by a settable interface, so values that
+		// cannot be modified in place are copied and replaced
+		holder := reflect.New(reflect.TypeFor[any]()).Elem()
+		if e.value != nil {
+			holder.Set(reflect.ValueOf(e.value))
+		}
+		if err := w.doInterface(holder, keyPath(path, e.key)); err != nil {
+			return err
+		}
+		if !w.checking() && !reflect.DeepEqual(holder.Interface(), e.value) {
+			m.Store(e.key, holder.Interface())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package goenvsubst_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func syncMapContents(m *sync.Map) map[any]any {
+	contents := map[any]any{}
+	m.Range(func(key, value any) bool {
+		contents[key] = value
+		return true
+	})
+	return contents
+}
+
+func TestDoSyncMap(t *testing.T) {
+	vars := goenvsubst.MapResolver{"HOST": "db.internal", "PORT": "5432"}
+	type endpoint struct{ URL string }
+
+	var config struct {
+		Cache sync.Map
+		Peers *sync.Map
+	}
+	shared := &endpoint{"http://$HOST"}
+	config.Cache.Store("host", "$HOST")
+	config.Cache.Store("endpoint", endpoint{"http://$HOST:$PORT"})
+	config.Cache.Store("shared", shared)
+	config.Cache.Store("ports", []string{"$PORT"})
+	config.Cache.Store("count", 3)
+	config.Cache.Store("none", nil)
+	config.Peers = &sync.Map{}
+	config.Peers.Store(1, "$HOST")
+
+	if err := goenvsubst.Do(&config, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := map[any]any{
+		"host":     "db.internal",
+		"endpoint": endpoint{"http://db.internal:5432"},
+		"shared":   shared,
+		"ports":    []string{"5432"},
+		"count":    3,
+		"none":     nil,
+	}
+	if got := syncMapContents(&config.Cache); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Do() = %v, want %v", got, expected)
+	}
+	if shared.URL != "http://db.internal" {
+		t.Errorf("pointer value = %q, want it substituted in place", shared.URL)
+	}
+	if got, _ := config.Peers.Load(1); got != "db.internal" {
+		t.Errorf("Do() through a pointer = %v, want %q", got, "db.internal")
+	}
+
+	// Check leaves the map as is and names entries by key
+	var pending sync.Map
+	pending.Store("url", "$MISSING_VAR")
+	issues, err := goenvsubst.Check(&pending)
+	if err != nil || len(issues) != 1 || issues[0].Path != "[url]" {
+		t.Errorf("Check() = %v, %v, want one issue at [url]", issues, err)
+	}
+	if got, _ := pending.Load("url"); got != "$MISSING_VAR" {
+		t.Errorf("Check() modified the map: %v", got)
+	}
+}
+
+// lockedMap is a concurrent container other than sync.Map
+type lockedMap struct {
+	mu     sync.Mutex
+	values map[string]string
+	stores int
+}
+
+func (m *lockedMap) Range(f func(key, value any) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, value := range m.values {
+		if !f(key, value) {
+			return
+		}
+	}
+}
+
+func (m *lockedMap) Store(key, value any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key.(string)] = value.(string)
+	m.stores++
+}
+
+func TestDoConcurrentMap(t *testing.T) {
+	m := &lockedMap{values: map[string]string{"url": "http://$HOST", "literal": "as is"}}
+	if err := goenvsubst.Do(m, goenvsubst.WithLookup(func(string) (string, bool) {
+		return "db.internal", true
+	})); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if m.values["url"] != "http://db.internal" || m.values["literal"] != "as is" {
+		t.Errorf("Do() = %v", m.values)
+	}
+	if m.stores != 1 {
+		t.Errorf("Do() stored %d values, want only the changed one", m.stores)
+	}
+}
+
+func TestDoCopySyncMap(t *testing.T) {
+	var template struct{ Cache sync.Map }
+	template.Cache.Store("hosts", []string{"$HOST"})
+
+	copied, err := goenvsubst.DoCopy(&template, goenvsubst.WithLookup(func(string) (string, bool) {
+		return "db.internal", true
+	}))
+	if err != nil {
+		t.Fatalf("DoCopy() error = %v", err)
+	}
+	if got, _ := copied.Cache.Load("hosts"); !reflect.DeepEqual(got, []string{"db.internal"}) {
+		t.Errorf("DoCopy() = %v", got)
+	}
+	if got, _ := template.Cache.Load("hosts"); !reflect.DeepEqual(got, []string{"$HOST"}) {
+		t.Errorf("DoCopy() modified the template: %v", got)
+	}
+}
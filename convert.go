@@ -0,0 +1,190 @@
+package goenvsubst
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// converters parse resolved strings into types that cannot hold a placeholder
+// themselves, keyed by the target type
+var converters = map[reflect.Type]func(s string) (any, error){
+	reflect.TypeFor[net.IP](): func(s string) (any, error) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: s}
+		}
+		return ip, nil
+	},
+	reflect.TypeFor[net.IPNet](): func(s string) (any, error) {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		return *ipNet, nil
+	},
+	reflect.TypeFor[netip.Addr](): func(s string) (any, error) {
+		return netip.ParseAddr(s)
+	},
+	reflect.TypeFor[netip.AddrPort](): func(s string) (any, error) {
+		return netip.ParseAddrPort(s)
+	},
+	reflect.TypeFor[netip.Prefix](): func(s string) (any, error) {
+		return netip.ParsePrefix(s)
+	},
+	reflect.TypeFor[url.URL](): func(s string) (any, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return *u, nil
+	},
+	reflect.TypeFor[time.Duration](): func(s string) (any, error) {
+		return time.ParseDuration(s)
+	},
+	reflect.TypeFor[slog.Level](): func(s string) (any, error) {
+		var level slog.Level
+		err := level.UnmarshalText([]byte(s))
+		return level, err
+	},
+	reflect.TypeFor[time.Month](): func(s string) (any, error) {
+		n, err := parseEnum(s, 1, 12, func(i int) string { return time.Month(i).String() })
+		return time.Month(n), err
+	},
+	reflect.TypeFor[time.Weekday](): func(s string) (any, error) {
+		n, err := parseEnum(s, 0, 6, func(i int) string { return time.Weekday(i).String() })
+		return time.Weekday(n), err
+	},
+}
+
+// parseEnum parses a value of an enum whose members are numbered from min to
+// max and named by name. It accepts the number, the full name or its first
+// three letters, case-insensitively.
+func parseEnum(s string, min, max int, name func(int) string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < min || n > max {
+			return 0, fmt.Errorf("%d is out of range %d..%d", n, min, max)
+		}
+		return n, nil
+	}
+	for i := min; i <= max; i++ {
+		full := name(i)
+		if strings.EqualFold(s, full) || strings.EqualFold(s, full[:3]) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown value %q", s)
+}
+
+// inferType returns s as a bool, int or float64 if it is written as one, the
+// way JSON would have decoded it, and s itself otherwise
+func inferType(s string) any {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	return s
+}
+
+// doSource expands the template given in a field's tag, or the variable
+// named by its env tag, and assigns the result to the field, converting it
+// to the field's type. An empty result leaves the field unchanged.
+func (w *walker) doSource(v reflect.Value, tag fieldTag, path string) error {
+	expanded, err := w.expandSource(tag, path)
+	if err != nil || expanded == "" {
+		return err
+	}
+	if w.checking() {
+		// Convert into a scratch value to find values of the wrong type
+		v = reflect.New(v.Type()).Elem()
+	}
+	return setString(v, expanded, path)
+}
+
+// expandSource expands the template given in a field's tag, or the variable
+// named by its env tag
+func (w *walker) expandSource(tag fieldTag, path string) (string, error) {
+	if tag.env != "" {
+		return w.expandVariable(tag.env, path)
+	}
+	return w.expand(tag.source, path)
+}
+
+// setString assigns s to v, allocating pointers and converting s to the
+// type of v when it is not a string kind. Types without a converter that
+// implement encoding.TextUnmarshaler parse s themselves.
+func setString(v reflect.Value, s, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if convert, ok := converters[v.Type()]; ok {
+		converted, err := convert(s)
+		if err != nil {
+			return fmt.Errorf("goenvsubst: field %s: parse %s: %w", path, v.Type(), err)
+		}
+		v.Set(reflect.ValueOf(converted))
+		return nil
+	}
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("goenvsubst: field %s: parse %s: %w", path, v.Type(), err)
+		}
+		return nil
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 10, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(s, 10, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("goenvsubst: field %s: parse %s: %w", path, v.Type(), err)
+	}
+
+	return fmt.Errorf("goenvsubst: field %s: cannot assign a resolved value to %s", path, v.Type())
+}
@@ -0,0 +1,362 @@
+package goenvsubst_test
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoTagSourceNetip(t *testing.T) {
+	os.Setenv("LISTEN_IP", "10.0.0.1")
+	os.Setenv("LISTEN_ADDR", "[::1]:8080")
+	os.Setenv("ALLOWED_NET", "192.168.0.0/16")
+	os.Setenv("HOST_NAME", "example.com")
+	defer func() {
+		os.Unsetenv("LISTEN_IP")
+		os.Unsetenv("LISTEN_ADDR")
+		os.Unsetenv("ALLOWED_NET")
+		os.Unsetenv("HOST_NAME")
+	}()
+
+	config := &struct {
+		IP       netip.Addr      `envsubst:"$LISTEN_IP"`
+		Listen   netip.AddrPort  `envsubst:"$LISTEN_ADDR"`
+		Allowed  netip.Prefix    `envsubst:"$ALLOWED_NET"`
+		Optional *netip.AddrPort `envsubst:"$LISTEN_ADDR"`
+		Missing  *netip.Addr     `envsubst:"$MISSING_VAR"`
+		Host     string          `envsubst:"$HOST_NAME"`
+	}{}
+
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if want := netip.MustParseAddr("10.0.0.1"); config.IP != want {
+		t.Errorf("IP = %v, want %v", config.IP, want)
+	}
+	if want := netip.MustParseAddrPort("[::1]:8080"); config.Listen != want {
+		t.Errorf("Listen = %v, want %v", config.Listen, want)
+	}
+	if want := netip.MustParsePrefix("192.168.0.0/16"); config.Allowed != want {
+		t.Errorf("Allowed = %v, want %v", config.Allowed, want)
+	}
+	if config.Optional == nil || *config.Optional != netip.MustParseAddrPort("[::1]:8080") {
+		t.Errorf("Optional = %v", config.Optional)
+	}
+	if config.Missing != nil {
+		t.Errorf("Missing = %v, want nil", config.Missing)
+	}
+	if config.Host != "example.com" {
+		t.Errorf("Host = %q, want %q", config.Host, "example.com")
+	}
+}
+
+func TestDoTagSourceNet(t *testing.T) {
+	os.Setenv("LISTEN_IP", "10.0.0.1")
+	os.Setenv("ALLOWED_NET", "192.168.0.0/16")
+	defer func() {
+		os.Unsetenv("LISTEN_IP")
+		os.Unsetenv("ALLOWED_NET")
+	}()
+
+	config := &struct {
+		IP       net.IP     `env:"LISTEN_IP"`
+		Optional *net.IP    `env:"LISTEN_IP"`
+		Allowed  net.IPNet  `env:"ALLOWED_NET"`
+		Network  *net.IPNet `envsubst:"${ALLOWED_NET}"`
+	}{}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if !config.IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("IP = %v, want 10.0.0.1", config.IP)
+	}
+	if config.Optional == nil || !config.Optional.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Optional = %v, want 10.0.0.1", config.Optional)
+	}
+	if config.Allowed.String() != "192.168.0.0/16" {
+		t.Errorf("Allowed = %v, want 192.168.0.0/16", &config.Allowed)
+	}
+	if config.Network == nil || config.Network.String() != "192.168.0.0/16" {
+		t.Errorf("Network = %v, want 192.168.0.0/16", config.Network)
+	}
+
+	os.Setenv("LISTEN_IP", "10.0.0.256")
+	os.Setenv("ALLOWED_NET", "10.0.0.0/33")
+	invalid := &struct {
+		IP      net.IP    `env:"LISTEN_IP"`
+		Allowed net.IPNet `env:"ALLOWED_NET"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	for _, want := range []string{"field IP", "10.0.0.256"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Do() error = %v, want it to contain %q", err, want)
+		}
+	}
+	os.Setenv("LISTEN_IP", "10.0.0.1")
+	err = goenvsubst.Do(invalid)
+	for _, want := range []string{"field Allowed", "10.0.0.0/33"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Do() error = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestDoTagSourceErrors(t *testing.T) {
+	os.Setenv("BAD_IP", "999.0.0.1")
+	defer os.Unsetenv("BAD_IP")
+
+	invalid := &struct {
+		IP netip.Addr `envsubst:"$BAD_IP"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "IP") || !strings.Contains(err.Error(), "999.0.0.1") {
+		t.Errorf("Do() error = %v, want a parse error naming the field and value", err)
+	}
+
+	unsupported := &struct {
+		Value chan int `envsubst:"$BAD_IP"`
+	}{}
+	if err := goenvsubst.Do(unsupported); err == nil {
+		t.Error("Do() expected error for an unsupported field type")
+	}
+}
+
+func TestDoTagSourceURL(t *testing.T) {
+	os.Setenv("API_URL", "https://user@api.example.com:8443/v1?debug=1")
+	defer os.Unsetenv("API_URL")
+
+	config := &struct {
+		API      url.URL  `env:"API_URL"`
+		Optional *url.URL `envsubst:"${API_URL}"`
+		Missing  *url.URL `env:"MISSING_VAR"`
+	}{}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if config.API.Host != "api.example.com:8443" || config.API.Path != "/v1" || config.API.User.Username() != "user" {
+		t.Errorf("API = %#v", config.API)
+	}
+	if config.Optional == nil || config.Optional.String() != "https://user@api.example.com:8443/v1?debug=1" {
+		t.Errorf("Optional = %v", config.Optional)
+	}
+	if config.Missing != nil {
+		t.Errorf("Missing = %v, want nil", config.Missing)
+	}
+
+	os.Setenv("API_URL", "http://[::1:80")
+	invalid := &struct {
+		API *url.URL `env:"API_URL"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "field API") || !strings.Contains(err.Error(), "url.URL") {
+		t.Errorf("Do() error = %v, want a parse error naming the field and type", err)
+	}
+}
+
+func TestDoTagSourceEnums(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "warn")
+	os.Setenv("DEBUG_LEVEL", "DEBUG+2")
+	os.Setenv("BILLING_MONTH", "mar")
+	os.Setenv("REPORT_DAY", "Friday")
+	os.Setenv("NUMERIC_DAY", "0")
+	defer func() {
+		os.Unsetenv("LOG_LEVEL")
+		os.Unsetenv("DEBUG_LEVEL")
+		os.Unsetenv("BILLING_MONTH")
+		os.Unsetenv("REPORT_DAY")
+		os.Unsetenv("NUMERIC_DAY")
+	}()
+
+	config := &struct {
+		LogLevel   slog.Level   `envsubst:"$LOG_LEVEL"`
+		DebugLevel slog.Level   `envsubst:"$DEBUG_LEVEL"`
+		Month      time.Month   `envsubst:"$BILLING_MONTH"`
+		Day        time.Weekday `envsubst:"$REPORT_DAY"`
+		Numeric    time.Weekday `envsubst:"$NUMERIC_DAY"`
+	}{}
+
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if config.LogLevel != slog.LevelWarn {
+		t.Errorf("LogLevel = %v, want %v", config.LogLevel, slog.LevelWarn)
+	}
+	if config.DebugLevel != slog.LevelDebug+2 {
+		t.Errorf("DebugLevel = %v, want %v", config.DebugLevel, slog.LevelDebug+2)
+	}
+	if config.Month != time.March {
+		t.Errorf("Month = %v, want %v", config.Month, time.March)
+	}
+	if config.Day != time.Friday {
+		t.Errorf("Day = %v, want %v", config.Day, time.Friday)
+	}
+	if config.Numeric != time.Sunday {
+		t.Errorf("Numeric = %v, want %v", config.Numeric, time.Sunday)
+	}
+}
+
+func TestDoTagSourceEnumErrors(t *testing.T) {
+	os.Setenv("BAD_LEVEL", "verbose")
+	os.Setenv("BAD_MONTH", "13")
+	defer func() {
+		os.Unsetenv("BAD_LEVEL")
+		os.Unsetenv("BAD_MONTH")
+	}()
+
+	level := &struct {
+		Level slog.Level `envsubst:"$BAD_LEVEL"`
+	}{}
+	if err := goenvsubst.Do(level); err == nil {
+		t.Error("Do() expected error for an unknown slog level")
+	}
+
+	month := &struct {
+		Month time.Month `envsubst:"$BAD_MONTH"`
+	}{}
+	if err := goenvsubst.Do(month); err == nil {
+		t.Error("Do() expected error for an out-of-range month")
+	}
+}
+
+func TestDoEnvTag(t *testing.T) {
+	os.Setenv("PORT", "8080")
+	os.Setenv("DEBUG", "true")
+	os.Setenv("RATIO", "0.75")
+	os.Setenv("TIMEOUT", "1m30s")
+	os.Setenv("HOST_NAME", "example.com")
+	defer func() {
+		for _, name := range []string{"PORT", "DEBUG", "RATIO", "TIMEOUT", "HOST_NAME"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	config := &struct {
+		Port      int           `env:"PORT"`
+		Workers   uint8         `env:"PORT_WORKERS" envsubst:"default=4"`
+		Debug     bool          `env:"DEBUG"`
+		Ratio     float64       `env:"RATIO"`
+		Timeout   time.Duration `env:"TIMEOUT"`
+		Host      *string       `env:"HOST_NAME"`
+		Untouched int           `env:"MISSING_VAR"`
+	}{Untouched: 42}
+
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if config.Port != 8080 || config.Workers != 4 || !config.Debug || config.Ratio != 0.75 {
+		t.Errorf("Do() = %+v", config)
+	}
+	if config.Timeout != 90*time.Second {
+		t.Errorf("Timeout = %v, want %v", config.Timeout, 90*time.Second)
+	}
+	if config.Host == nil || *config.Host != "example.com" {
+		t.Errorf("Host = %v, want example.com", config.Host)
+	}
+	if config.Untouched != 42 {
+		t.Errorf("Untouched = %d, want 42", config.Untouched)
+	}
+}
+
+func TestDoEnvTagErrors(t *testing.T) {
+	os.Setenv("PORT", "99999")
+	defer os.Unsetenv("PORT")
+
+	overflow := &struct {
+		Port int16 `env:"PORT"`
+	}{}
+	err := goenvsubst.Do(overflow)
+	if err == nil || !strings.Contains(err.Error(), "field Port") || !strings.Contains(err.Error(), "int16") {
+		t.Errorf("Do() error = %v, want a parse error naming the field and type", err)
+	}
+
+	// env tags of other packages, such as caarlos0/env, are left to them
+	foreign := &struct {
+		Port int `env:"PORT,required"`
+		Host int `env:"$PORT"`
+	}{}
+	if err := goenvsubst.Do(foreign); err != nil || foreign.Port != 0 || foreign.Host != 0 {
+		t.Errorf("Do() = %+v, %v, want env tags that are not a variable name ignored", *foreign, err)
+	}
+
+	conflicting := &struct {
+		Port int `env:"PORT" envsubst:"$OTHER_PORT"`
+	}{}
+	if err := goenvsubst.Do(conflicting); err == nil {
+		t.Error("Do() expected error for an env tag combined with a template")
+	}
+}
+
+// nodeID is a custom type that validates its text form
+type nodeID struct{ region, name string }
+
+func (id *nodeID) UnmarshalText(text []byte) error {
+	region, name, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("node ID %q must look like region/name", text)
+	}
+	*id = nodeID{region, name}
+	return nil
+}
+
+// upperName is a string kind whose UnmarshalText normalizes the value
+type upperName string
+
+func (n *upperName) UnmarshalText(text []byte) error {
+	*n = upperName(strings.ToUpper(string(text)))
+	return nil
+}
+
+func TestDoTagSourceTextUnmarshaler(t *testing.T) {
+	os.Setenv("NODE_ID", "eu-west/node-1")
+	os.Setenv("NODE_NAME", "primary")
+	defer func() {
+		os.Unsetenv("NODE_ID")
+		os.Unsetenv("NODE_NAME")
+	}()
+
+	config := &struct {
+		ID       nodeID     `envsubst:"$NODE_ID"`
+		Optional *nodeID    `env:"NODE_ID"`
+		Name     upperName  `env:"NODE_NAME"`
+		Level    slog.Level `envsubst:"${MISSING_VAR:-warn}"`
+	}{}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	want := nodeID{"eu-west", "node-1"}
+	if config.ID != want {
+		t.Errorf("ID = %+v, want %+v", config.ID, want)
+	}
+	if config.Optional == nil || *config.Optional != want {
+		t.Errorf("Optional = %+v, want %+v", config.Optional, want)
+	}
+	if config.Name != "PRIMARY" {
+		t.Errorf("Name = %q, want %q", config.Name, "PRIMARY")
+	}
+	if config.Level != slog.LevelWarn {
+		t.Errorf("Level = %v, want %v", config.Level, slog.LevelWarn)
+	}
+
+	os.Setenv("NODE_ID", "invalid")
+	invalid := &struct {
+		ID nodeID `env:"NODE_ID"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "ID") || !strings.Contains(err.Error(), "region/name") {
+		t.Errorf("Do() error = %v, want the UnmarshalText error naming the field", err)
+	}
+}
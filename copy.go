@@ -0,0 +1,128 @@
+package goenvsubst
+
+import "reflect"
+
+// DoCopy is like Do but substitutes into a deep copy of v and returns it,
+// leaving v intact, so one template can be expanded repeatedly with
+// different variable sets. Pointers, slices, maps and interfaces are copied
+// recursively; unexported fields, functions and channels are shared with v.
+// On error DoCopy returns the zero value of T.
+func DoCopy[T any](v T, opts ...Option) (T, error) {
+	copied := reflect.ValueOf(&v).Elem()
+	copied.Set((&copier{seen: map[copyKey]reflect.Value{}}).copy(copied))
+
+	if err := New(opts...).walker().do(copied); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// copier makes deep copies of values, preserving pointers that are shared or
+// form cycles
+type copier struct {
+	seen map[copyKey]reflect.Value
+}
+
+// copyKey identifies a pointer already copied
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// copy returns a deep copy of v
+func (c *copier) copy(v reflect.Value) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+	c.copyInto(out, v)
+	return out
+}
+
+// copyInto stores a deep copy of src in the settable value dst
+func (c *copier) copyInto(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{ptr: src.Pointer(), typ: src.Type()}
+		if p, ok := c.seen[key]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c.seen[key] = p
+		c.copyInto(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if !src.IsNil() {
+			dst.Set(c.copy(src.Elem()))
+		}
+	case reflect.Struct:
+		if m, ok := concurrentMap(dst); ok && dst.CanSet() {
+			c.copyConcurrentMap(dst, m, src)
+			return
+		}
+		// Unexported fields cannot be set through reflection and stay
+		// shared, except for the exported fields of embedded structs,
+		// which WithUnexportedEmbeds substitutes
+		if dst.CanSet() {
+			dst.Set(src)
+		}
+		for i := 0; i < src.NumField(); i++ {
+			switch {
+			case dst.Field(i).CanSet():
+				c.copyInto(dst.Field(i), src.Field(i))
+			case src.Type().Field(i).Anonymous && src.Field(i).Kind() == reflect.Struct:
+				c.copyInto(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			c.copyInto(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copyInto(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(c.copy(iter.Key()), c.copy(iter.Value()))
+		}
+		dst.Set(m)
+	default:
+		dst.Set(src)
+	}
+}
+
+// copyConcurrentMap stores deep copies of the entries of the container src
+// in the container m, which is the settable value dst
+func (c *copier) copyConcurrentMap(dst reflect.Value, m ConcurrentMap, src reflect.Value) {
+	if !src.CanAddr() {
+		// Only the pointer has the methods; a shallow copy reads the same
+		// entries
+		addressable := reflect.New(src.Type()).Elem()
+		addressable.Set(src)
+		src = addressable
+	}
+	source, _ := concurrentMap(src)
+
+	// The fields of dst may still share the state of src
+	dst.SetZero()
+	source.Range(func(key, value any) bool {
+		if value != nil {
+			value = c.copy(reflect.ValueOf(value)).Interface()
+		}
+		m.Store(key, value)
+		return true
+	})
+}
@@ -0,0 +1,98 @@
+package goenvsubst_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoCopy(t *testing.T) {
+	type Database struct {
+		URL      string
+		Replicas []string
+	}
+	type Config struct {
+		Database *Database
+		Primary  *Database
+		Labels   map[string]string
+		Extra    any
+		Ports    [2]string
+	}
+
+	db := &Database{URL: "postgres://$DB_HOST/app", Replicas: []string{"$DB_HOST"}}
+	template := Config{
+		Database: db,
+		Primary:  db,
+		Labels:   map[string]string{"region": "$REGION"},
+		Extra:    map[string]any{"tenant": "$TENANT"},
+		Ports:    [2]string{"$PORT", "9090"},
+	}
+
+	tenants := []map[string]string{
+		{"DB_HOST": "db-a", "REGION": "eu", "TENANT": "a", "PORT": "8080"},
+		{"DB_HOST": "db-b", "REGION": "us", "TENANT": "b", "PORT": "8081"},
+	}
+	for _, vars := range tenants {
+		got, err := goenvsubst.DoCopy(template, goenvsubst.WithResolver(goenvsubst.MapResolver(vars)))
+		if err != nil {
+			t.Fatalf("DoCopy() error = %v", err)
+		}
+
+		expected := Config{
+			Database: &Database{URL: "postgres://" + vars["DB_HOST"] + "/app", Replicas: []string{vars["DB_HOST"]}},
+			Labels:   map[string]string{"region": vars["REGION"]},
+			Extra:    map[string]any{"tenant": vars["TENANT"]},
+			Ports:    [2]string{vars["PORT"], "9090"},
+		}
+		expected.Primary = expected.Database
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("DoCopy() = %+v, want %+v", got, expected)
+		}
+		if got.Database != got.Primary {
+			t.Error("DoCopy() did not preserve a shared pointer")
+		}
+	}
+
+	if db.URL != "postgres://$DB_HOST/app" || db.Replicas[0] != "$DB_HOST" || template.Labels["region"] != "$REGION" ||
+		template.Extra.(map[string]any)["tenant"] != "$TENANT" || template.Ports[0] != "$PORT" {
+		t.Errorf("DoCopy() modified the template: %+v", template)
+	}
+}
+
+func TestDoCopyPointer(t *testing.T) {
+	template := &struct{ Host string }{"$COPY_HOST"}
+
+	got, err := goenvsubst.DoCopy(template, goenvsubst.WithResolver(goenvsubst.MapResolver{"COPY_HOST": "localhost"}))
+	if err != nil {
+		t.Fatalf("DoCopy() error = %v", err)
+	}
+	if got == template || got.Host != "localhost" || template.Host != "$COPY_HOST" {
+		t.Errorf("DoCopy() = %+v, template = %+v", got, template)
+	}
+}
+
+func TestDoCopyError(t *testing.T) {
+	got, err := goenvsubst.DoCopy([]string{"$COPY_UNSET"}, goenvsubst.Strict(), goenvsubst.WithResolver(goenvsubst.MapResolver{}))
+	if err == nil {
+		t.Fatal("DoCopy() expected error")
+	}
+	if got != nil {
+		t.Errorf("DoCopy() = %v, want nil on error", got)
+	}
+}
+
+func TestDoCopyUnexportedEmbed(t *testing.T) {
+	type hosts struct{ Hosts []string }
+	type config struct{ hosts }
+	original := config{hosts{[]string{"$HOST"}}}
+	copied, err := goenvsubst.DoCopy(original, goenvsubst.WithLookup(func(string) (string, bool) {
+		return "db.internal", true
+	}), goenvsubst.WithUnexportedEmbeds())
+	if err != nil {
+		t.Fatalf("DoCopy() error = %v", err)
+	}
+	if copied.Hosts[0] != "db.internal" || original.Hosts[0] != "$HOST" {
+		t.Errorf("DoCopy() = %q, original %q", copied.Hosts, original.Hosts)
+	}
+}
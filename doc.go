@@ -5,8 +5,36 @@ references in Go data structures with their actual values from the environment.
 The package supports various Go data types including structs, slices, maps, arrays,
 and pointers, both as top-level inputs and nested within other structures.
 
-Environment variables should be referenced in the format $VAR_NAME. If an environment
-variable is not set or is empty, it will be replaced with an empty string.
+Environment variables are referenced as $VAR_NAME or ${VAR_NAME} anywhere in a string,
+as in "$SCHEME://${DB_HOST}:${DB_PORT}/app", and every reference is replaced
+independently, like os.ExpandEnv does. If an environment variable is not set or is
+empty, it will be replaced with an empty string. A $ that does not start a reference is
+kept, $$ is an escaped literal $ (so "$$HOME" becomes "$HOME"), and a malformed
+${...} reference makes Do return a *SyntaxError.
+
+Braced references support the POSIX operators for defaults, required variables
+and alternate values; the colon forms treat a variable that is set but empty as
+unset:
+
+	"${PORT:-8080}"                         // 8080 if PORT is unset or empty
+	"${PORT-8080}"                          // 8080 only if PORT is unset
+	"${JWT_SECRET:?jwt secret must be set}" // Do returns a *RequiredError
+	"${JWT_SECRET?}"                        // the same, only if unset
+	"${TLS_CERT:+--tls}"                    // --tls if TLS_CERT is set and not empty
+
+The case operators of bash change the case of the value:
+
+	"${HOST,,}"                             // db.internal for DB.Internal
+	"${REGION^^}"                           // EU-WEST for eu-west
+	"${NAME^}"                              // only the first letter, as does ${NAME,}
+
+Modifiers, which follow the name, pass the value through a function after
+any operator applies; they chain, and RegisterModifier adds to the built-in
+urlencode, urldecode, b64enc, b64dec, quote, trim, upper and lower:
+
+	"postgres://app:${DB_PASSWORD|urlencode}@db/app"
+	"${TLS_CERT_B64|b64dec}"
+	"${GREETING|trim|quote:-hello}"         // "hello" if GREETING is unset
 
 # Basic Usage
 
@@ -31,6 +59,11 @@ The main function Do() accepts any Go data structure and modifies it in-place:
 	}
 	// config.DatabaseURL is now "postgres://localhost:5432/mydb"
 
+Do returns an error when given a value it cannot modify, such as a struct
+passed by value or a nil pointer; maps and slices can be passed directly.
+DoPtr has the same behavior as Do but only accepts pointers, so that mistake
+fails to compile.
+
 # Struct Fields
 
 Environment variable substitution works with any string field in a struct:
@@ -74,6 +107,10 @@ Map values (but not keys) are processed for environment variable substitution:
 	}
 	goenvsubst.Do(&config)
 
+The values of a sync.Map are processed too, through its Range and Store
+methods, and so are those of any other concurrent container whose pointer
+implements ConcurrentMap. Only values that changed are stored back.
+
 # Nested Structures
 
 The package handles deeply nested structures:
@@ -120,6 +157,133 @@ The package safely handles pointers, including nil pointers:
 	config = &struct{ Value string }{"$MY_VALUE"}
 	goenvsubst.Do(config)
 
+Values stored in interfaces are processed as well, so plugin-style sections
+kept as map[string]Section interfaces and the map[string]any and []any trees
+produced by json.Unmarshal are fully substituted:
+
+	var tree map[string]any
+	json.Unmarshal(data, &tree)
+	goenvsubst.Do(tree)
+
+The WithTypeInference option additionally turns strings held by interfaces
+into int, float64 or bool values when the result looks like one.
+
+# Struct Tags
+
+The envsubst struct tag takes comma-separated options that change how a
+field is processed:
+
+	type Config struct {
+		// Marshaled with MarshalText, expanded, and unmarshaled back with
+		// UnmarshalText, for types that keep templates in unexported fields
+		Token SecretRef `envsubst:"text"`
+
+		// Zero-padded text in a fixed-size array; values that do not fit
+		// are an error
+		Region [4]byte `envsubst:"fixed"`
+
+		// The base64-decoded value of TLS_KEY_B64, for binary secrets
+		TLSKey []byte `env:"TLS_KEY_B64" envsubst:"base64"`
+
+		// Unmarshaled from the JSON held by FEATURE_FLAGS_JSON
+		Flags Flags `env:"FEATURE_FLAGS_JSON" envsubst:"json"`
+
+		// The elements of ALLOWED_ORIGINS=https://a.example,https://b.example;
+		// split=; splits at semicolons instead
+		Origins []string `env:"ALLOWED_ORIGINS" envsubst:"split"`
+
+		// The key=value pairs of LABELS=team=payments,tier=1
+		Labels map[string]string `env:"LABELS" envsubst:"split"`
+
+		// The value of ZONE without surrounding whitespace, in lower
+		// case; upper and quote are available too and apply in order
+		Zone string `env:"ZONE" envsubst:"trim,lower"`
+
+		// Do returns a *RequiredError naming the field and the variable
+		// if a variable referenced here is not set
+		DatabaseURL string `envsubst:"required"`
+
+		// The values of the variables referenced here are kept out of
+		// error messages, as with WithSecretVars
+		Password string `env:"DB_PASSWORD" envsubst:"secret"`
+
+		// "8080" if PORT is unset or empty, as with ${PORT:-8080}; the
+		// default takes the rest of the tag, commas included
+		Port string `envsubst:"default=8080"`
+	}
+
+A tag may start with a placeholder instead, written in the syntax selected
+with WithSyntax. The field is then assigned the expanded placeholder rather
+than having its own content expanded, which lets types that cannot hold a
+placeholder be filled from the environment. Commas inside the placeholder,
+as in ${HOSTS:-a,b}, belong to it. An empty result leaves the field
+unchanged:
+
+	type Server struct {
+		Listen  netip.AddrPort `envsubst:"$LISTEN_ADDR"`
+		Allowed netip.Prefix   `envsubst:"$ALLOWED_NET"`
+	}
+
+String fields accept any value. Booleans, integers, floats, time.Duration,
+url.URL, net.IP, net.IPNet, netip.Addr, netip.AddrPort, netip.Prefix,
+slog.Level, time.Month and time.Weekday are parsed, other types
+implementing encoding.TextUnmarshaler parse the value themselves, and
+values that fail to parse are reported together with the field name:
+
+	type Logging struct {
+		Level slog.Level `envsubst:"$LOG_LEVEL"` // "info", "DEBUG+2", ...
+	}
+
+The env tag is shorthand for a template that is a single variable, and
+combines with the envsubst options. An env tag that is not a bare variable
+name, such as env:"PORT,required" of other packages, is ignored:
+
+	type Server struct {
+		Port    int           `env:"PORT" envsubst:"default=8080"`
+		Timeout time.Duration `env:"TIMEOUT"`
+	}
+
+Unknown options make Do return an error. The tag envsubst:"-" skips a field,
+including everything nested in it, for values that contain dollar signs on
+purpose:
+
+	type Plan struct {
+		Price string `envsubst:"-"` // "$5 per month"
+	}
+
+The envwhen tag limits substitution of a field to certain profiles, named by
+the values of a designated variable. Other profiles leave the field as it is:
+
+	type Config struct {
+		// Only substituted when APP_ENV is production or staging
+		SentryDSN string `envwhen:"APP_ENV=production,staging"`
+	}
+
+The envsource tag binds a field to one of the sources of WithSources,
+bypassing their order of precedence, so that credentials are only ever
+read from a secret store. Options such as WithPrefix given after
+WithSources apply to the bound source as well:
+
+	type Config struct {
+		Password string `envsource:"vault"`
+	}
+
+# Custom Types
+
+RegisterHandler takes over the substitution of a type that the built-in
+handling of kinds cannot reach, such as a wrapper with unexported fields.
+The handler receives the value and a Resolver that looks variables up as
+references do:
+
+	func init() {
+		goenvsubst.RegisterHandler(reflect.TypeFor[SecretRef](), func(v reflect.Value, resolve goenvsubst.Resolver) error {
+			ref := v.Addr().Interface().(*SecretRef)
+			value, _, err := resolve.Resolve(ref.Name())
+			ref.Set(value)
+			return err
+		})
+	}
+
 # Complex Example
 
 A real-world configuration structure:
@@ -172,11 +336,366 @@ A real-world configuration structure:
 		log.Fatal(err)
 	}
 
+# Commands
+
+DoCmd expands the arguments, working directory and environment values of an
+exec.Cmd before it is started:
+
+	cmd := exec.Command("worker", "--db", "$DATABASE_URL")
+	cmd.Env = []string{"TOKEN=$API_TOKEN"}
+	err := goenvsubst.DoCmd(cmd)
+
+# HTTP Requests
+
+DoRequest expands an http.Request template: its URL, header values and
+basic-auth credentials. DoHeader does the same for a bare http.Header:
+
+	req, _ := http.NewRequest("GET", "$API_URL", nil)
+	req.Header.Set("Authorization", "$API_TOKEN")
+	err := goenvsubst.DoRequest(req)
+
+# MessagePack Payloads
+
+DoMsgpack substitutes string values inside an encoded MessagePack payload and
+returns the re-encoded bytes. Map keys, binary data, extensions and all other
+values are copied unchanged:
+
+	out, err := goenvsubst.DoMsgpack(payload)
+
+# Embedded Files
+
+DoFS substitutes the files of an fs.FS, such as one embedded with go:embed,
+that a match function selects, and returns their contents by path:
+
+	files, err := goenvsubst.DoFS(templates, nil)
+
+# JSON Documents
+
+DoJSON substitutes string values inside a raw JSON document and escapes them
+again, so the result stays valid whatever the values contain. Keys and
+non-string values are never substituted, and formatting is kept:
+
+	out, err := goenvsubst.DoJSON(data)
+
+WithJSONPaths restricts substitution to the values selected by JSON
+Pointers, in which * matches any key or index, for documents that embed
+content that must not be expanded:
+
+	out, err := goenvsubst.DoJSON(data, goenvsubst.WithJSONPaths("/spec/env", "/data/*"))
+
+NewJSONDecoder returns a JSONDecoder, which works like json.Decoder but
+substitutes every value before decoding it:
+
+	err := goenvsubst.NewJSONDecoder(r).Decode(&config)
+
+# Single Strings
+
+Expand substitutes a single string, with the same syntax and options as Do,
+and ExpandWith resolves it through a given Resolver:
+
+	format, err := goenvsubst.Expand("${LOG_PREFIX:-app}: %s")
+
+ExpandReader substitutes a stream from an io.Reader into an io.Writer
+without reading it all into memory:
+
+	err := goenvsubst.ExpandReader(out, in)
+
+# Parsing Templates
+
+Parse splits a string into literal and placeholder segments using exactly the
+grammar that Do applies, so external tools can analyze or rewrite templates:
+
+	segments, err := goenvsubst.Parse("$DATABASE_URL")
+	for _, segment := range segments {
+		if segment.Kind == goenvsubst.PlaceholderSegment {
+			fmt.Println(segment.Name) // DATABASE_URL
+		}
+	}
+
+Concatenating the String form of every segment reassembles the template.
+Each segment also records its Start and End Position: the byte offset and the
+1-based line and column, which locate placeholders inside multi-line input
+such as a whole configuration file. Position.Advance moves a position past
+some text, for tools that locate the references of an operator's argument
+within the enclosing file.
+
+# Strict Mode
+
+By default a reference to an unset variable becomes an empty string. With the
+Strict option, Do processes the whole value and then returns an *UnsetError
+listing every variable that was referenced but is not set:
+
+	err := goenvsubst.Do(config, goenvsubst.Strict())
+	// goenvsubst: unset variables: DATABASE_URL (Database.URL), JWT_SECRET (Auth.Secret)
+
+# Options
+
+Settings such as Strict are passed as Option values. DoCmd, DoRequest,
+DoHeader, DoMsgpack and DoJSON accept the same options as Do, so one set of
+options applies to every kind of input:
+
+	opts := []goenvsubst.Option{goenvsubst.Strict()}
+	err := goenvsubst.Do(config, opts...)
+	err = goenvsubst.DoCmd(cmd, opts...)
+
+WithKeepUnset leaves references to unset variables as written, for pipelines
+where a later stage resolves the rest:
+
+	goenvsubst.Do(config, goenvsubst.WithKeepUnset()) // "$MISSING_VAR" is kept
+
+WithRecursion also expands references inside the values of variables, up to
+a given depth, and fails with a *CycleError on variables that refer to each
+other in a cycle:
+
+	// FULL_URL=https://$HOST/$URL_PATH
+	err := goenvsubst.Do(config, goenvsubst.WithRecursion(5))
+
+WithMaxOutputSize limits how large a string may grow by expansion, failing
+with an *OutputSizeError, so templates from untrusted sources cannot
+multiply references into gigabytes:
+
+	err := goenvsubst.Do(config, goenvsubst.WithRecursion(5), goenvsubst.WithMaxOutputSize(1<<20))
+
+WithSecretVars keeps the values of the named variables out of the messages
+of errors and issues, showing the variable's name and a fingerprint of the
+value instead:
+
+	err := goenvsubst.Do(config, goenvsubst.WithSecretVars("DB_PASSWORD", "API_TOKEN"))
+
+WithValidator checks the value every reference expands to in the same pass,
+failing with a *ValidationError at the path of the value:
+
+	err := goenvsubst.Do(config, goenvsubst.WithValidator(func(path, varName, value string) error {
+		if value == "" {
+			return errors.New("must not be empty")
+		}
+		return nil
+	}))
+
+WithURLEscaping percent-encodes the values inserted into the userinfo or a
+query value of a URL, so that a password containing @ or : still yields a
+valid DSN:
+
+	// postgres://app:p%40ss@db/app for the password p@ss
+	config.DSN = "postgres://app:$DB_PASSWORD@db/app"
+	err := goenvsubst.Do(config, goenvsubst.WithURLEscaping())
+
+WithSyntax selects another way of writing references, such as K8sSyntax for
+the $(VAR_NAME) references of Kubernetes manifests:
+
+	err := goenvsubst.Do(&container, goenvsubst.WithSyntax(goenvsubst.K8sSyntax))
+
+Other formats can be supported by implementing the Syntax interface, or by
+converting a function that splits strings into segments to a SegmentSyntax.
+TemplateSyntax treats strings as text/template templates that read
+variables as {{ .VAR_NAME }} or {{ env "VAR_NAME" }}. WindowsSyntax reads
+%VAR_NAME% references, and WithCaseInsensitive matches
+variable names regardless of case, as Windows does. LenientShellSyntax is
+ShellSyntax without syntax errors: a $ that does not start a well-formed
+reference, such as the one of a shell script's ${arr[@]}, is literal text.
+ShellFormatSyntax only recognizes references to the variables it lists,
+like the SHELL-FORMAT argument of GNU envsubst, and leaves the rest of a
+template, such as nginx's $host, as it is. ActionsSyntax reads the
+${{ env.VAR_NAME }} expressions of GitHub Actions workflows and leaves their
+other expressions as they are.
+
+WithMapKeys also expands the keys of maps with string keys, failing without
+renaming any key if two keys would expand to the same one.
+
+Unexported fields cannot be set and are skipped, but embedded structs of
+exported types are processed like any other field, whether embedded by value
+or by pointer. WithUnexportedEmbeds also processes the promoted fields of
+embedded structs whose type is unexported.
+
+WithSkipTypes leaves values of the given types untouched wherever they
+appear, for types whose exported strings are internals rather than
+configuration:
+
+	err := goenvsubst.Do(config, goenvsubst.WithSkipTypes(reflect.TypeFor[vendor.Client]()))
+
+time.Time, big.Int, big.Float, big.Rat, sync.Mutex, sync.RWMutex and
+reflect.Value are always skipped.
+
+Pointers, maps and slices are processed once however often they are
+reachable, so shared values are not substituted twice and cycles, such as
+parent back-references, end. WithMaxDepth limits how deeply containers may
+nest, failing with a *DepthError beyond it.
+
+WithLookup resolves variables from any source instead of the process
+environment, for example test fixtures or a snapshot taken at startup:
+
+	err := goenvsubst.Do(config, goenvsubst.WithLookup(snapshot.Lookup))
+
+WithResolver takes a Resolver, whose lookups may also fail. A ChainResolver
+layers several sources and uses the first one that has the variable:
+
+	resolver := goenvsubst.ChainResolver{
+		goenvsubst.MapResolver(overrides),
+		goenvsubst.EnvResolver{},
+		goenvsubst.MapResolver(defaults),
+	}
+	err := goenvsubst.Do(config, goenvsubst.WithResolver(resolver))
+
+WithDotenv reads .env files and uses their variables for those the
+environment does not set:
+
+	err := goenvsubst.Do(config, goenvsubst.WithDotenv(".env", ".env.local"))
+
+WithSources lists named sources in order of precedence, the first source
+that has a variable supplying it:
+
+	err := goenvsubst.Do(config, goenvsubst.WithSources(
+		goenvsubst.Env(),
+		goenvsubst.Dotenv(".env"),
+		goenvsubst.Source{Name: "defaults", Resolver: goenvsubst.MapResolver(defaults)},
+	))
+
+WithPrefix("MYAPP_") resolves $DB_HOST as MYAPP_DB_HOST, or as DB_HOST if
+that is not set; WithPrefixOnly never falls back to the bare name.
+
+WithProfile("prod") resolves $DB_HOST as DB_HOST_PROD, or as DB_HOST if that
+is not set, and WithProfileFrom("APP_PROFILE") takes the profile from the
+APP_PROFILE variable.
+
+WithScheme resolves references of the form ${scheme:path} with a resolver
+of their own, so that values can come from secret stores and other
+sources besides the environment. The modules under resolvers read AWS
+Secrets Manager (awssm), HashiCorp Vault (vault), Google Cloud Secret
+Manager (gcpsm) and Azure Key Vault (azkeyvault), and the keys of Consul KV
+(consul) and etcd (etcd):
+
+	err := goenvsubst.Do(config, goenvsubst.WithScheme("awssm", awssm.NewFromConfig(awsConfig)))
+	// "${awssm:prod/db#password}" is the password key of the prod/db secret
+
+FileResolver reads secrets mounted as files, such as those of Docker Swarm
+and Kubernetes, as in ${file:/run/secrets/db_password}:
+
+	err := goenvsubst.Do(config, goenvsubst.WithScheme("file", goenvsubst.FileResolver{}))
+
+CommandResolver runs the command lines of its allowlist, arguments
+included, without a shell, and resolves to their output, as in
+${cmd:gcloud auth print-access-token}:
+
+	err := goenvsubst.Do(config, goenvsubst.WithScheme("cmd", goenvsubst.CommandResolver{Allow: []string{"gcloud auth print-access-token"}}))
+
+HTTPResolver fetches values from an HTTP endpoint, with authentication,
+retries and a cache, as in ${http:feature/flags/foo}. Paths cannot leave
+the base URL:
+
+	err := goenvsubst.Do(config, goenvsubst.WithScheme("http", &goenvsubst.HTTPResolver{BaseURL: "https://config.internal/v1/"}))
+
+WithFileFallback reads a variable that is unset from the file named by the
+variable with the suffix _FILE, as the official Docker images do, so
+DB_PASSWORD_FILE=/run/secrets/db_password provides $DB_PASSWORD.
+
+WithAllowlist and WithDenylist restrict the variables that references may
+expand to names matching path.Match globs, for templates from untrusted
+sources; other references make the call fail with a *DeniedError:
+
+	err := goenvsubst.Do(config, goenvsubst.WithAllowlist("APP_*", "PORT"))
+
+DoWithMap is a shortcut that resolves variables from a map:
+
+	err := goenvsubst.DoWithMap(config, tenantVars[tenantID])
+
+DoCopy returns a substituted deep copy and leaves the template it is given
+intact:
+
+	tenantConfig, err := goenvsubst.DoCopy(template, goenvsubst.WithResolver(resolver))
+
+New builds an Expander that applies a fixed set of options and can be reused,
+also concurrently, for any number of values:
+
+	expander := goenvsubst.New(goenvsubst.Strict())
+	err := expander.Do(dbConfig)
+
+The GOENVSUBST environment variable holds comma-separated settings that
+override the options of every Expander, including those built by Do, so
+operators can change policies without changing code: strict=1 or strict=0
+turns Strict on or off, and keepunset=1 or keepunset=0 (or keepmissing) does
+the same for KeepUnset. The variable is read when an Expander is built.
+
+	GOENVSUBST=strict=1,keepmissing=1 ./server
+
+# Reporting Substitutions
+
+DoWithReport also returns a *Report of the variables that were referenced,
+resolved and unset and of the paths of the values that were changed or left
+empty. It never holds values, so it can be logged without exposing secrets:
+
+	report, err := goenvsubst.DoWithReport(config)
+	log.Printf("resolved %v, unset %v", report.Resolved, report.Unset)
+
+Its Sources name the source that supplied each variable, such as "env",
+"dotenv" or the scheme of a reference.
+
+WithOnSubstitute calls a function for every reference substituted, for
+logging, metrics or audits; values of the variables of WithSecretVars are
+passed as fingerprints:
+
+	err := goenvsubst.Do(config, goenvsubst.WithOnSubstitute(func(path, varName, oldValue, newValue string) {
+		log.Printf("%s <- %s", path, oldValue) // Database.URL <- $DATABASE_URL
+	}))
+
+# Validating Templates
+
+Check is a dry run that leaves its input untouched. It returns an Issue for
+every reference to an unset variable and every value Do would fail on:
+
+	issues, err := goenvsubst.Check(config)
+	for _, issue := range issues {
+		fmt.Println(issue) // Database.URL: DB_NAME is not set
+	}
+
+References lists the names of the variables a value refers to, in strings
+and struct tags, without resolving any:
+
+	names, err := goenvsubst.References(config) // [DB_HOST DB_NAME PORT ...]
+
+# Checking the Result
+
+AssertFullyResolved reports any string that still looks like a placeholder
+after substitution, for example because the variable's own value contained
+one. It walks the value as Do does, with the same options, and the
+returned *UnresolvedError lists the offending paths:
+
+	goenvsubst.Do(config)
+	if err := goenvsubst.AssertFullyResolved(config); err != nil {
+		log.Fatal(err) // goenvsubst: unresolved placeholders in Database.URL
+	}
+
+# Redacting Secrets
+
+Redact reverses Do for the variables given to WithSecretVars: it replaces
+every string equal to one of their values with a reference to the variable,
+so the effective configuration can be logged or served on a debug endpoint.
+WithRedactSubstrings also replaces the values within longer strings, which
+suits secrets too long to occur by chance:
+
+	goenvsubst.Redact(&dump, goenvsubst.WithSecretVars("DB_PASSWORD"), goenvsubst.WithRedactSubstrings())
+	log.Printf("config: %+v", dump) // DSN:postgres://admin:$DB_PASSWORD@db
+
+# Exporting Back to the Environment
+
+After substitution, SetEnvFromStruct writes the resolved values back into the
+process environment so child processes that only read the environment see
+the same configuration. EnvFromStruct returns the same variables as a
+KEY=VALUE slice suitable for exec.Cmd.Env. Both walk the value as Do does,
+with the same options, so fields tagged envsubst:"-" are not exported:
+
+	goenvsubst.Do(config)
+
+	// Database.URL is exported as DATABASE_URL
+	err := goenvsubst.SetEnvFromStruct(config, goenvsubst.UpperSnakeCase)
+
+	cmd := exec.Command("legacy-worker")
+	cmd.Env, err = goenvsubst.EnvFromStruct(config, nil)
+
 # Error Handling
 
-The Do function returns an error if there are issues during processing.
-Currently, the function is designed to be robust and typically returns nil,
-but error handling is provided for future extensibility:
+The Do function returns an error for a malformed reference (*SyntaxError), a
+failed ${NAME:?message} check (*RequiredError), unset variables in strict
+mode (*UnsetError) and struct tags that cannot be applied:
 
 	err := goenvsubst.Do(config)
 	if err != nil {
@@ -184,6 +703,16 @@ but error handling is provided for future extensibility:
 		return
 	}
 
+Errors name the offending value by its path, such as Database.Replicas[2].DSN.
+Errors raised while expanding a value are wrapped in a *PathError, which
+unwraps to the underlying error:
+
+	// goenvsubst: Database.Replicas[2].DSN: syntax error at 1:12: unterminated ${
+	var pathErr *goenvsubst.PathError
+	if errors.As(err, &pathErr) {
+		log.Printf("bad value at %s", pathErr.Path)
+	}
+
 # Important Notes
 
 - Only string values are processed for environment variable substitution
@@ -0,0 +1,205 @@
+package goenvsubst
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// WithDotenv layers the variables of the given .env files under the
+// resolver configured so far, by default the process environment: a
+// variable is taken from the files only when the resolver does not have it.
+// Among the files, later ones override earlier ones. Without paths, .env in
+// the working directory is used.
+//
+// The files are read, in the format described at ParseDotenv, when the
+// first variable is resolved; a file that is missing or malformed makes the
+// call fail. Give WithDotenv after WithResolver or WithLookup, since those
+// replace the resolver.
+func WithDotenv(paths ...string) Option {
+	if len(paths) == 0 {
+		paths = []string{".env"}
+	}
+	return func(c *config) {
+		base := c.resolver
+		if base == nil {
+			base = EnvResolver{}
+		}
+		c.resolver = &dotenvResolver{base: base, paths: paths}
+	}
+}
+
+// dotenvResolver resolves variables with base and then from .env files,
+// which it reads once
+type dotenvResolver struct {
+	base  Resolver
+	paths []string
+	once  sync.Once
+	vars  MapResolver
+	err   error
+}
+
+func (d *dotenvResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(d.resolveSource(name))
+}
+
+// resolveSource reports the variables of the files as coming from the
+// source "dotenv"
+func (d *dotenvResolver) resolveSource(name string) (string, bool, string, error) {
+	// Load the files first, so a broken file fails any call that
+	// resolves a variable, not only those that need the files
+	d.once.Do(func() {
+		d.vars, d.err = loadDotenv(d.paths)
+	})
+	if d.err != nil {
+		return "", false, "", d.err
+	}
+	value, ok, source, err := resolveFrom(d.base, name)
+	if err != nil || ok {
+		return value, ok, source, err
+	}
+	value, ok = d.vars[name]
+	if !ok {
+		return "", false, "", nil
+	}
+	return value, true, dotenvSource, nil
+}
+
+// loadDotenv reads and merges the .env files at paths
+func loadDotenv(paths []string) (MapResolver, error) {
+	vars := MapResolver{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseDotenv(string(data), vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return vars, nil
+}
+
+// ParseDotenv reads variables in the .env format: one KEY=VALUE assignment
+// per line, optionally preceded by export, with blank lines and lines
+// starting with # ignored. Unquoted values are trimmed and end at a # that
+// follows whitespace. Values in single quotes are taken literally; values in
+// double quotes may contain the escapes \n, \r, \t, \", \\ and \$. Quoted
+// values may span several lines. Values are not substituted; when a key is
+// assigned more than once, the last assignment wins.
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("goenvsubst: dotenv: %w", err)
+	}
+	vars := map[string]string{}
+	if err := parseDotenv(string(data), vars); err != nil {
+		return nil, fmt.Errorf("goenvsubst: dotenv: %w", err)
+	}
+	return vars, nil
+}
+
+// parseDotenv adds the assignments in src to vars
+func parseDotenv(src string, vars map[string]string) error {
+	line := 1
+	for pos := 0; pos < len(src); {
+		end := lineEnd(src, pos)
+		text := strings.TrimSpace(src[pos:end])
+		if text == "" || text[0] == '#' {
+			pos, line = end+1, line+1
+			continue
+		}
+
+		eq := strings.IndexByte(src[pos:end], '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		key := strings.TrimSpace(src[pos : pos+eq])
+		if rest, ok := strings.CutPrefix(key, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			key = strings.TrimSpace(rest)
+		}
+		if !isName(key) {
+			return fmt.Errorf("line %d: invalid variable name %q", line, key)
+		}
+
+		i := pos + eq + 1
+		for i < end && (src[i] == ' ' || src[i] == '\t') {
+			i++
+		}
+		if i == end || (src[i] != '"' && src[i] != '\'') {
+			vars[key] = unquotedValue(src[i:end])
+			pos, line = end+1, line+1
+			continue
+		}
+
+		value, next, lines, err := quotedValue(src, i)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		end = lineEnd(src, next)
+		if rest := strings.TrimSpace(src[next:end]); rest != "" && rest[0] != '#' {
+			return fmt.Errorf("line %d: unexpected %q after quoted value", line+lines, rest)
+		}
+		vars[key] = value
+		pos, line = end+1, line+lines+1
+	}
+	return nil
+}
+
+// lineEnd returns the index of the newline ending the line that contains
+// pos, or len(src) for the last line
+func lineEnd(src string, pos int) int {
+	if n := strings.IndexByte(src[pos:], '\n'); n >= 0 {
+		return pos + n
+	}
+	return len(src)
+}
+
+// unquotedValue trims s and removes a trailing comment
+func unquotedValue(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// quotedValue reads the quoted value starting at src[start], returning it
+// together with the index after the closing quote and the number of
+// newlines it spans
+func quotedValue(src string, start int) (value string, next, lines int, err error) {
+	quote := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, lines, nil
+		case c == '\\' && quote == '"' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(src[i])
+			default:
+				b.WriteByte('\\')
+				i--
+			}
+			continue
+		case c == '\n':
+			lines++
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, 0, errors.New("unterminated quoted value")
+}
@@ -0,0 +1,98 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestParseDotenv(t *testing.T) {
+	src := `# database settings
+DB_HOST=db.internal
+export DB_PORT = 5432
+DB_NAME=app # trailing comment
+DB_PASS=p#ss
+SINGLE='literal $HOME \n'
+DOUBLE="tab\there \"quoted\" \$HOME"
+MULTI="first line
+second line"
+CERT='-----BEGIN-----
+abc
+-----END-----' # certificate
+EMPTY=
+CRLF=windows` + "\r\n" + `DB_HOST=override
+`
+
+	vars, err := goenvsubst.ParseDotenv(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseDotenv() error = %v", err)
+	}
+	expected := map[string]string{
+		"DB_HOST": "override",
+		"DB_PORT": "5432",
+		"DB_NAME": "app",
+		"DB_PASS": "p#ss",
+		"SINGLE":  `literal $HOME \n`,
+		"DOUBLE":  "tab\there \"quoted\" $HOME",
+		"MULTI":   "first line\nsecond line",
+		"CERT":    "-----BEGIN-----\nabc\n-----END-----",
+		"EMPTY":   "",
+		"CRLF":    "windows",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("ParseDotenv() = %q, want %q", vars, expected)
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"missing assignment", "A=1\nJUST_A_NAME\n", "goenvsubst: dotenv: line 2: expected KEY=VALUE"},
+		{"invalid name", "1ST=x", `goenvsubst: dotenv: line 1: invalid variable name "1ST"`},
+		{"unterminated quote", "A=\"open\nB=2\n", "goenvsubst: dotenv: line 1: unterminated quoted value"},
+		{"text after quote", "A='x'\nB='y\nz' extra", `goenvsubst: dotenv: line 3: unexpected "extra" after quoted value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := goenvsubst.ParseDotenv(strings.NewReader(tt.src))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ParseDotenv() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoWithDotenv(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	os.WriteFile(base, []byte("DOTENV_HOST=from_file\nDOTENV_PORT=5432\nDOTENV_USER=app\n"), 0o600)
+	os.WriteFile(local, []byte("DOTENV_USER=local\n"), 0o600)
+
+	os.Setenv("DOTENV_HOST", "from_env")
+	defer os.Unsetenv("DOTENV_HOST")
+
+	config := &struct{ Host, Port, User string }{"$DOTENV_HOST", "$DOTENV_PORT", "$DOTENV_USER"}
+	if err := goenvsubst.Do(config, goenvsubst.WithDotenv(base, local)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Host != "from_env" || config.Port != "5432" || config.User != "local" {
+		t.Errorf("Do() = %+v, want the environment over the files and later files over earlier ones", config)
+	}
+
+	// A missing file fails the call even if the environment has every
+	// variable
+	err := goenvsubst.Do(&[]string{"$DOTENV_HOST"}, goenvsubst.WithDotenv(filepath.Join(dir, "missing.env")))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Do() error = %v, want fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,135 @@
+package goenvsubst
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RequiredError is returned when a ${NAME:?message} or ${NAME?message}
+// reference meets a missing variable, or when a field tagged required
+// references a variable that is not set.
+type RequiredError struct {
+	// Name is the missing variable.
+	Name string
+	// Message is the expanded message given in the reference.
+	Message string
+	// Field is the path of the struct field tagged required, such as
+	// Database.URL, if the error comes from the tag.
+	Field string
+}
+
+func (e *RequiredError) Error() string {
+	prefix := "goenvsubst: "
+	if e.Field != "" {
+		prefix += "field " + e.Field + ": "
+	}
+	if e.Message == "" {
+		return prefix + e.Name + " is not set"
+	}
+	return prefix + e.Name + ": " + e.Message
+}
+
+// DeniedError is returned when a reference names a variable that
+// WithAllowlist or WithDenylist does not permit.
+type DeniedError struct {
+	// Name is the variable that was referenced.
+	Name string
+}
+
+func (e *DeniedError) Error() string {
+	return "goenvsubst: variable " + e.Name + " is not allowed"
+}
+
+// ValidationError is returned when a validator given to WithValidator
+// rejects the value a reference expanded to.
+type ValidationError struct {
+	// Name is the referenced variable.
+	Name string
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return "goenvsubst: invalid value of " + e.Name + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// UnsetError is returned in strict mode and lists the variables that were
+// referenced but are not set.
+type UnsetError struct {
+	// Names holds the unset variables in the order they were first
+	// referenced, each listed once.
+	Names []string
+	// Paths holds, for every name, the locations of the values that
+	// reference it, such as Database.Replicas[2].DSN.
+	Paths map[string][]string
+}
+
+func (e *UnsetError) Error() string {
+	vars := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		vars[i] = name
+		// The path of a bare string, as given to Expand, says nothing
+		paths := slices.DeleteFunc(slices.Clone(e.Paths[name]), func(path string) bool { return path == rootPath })
+		if len(paths) > 0 {
+			vars[i] += " (" + strings.Join(paths, ", ") + ")"
+		}
+	}
+	return "goenvsubst: unset variables: " + strings.Join(vars, ", ")
+}
+
+// CycleError is returned with WithRecursion when the value of a variable
+// refers back to the variable, directly or through other variables.
+type CycleError struct {
+	// Chain holds the variables in the order their values refer to each
+	// other, starting and ending with the same variable, as in A, B, A.
+	Chain []string
+}
+
+func (e *CycleError) Error() string {
+	return "goenvsubst: variables refer to each other: " + strings.Join(e.Chain, " -> ")
+}
+
+// DepthError is returned, wrapped in a *PathError naming the container, when
+// structs, slices and maps nest deeper than WithMaxDepth allows.
+type DepthError struct {
+	// Max is the configured maximum depth.
+	Max int
+}
+
+func (e *DepthError) Error() string {
+	return "goenvsubst: value nests deeper than " + strconv.Itoa(e.Max) + " levels"
+}
+
+// OutputSizeError is returned when a string expands to more bytes than
+// WithMaxOutputSize allows.
+type OutputSizeError struct {
+	// Max is the configured maximum size in bytes.
+	Max int
+}
+
+func (e *OutputSizeError) Error() string {
+	return "goenvsubst: expansion exceeds the maximum size of " + strconv.Itoa(e.Max) + " bytes"
+}
+
+// PathError records where in the processed value an error occurred.
+type PathError struct {
+	// Path locates the value, such as Database.Replicas[2].DSN; the
+	// outermost value is (root).
+	Path string
+	// Err is the underlying error, such as a *SyntaxError or a
+	// *RequiredError.
+	Err error
+}
+
+func (e *PathError) Error() string {
+	return "goenvsubst: " + e.Path + ": " + strings.TrimPrefix(e.Err.Error(), "goenvsubst: ")
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
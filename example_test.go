@@ -3,6 +3,7 @@ package goenvsubst_test
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/iamolegga/goenvsubst"
 )
@@ -223,3 +224,104 @@ func ExampleDo_array() {
 	// Server 2: server2.example.com
 	// Server 3: server3.example.com
 }
+
+// ExampleEnvFromStruct demonstrates exporting a resolved configuration for a child process
+func ExampleEnvFromStruct() {
+	config := &struct {
+		DatabaseURL string
+		MaxConns    int
+	}{
+		DatabaseURL: "postgres://localhost:5432/mydb",
+		MaxConns:    10,
+	}
+
+	env, err := goenvsubst.EnvFromStruct(config, nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	for _, kv := range env {
+		fmt.Println(kv)
+	}
+
+	// Output:
+	// DATABASE_URL=postgres://localhost:5432/mydb
+	// MAX_CONNS=10
+}
+
+// ExampleParse demonstrates inspecting the placeholders of a template
+func ExampleParse() {
+	segments, err := goenvsubst.Parse("$DATABASE_URL")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	for _, segment := range segments {
+		if segment.Kind == goenvsubst.PlaceholderSegment {
+			fmt.Printf("Variable: %s\n", segment.Name)
+		}
+	}
+
+	// Output:
+	// Variable: DATABASE_URL
+}
+
+// ExampleNew demonstrates reusing one configured Expander
+func ExampleNew() {
+	os.Setenv("DATABASE_URL", "postgres://localhost:5432/mydb")
+	defer os.Unsetenv("DATABASE_URL")
+
+	expander := goenvsubst.New(goenvsubst.Strict())
+
+	db := &struct{ URL string }{"$DATABASE_URL"}
+	if err := expander.Do(db); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Database URL: %s\n", db.URL)
+
+	cache := &struct{ URL string }{"$CACHE_URL"}
+	if err := expander.Do(cache); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	// Output:
+	// Database URL: postgres://localhost:5432/mydb
+	// Error: goenvsubst: unset variables: CACHE_URL (URL)
+}
+
+// ExampleSegmentSyntax demonstrates a custom placeholder format, @{NAME}@
+func ExampleSegmentSyntax() {
+	atSyntax := goenvsubst.SegmentSyntax(func(s string) ([]goenvsubst.Segment, error) {
+		var segments []goenvsubst.Segment
+		for s != "" {
+			start := strings.Index(s, "@{")
+			end := strings.Index(s, "}@")
+			if start < 0 || end < start {
+				break
+			}
+			if start > 0 {
+				segments = append(segments, goenvsubst.Segment{Kind: goenvsubst.LiteralSegment, Literal: s[:start]})
+			}
+			segments = append(segments, goenvsubst.Segment{Kind: goenvsubst.PlaceholderSegment, Name: s[start+2 : end]})
+			s = s[end+2:]
+		}
+		if s != "" {
+			segments = append(segments, goenvsubst.Segment{Kind: goenvsubst.LiteralSegment, Literal: s})
+		}
+		return segments, nil
+	})
+
+	vars := map[string]string{"DB_HOST": "db.internal"}
+	config := &struct{ DSN string }{"postgres://@{DB_HOST}@/app"}
+	if err := goenvsubst.DoWithMap(config, vars, goenvsubst.WithSyntax(atSyntax)); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Println(config.DSN)
+
+	// Output:
+	// postgres://db.internal/app
+}
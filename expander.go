@@ -0,0 +1,85 @@
+package goenvsubst
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// toggleVariable is the environment variable whose settings override the
+// options of every Expander, like GODEBUG does for the standard library
+const toggleVariable = "GOENVSUBST"
+
+// Expander applies a fixed set of options. Build one at startup with New and
+// reuse it for every configuration object; it holds no state between calls
+// and is safe for concurrent use.
+type Expander struct {
+	config config
+}
+
+// New returns an Expander configured by opts.
+//
+// The comma-separated settings of the GOENVSUBST environment variable take
+// precedence over opts, so that operators can change the policies of a
+// deployed binary: strict=1 and strict=0 turn Strict on and off, and
+// keepunset=1 and keepunset=0 (or keepmissing) do the same for KeepUnset.
+// Unknown settings and values other than 0 and 1 are ignored. The variable
+// is read when New is called, so an Expander that is reused keeps the
+// settings it was built with; Do and the other functions build an Expander
+// on every call.
+func New(opts ...Option) *Expander {
+	e := &Expander{}
+	for _, opt := range opts {
+		opt(&e.config)
+	}
+	applyToggles(&e.config, os.Getenv(toggleVariable))
+	return e
+}
+
+// applyToggles applies the settings of the GOENVSUBST variable to c
+func applyToggles(c *config, settings string) {
+	for _, setting := range strings.Split(settings, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		if value != "0" && value != "1" {
+			continue
+		}
+		on := value == "1"
+		switch key {
+		case "strict":
+			c.strict = on
+		case "keepunset", "keepmissing":
+			c.keepUnset = on
+		}
+	}
+}
+
+// Do is like the package-level Do with the Expander's options.
+func (e *Expander) Do(v any) error {
+	return e.walker().do(reflect.ValueOf(v))
+}
+
+// Expand is like the package-level Expand with the Expander's options.
+func (e *Expander) Expand(s string) (string, error) {
+	w := e.walker()
+	expanded, err := w.expandTemplate(s, "")
+	if err != nil {
+		return "", err
+	}
+	if err := w.finish(); err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// Lookup resolves the variable name the way the Expander resolves
+// references to it, with its resolver, prefix and dotenv files, and reports
+// whether it is set. It lets tools tell which referenced variables are
+// missing before substituting anything.
+func (e *Expander) Lookup(name string) (string, bool, error) {
+	return e.walker().resolve(name)
+}
+
+// walker returns a fresh walker for a single call
+func (e *Expander) walker() *walker {
+	return &walker{config: e.config, visited: map[visitKey]bool{}}
+}
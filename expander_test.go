@@ -0,0 +1,91 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestExpander(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	expander := goenvsubst.New(goenvsubst.Strict())
+
+	first := &struct{ A, B string }{"$TEST_VAR", "$MISSING_A"}
+	var unsetErr *goenvsubst.UnsetError
+	if err := expander.Do(first); !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+	if want := []string{"MISSING_A"}; !reflect.DeepEqual(unsetErr.Names, want) {
+		t.Errorf("Names = %v, want %v", unsetErr.Names, want)
+	}
+
+	// Nothing observed by one call carries over to the next
+	second := &[]string{"$TEST_VAR", "$MISSING_B"}
+	if err := expander.Do(second); !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+	if want := []string{"MISSING_B"}; !reflect.DeepEqual(unsetErr.Names, want) {
+		t.Errorf("Names = %v, want %v", unsetErr.Names, want)
+	}
+
+	if err := expander.Do(&[]string{"$TEST_VAR"}); err != nil {
+		t.Errorf("Do() error = %v", err)
+	}
+}
+
+func TestExpanderLookup(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	expander := goenvsubst.New(goenvsubst.WithResolver(goenvsubst.ChainResolver{
+		goenvsubst.EnvResolver{},
+		goenvsubst.MapResolver{"DEFAULTED": "fallback"},
+	}))
+
+	tests := []struct {
+		name      string
+		wantValue string
+		wantOK    bool
+	}{
+		{"TEST_VAR", "test_value", true},
+		{"DEFAULTED", "fallback", true},
+		{"MISSING_VAR", "", false},
+	}
+	for _, tt := range tests {
+		value, ok, err := expander.Lookup(tt.name)
+		if err != nil {
+			t.Fatalf("Lookup(%q) error = %v", tt.name, err)
+		}
+		if value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.name, value, ok, tt.wantValue, tt.wantOK)
+		}
+	}
+}
+
+func TestExpanderToggles(t *testing.T) {
+	t.Setenv("GOENVSUBST", "strict=1")
+	var unsetErr *goenvsubst.UnsetError
+	if err := goenvsubst.New().Do(&[]string{"$TOGGLE_MISSING"}); !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+
+	t.Setenv("GOENVSUBST", "keepmissing=1, bogus=1")
+	values := []string{"$TOGGLE_MISSING"}
+	if err := goenvsubst.Do(&values); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if values[0] != "$TOGGLE_MISSING" {
+		t.Errorf("value = %q, want the reference kept", values[0])
+	}
+
+	// The variable overrides the options given in code
+	t.Setenv("GOENVSUBST", "strict=0")
+	if err := goenvsubst.New(goenvsubst.Strict()).Do(&[]string{"$TOGGLE_MISSING"}); err != nil {
+		t.Errorf("Do() error = %v", err)
+	}
+}
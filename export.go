@@ -0,0 +1,139 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NamingFunc maps the path of a value inside a structure (field names, map
+// keys and slice indexes, from the outermost to the innermost) to the name of
+// the environment variable it is exported as. Returning an empty string skips
+// the value.
+type NamingFunc func(path []string) string
+
+// UpperSnakeCase is the default NamingFunc. It converts every path element
+// from CamelCase to UPPER_SNAKE_CASE and joins them with underscores, so the
+// field Database.MaxConns becomes DATABASE_MAX_CONNS.
+func UpperSnakeCase(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = toUpperSnake(p)
+	}
+	return strings.Join(parts, "_")
+}
+
+// SetEnvFromStruct writes the values of v back into the process environment,
+// naming every variable with naming (UpperSnakeCase when nil). It is meant to
+// be called after Do, so that child processes which only read the environment
+// observe the same resolved configuration.
+//
+// String, boolean and numeric values are exported; nil pointers and
+// unexported fields are skipped. v is walked as Do walks it, with opts
+// applied as in Do, so fields tagged envsubst:"-" and types skipped with
+// WithSkipTypes are not exported either. v must be a struct or a pointer to
+// one.
+func SetEnvFromStruct(v any, naming NamingFunc, opts ...Option) error {
+	env, err := EnvFromStruct(v, naming, opts...)
+	if err != nil {
+		return err
+	}
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("goenvsubst: set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// EnvFromStruct is like SetEnvFromStruct but returns the variables as a
+// KEY=VALUE slice, in the format used by os.Environ and exec.Cmd.Env, instead
+// of modifying the process environment.
+func EnvFromStruct(v any, naming NamingFunc, opts ...Option) ([]string, error) {
+	if naming == nil {
+		naming = UpperSnakeCase
+	}
+
+	// The pointers are scanned as well, so a struct that refers back to
+	// itself through them is exported once
+	rv := reflect.ValueOf(v)
+	target := rv
+	for target.Kind() == reflect.Ptr && !target.IsNil() {
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("goenvsubst: EnvFromStruct requires a struct, got %T", v)
+	}
+
+	var env []string
+	s := &scanner{w: New(opts...).walker(), leaf: func(v reflect.Value, _ string, path []string) {
+		value, ok := exportValue(v)
+		if !ok {
+			return
+		}
+		if name := naming(path); name != "" {
+			env = append(env, name+"="+value)
+		}
+	}}
+	if err := s.value(rv, "", nil); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// exportValue formats the string, boolean or number v as it is exported
+func exportValue(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true
+	}
+	return "", false
+}
+
+// sortedMapKeys returns the keys of map v ordered by their printed form, so
+// traversals that report or export values are deterministic
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// appendPath returns a copy of path with elem appended, so sibling branches
+// never share a backing array
+func appendPath(path []string, elem string) []string {
+	return append(path[:len(path):len(path)], elem)
+}
+
+// toUpperSnake converts a CamelCase identifier to UPPER_SNAKE_CASE, keeping
+// acronyms together: APIKey becomes API_KEY and DatabaseURL becomes
+// DATABASE_URL.
+func toUpperSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package goenvsubst_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestEnvFromStruct(t *testing.T) {
+	type Database struct {
+		URL      string
+		MaxConns int
+	}
+
+	tests := []struct {
+		name     string
+		input    any
+		naming   goenvsubst.NamingFunc
+		opts     []goenvsubst.Option
+		expected []string
+	}{
+		{
+			name: "flat struct",
+			input: &struct {
+				Host  string
+				Port  int
+				Debug bool
+			}{"localhost", 8080, true},
+			expected: []string{"HOST=localhost", "PORT=8080", "DEBUG=true"},
+		},
+		{
+			name: "nested struct",
+			input: &struct {
+				Database Database
+			}{Database{URL: "postgres://db", MaxConns: 10}},
+			expected: []string{"DATABASE_URL=postgres://db", "DATABASE_MAX_CONNS=10"},
+		},
+		{
+			name: "acronyms",
+			input: &struct {
+				APIKey  string
+				HTTPURL string
+			}{"secret", "http://example.com"},
+			expected: []string{"API_KEY=secret", "HTTPURL=http://example.com"},
+		},
+		{
+			name: "slices and maps",
+			input: &struct {
+				Services []string
+				Labels   map[string]string
+			}{[]string{"auth", "payment"}, map[string]string{"b": "2", "a": "1"}},
+			expected: []string{"SERVICES_0=auth", "SERVICES_1=payment", "LABELS_A=1", "LABELS_B=2"},
+		},
+		{
+			name: "nil pointers and unexported fields are skipped",
+			input: &struct {
+				Ptr     *Database
+				private string
+				Public  string
+			}{nil, "hidden", "shown"},
+			expected: []string{"PUBLIC=shown"},
+		},
+		{
+			name: "skipped fields and types are not exported",
+			input: &struct {
+				Price string `envsubst:"-"`
+				Raw   rawTemplate
+				Host  string
+			}{"$5", rawTemplate{"$RAW"}, "localhost"},
+			opts:     []goenvsubst.Option{goenvsubst.WithSkipTypes(reflect.TypeFor[rawTemplate]())},
+			expected: []string{"HOST=localhost"},
+		},
+		{
+			name:  "custom naming",
+			input: struct{ Host string }{"localhost"},
+			naming: func(path []string) string {
+				return "APP_" + strings.ToUpper(strings.Join(path, "_"))
+			},
+			expected: []string{"APP_HOST=localhost"},
+		},
+		{
+			name:  "empty name skips the value",
+			input: &struct{ Host, Port string }{"localhost", "8080"},
+			naming: func(path []string) string {
+				if path[0] == "Port" {
+					return ""
+				}
+				return path[0]
+			},
+			expected: []string{"Host=localhost"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := goenvsubst.EnvFromStruct(tt.input, tt.naming, tt.opts...)
+			if err != nil {
+				t.Fatalf("EnvFromStruct() error = %v", err)
+			}
+			if !reflect.DeepEqual(env, tt.expected) {
+				t.Errorf("EnvFromStruct() = %v, want %v", env, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvFromStructRequiresStruct(t *testing.T) {
+	if _, err := goenvsubst.EnvFromStruct(&[]string{"a"}, nil); err == nil {
+		t.Error("EnvFromStruct() expected error for non-struct input")
+	}
+}
+
+func TestSetEnvFromStruct(t *testing.T) {
+	os.Setenv("EXPORT_TEST_URL", "postgres://localhost/app")
+	defer func() {
+		os.Unsetenv("EXPORT_TEST_URL")
+		os.Unsetenv("EXPORT_DATABASE_URL")
+	}()
+
+	config := &struct {
+		DatabaseURL string
+	}{"$EXPORT_TEST_URL"}
+
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	naming := func(path []string) string {
+		return "EXPORT_" + goenvsubst.UpperSnakeCase(path)
+	}
+	if err := goenvsubst.SetEnvFromStruct(config, naming); err != nil {
+		t.Fatalf("SetEnvFromStruct() error = %v", err)
+	}
+
+	if got := os.Getenv("EXPORT_DATABASE_URL"); got != "postgres://localhost/app" {
+		t.Errorf("EXPORT_DATABASE_URL = %q, want %q", got, "postgres://localhost/app")
+	}
+}
+
+func TestEnvFromStructCycle(t *testing.T) {
+	type Node struct {
+		Name string
+		Next *Node
+	}
+	node := &Node{Name: "root"}
+	node.Next = node
+
+	env, err := goenvsubst.EnvFromStruct(node, nil)
+	if err != nil {
+		t.Fatalf("EnvFromStruct() error = %v", err)
+	}
+	if expected := []string{"NAME=root"}; !reflect.DeepEqual(env, expected) {
+		t.Errorf("EnvFromStruct() = %v, want %v", env, expected)
+	}
+}
@@ -0,0 +1,80 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// doFixed expands a [N]byte or [N]rune field holding short text. The text
+// ends at the first zero element; the expanded value is written back padded
+// with zeros, and values longer than N elements are an error.
+func (w *walker) doFixed(v reflect.Value, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Array || (v.Type().Elem().Kind() != reflect.Uint8 && v.Type().Elem().Kind() != reflect.Int32) {
+		return fmt.Errorf("goenvsubst: field %s: fixed option requires a [N]byte or [N]rune array, got %s", path, v.Type())
+	}
+	isBytes := v.Type().Elem().Kind() == reflect.Uint8
+
+	// Read the text up to the first zero element
+	var text string
+	if isBytes {
+		b := make([]byte, 0, v.Len())
+		for i := 0; i < v.Len() && v.Index(i).Uint() != 0; i++ {
+			b = append(b, byte(v.Index(i).Uint()))
+		}
+		text = string(b)
+	} else {
+		r := make([]rune, 0, v.Len())
+		for i := 0; i < v.Len() && v.Index(i).Int() != 0; i++ {
+			r = append(r, rune(v.Index(i).Int()))
+		}
+		text = string(r)
+	}
+
+	expanded, err := w.expand(text, path)
+	if err != nil {
+		return err
+	}
+	if expanded == text {
+		return nil
+	}
+
+	if isBytes {
+		if len(expanded) > v.Len() {
+			return fmt.Errorf("goenvsubst: field %s: value of %d bytes does not fit in %s", path, len(expanded), v.Type())
+		}
+		if w.checking() {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			var b byte
+			if i < len(expanded) {
+				b = expanded[i]
+			}
+			v.Index(i).SetUint(uint64(b))
+		}
+		return nil
+	}
+
+	runes := []rune(expanded)
+	if len(runes) > v.Len() {
+		return fmt.Errorf("goenvsubst: field %s: value of %d runes does not fit in %s", path, len(runes), v.Type())
+	}
+	if w.checking() {
+		return nil
+	}
+	for i := 0; i < v.Len(); i++ {
+		var r rune
+		if i < len(runes) {
+			r = runes[i]
+		}
+		v.Index(i).SetInt(int64(r))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package goenvsubst_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoFixedTag(t *testing.T) {
+	os.Setenv("REG", "eu1")
+	os.Setenv("GREE", "héllo")
+	defer func() {
+		os.Unsetenv("REG")
+		os.Unsetenv("GREE")
+	}()
+
+	config := &struct {
+		Region   [4]byte  `envsubst:"fixed"`
+		Greeting [5]rune  `envsubst:"fixed"`
+		Pointer  *[4]byte `envsubst:"fixed"`
+		Static   [4]byte  `envsubst:"fixed"`
+		Untagged [5]byte
+	}{
+		Region:   [4]byte{'$', 'R', 'E', 'G'},
+		Greeting: [5]rune{'$', 'G', 'R', 'E', 'E'},
+		Pointer:  &[4]byte{'$', 'R', 'E', 'G'},
+		Static:   [4]byte{'a', 'b'},
+		Untagged: [5]byte{'$', 'R', 'E', 'G'},
+	}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if want := [4]byte{'e', 'u', '1', 0}; config.Region != want {
+		t.Errorf("Region = %v, want %v", config.Region, want)
+	}
+	if want := [5]rune{'h', 'é', 'l', 'l', 'o'}; config.Greeting != want {
+		t.Errorf("Greeting = %v, want %v", config.Greeting, want)
+	}
+	if want := [4]byte{'e', 'u', '1', 0}; *config.Pointer != want {
+		t.Errorf("Pointer = %v, want %v", *config.Pointer, want)
+	}
+	if want := [4]byte{'a', 'b'}; config.Static != want {
+		t.Errorf("Static = %v, want %v", config.Static, want)
+	}
+	if want := [5]byte{'$', 'R', 'E', 'G'}; config.Untagged != want {
+		t.Errorf("Untagged = %v, want %v", config.Untagged, want)
+	}
+}
+
+func TestDoFixedTagErrors(t *testing.T) {
+	os.Setenv("LONG", "way-too-long")
+	defer os.Unsetenv("LONG")
+
+	overflow := &struct {
+		Code [5]byte `envsubst:"fixed"`
+	}{[5]byte{'$', 'L', 'O', 'N', 'G'}}
+	if err := goenvsubst.Do(overflow); err == nil {
+		t.Error("Do() expected error when the value does not fit")
+	}
+
+	wrongType := &struct {
+		Code [4]int `envsubst:"fixed"`
+	}{}
+	if err := goenvsubst.Do(wrongType); err == nil {
+		t.Error("Do() expected error for a non byte/rune array")
+	}
+}
@@ -0,0 +1,38 @@
+package goenvsubst
+
+import "io/fs"
+
+// DoFS substitutes the references in the files of fsys, such as templates
+// embedded with go:embed, and returns their contents keyed by path. Only
+// regular files whose slash-separated path match reports true for are read;
+// a nil match selects every file. fsys itself is never modified. opts apply
+// as in Do, and errors name the file by its path.
+func DoFS(fsys fs.FS, match func(path string) bool, opts ...Option) (map[string][]byte, error) {
+	w := New(opts...).walker()
+	files := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || (match != nil && !match(path)) {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		expanded, err := w.expand(string(data), path)
+		if err != nil {
+			return err
+		}
+		files[path] = []byte(expanded)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.finish(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
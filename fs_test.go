@@ -0,0 +1,71 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoFS(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	fsys := fstest.MapFS{
+		"app.yaml":           {Data: []byte("host: $TEST_VAR\n")},
+		"nested/db.yaml":     {Data: []byte("url: ${TEST_VAR}/db\n")},
+		"nested/README.md":   {Data: []byte("Set $TEST_VAR\n")},
+		"nested/deep/x.yaml": {Data: []byte("plain\n")},
+	}
+
+	files, err := goenvsubst.DoFS(fsys, func(path string) bool { return strings.HasSuffix(path, ".yaml") })
+	if err != nil {
+		t.Fatalf("DoFS() error = %v", err)
+	}
+	want := map[string][]byte{
+		"app.yaml":           []byte("host: test_value\n"),
+		"nested/db.yaml":     []byte("url: test_value/db\n"),
+		"nested/deep/x.yaml": []byte("plain\n"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("DoFS() = %q, want %q", files, want)
+	}
+	if got := string(fsys["app.yaml"].Data); got != "host: $TEST_VAR\n" {
+		t.Errorf("fsys was modified: %q", got)
+	}
+
+	all, err := goenvsubst.DoFS(fsys, nil)
+	if err != nil {
+		t.Fatalf("DoFS() error = %v", err)
+	}
+	if len(all) != len(fsys) {
+		t.Errorf("DoFS() with nil match returned %d files, want %d", len(all), len(fsys))
+	}
+}
+
+func TestDoFSErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.conf": {Data: []byte("$MISSING_A")},
+		"b.conf": {Data: []byte("${MISSING_B:?is required}")},
+	}
+
+	_, err := goenvsubst.DoFS(fsys, nil)
+	var pathErr *goenvsubst.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "b.conf" {
+		t.Fatalf("DoFS() error = %v, want *PathError for b.conf", err)
+	}
+
+	delete(fsys, "b.conf")
+	_, err = goenvsubst.DoFS(fsys, nil, goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("DoFS() error = %v, want *UnsetError", err)
+	}
+	if want := "goenvsubst: unset variables: MISSING_A (a.conf)"; err.Error() != want {
+		t.Errorf("DoFS() error = %q, want %q", err, want)
+	}
+}
@@ -1,67 +1,455 @@
 package goenvsubst
 
 import (
-	"os"
+	"fmt"
+	"math/big"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Do recursively walks through any Go data structure (structs, slices, maps, arrays, pointers)
 // and replaces environment variable references in string values with their actual values
 // from the environment. Environment variables should be in the format $VAR_NAME.
 // Supports top-level and nested: structs, slices, arrays, maps, and pointers.
-func Do(v any) error {
-	return doValue(reflect.ValueOf(v))
+// Options such as Strict change how references are resolved.
+//
+// v must be a non-nil pointer, or a map or slice, since Do modifies it in
+// place; other values make Do return an error.
+func Do(v any, opts ...Option) error {
+	return New(opts...).Do(v)
 }
 
-// doValue recursively processes reflect.Value to expand environment variables
-func doValue(v reflect.Value) error {
+// DoPtr is a type-safe form of Do for callers that want the compiler,
+// rather than an error at run time, to tell them they passed a value that
+// cannot be modified. T may itself be a pointer, as in DoPtr(&cfg) with cfg of
+// type *Config.
+func DoPtr[T any](v *T, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return New(opts...).walker().do(rv)
+}
+
+// DoWithMap is like Do but resolves variables from vars instead of the
+// process environment: a variable is set only if vars has the key. It
+// suits services that keep a separate variable set per tenant. opts apply
+// as in Do; a resolver among them takes precedence over vars.
+func DoWithMap(v any, vars map[string]string, opts ...Option) error {
+	return Do(v, append([]Option{WithResolver(MapResolver(vars))}, opts...)...)
+}
+
+// Expand substitutes the references in s, with the same syntax and options
+// as Do, for strings that are not part of a structure, such as log formats
+// or command-line arguments.
+func Expand(s string, opts ...Option) (string, error) {
+	return New(opts...).Expand(s)
+}
+
+// ExpandWith is like Expand but resolves variables with r. opts apply as in
+// Do; a resolver among them takes precedence over r.
+func ExpandWith(s string, r Resolver, opts ...Option) (string, error) {
+	return Expand(s, append([]Option{WithResolver(r)}, opts...)...)
+}
+
+// walker carries the configuration of a single Do call through the traversal
+// together with what it observed along the way
+type walker struct {
+	config
+	// unset lists the variables referenced but not set, in the order they
+	// were first met, and unsetPaths where each is referenced; they are only
+	// collected in strict mode
+	unset      []string
+	unsetPaths map[string][]string
+	// fieldDefault, if set, replaces plain references to unset or empty
+	// variables within a field tagged with a default
+	fieldDefault *string
+	// report, if set, collects what the traversal did for DoWithReport
+	report *Report
+	// issues, if set, collects the problems found by Check, which walks
+	// without modifying anything
+	issues *[]Issue
+	// expanding lists the variables whose values are being expanded with
+	// WithRecursion, outermost first
+	expanding []string
+	// visited holds the pointers, maps and slices already processed, so
+	// shared values are substituted once and cycles end
+	visited map[visitKey]bool
+	// depth counts the containers being processed, outermost included
+	depth int
+	// transforms, if set, are applied to the strings expanded within a
+	// field tagged with them
+	transforms []string
+}
+
+// visitKey identifies a pointer, map or slice by what it refers to
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// visit reports whether the pointer, map or slice v is met for the first
+// time, and records it
+func (w *walker) visit(v reflect.Value) bool {
+	key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if w.visited[key] {
+		return false
+	}
+	w.visited[key] = true
+	return true
+}
+
+// enter records that the walk descends into the container at path, failing
+// once containers nest deeper than WithMaxDepth allows; leave undoes it
+func (w *walker) enter(path string) error {
+	if w.maxDepth > 0 && w.depth >= w.maxDepth {
+		return &PathError{Path: displayPath(path), Err: &DepthError{Max: w.maxDepth}}
+	}
+	w.depth++
+	return nil
+}
+
+func (w *walker) leave() {
+	w.depth--
+}
+
+// resolve finds a variable with the configured resolver, falling back to
+// the process environment
+func (w *walker) resolve(name string) (string, bool, error) {
+	return dropSource(w.resolveSource(name))
+}
+
+// resolveSource is like resolve, and also returns the name of the source
+// that supplied the variable, if known
+func (w *walker) resolveSource(name string) (string, bool, string, error) {
+	r := w.resolver
+	if r == nil {
+		r = EnvResolver{}
+	}
+	value, ok, source, err := resolveFrom(r, name)
+	if err != nil {
+		return "", false, "", &resolveError{name: name, err: err}
+	}
+	return value, ok, source, nil
+}
+
+// addUnset records an unset variable referenced at path
+func (w *walker) addUnset(name, path string) {
+	if w.unsetPaths == nil {
+		w.unsetPaths = map[string][]string{}
+	}
+	paths, seen := w.unsetPaths[name]
+	if !seen {
+		w.unset = append(w.unset, name)
+	}
+	if !slices.Contains(paths, path) {
+		w.unsetPaths[name] = append(paths, path)
+	}
+}
+
+// do processes v and reports the outcome of the whole traversal
+func (w *walker) do(v reflect.Value) error {
+	if err := checkSettable(v); err != nil {
+		return err
+	}
+	if err := w.doValue(v, ""); err != nil {
+		return err
+	}
+	return w.finish()
+}
+
+// checkSettable rejects inputs that substitution could not modify, such as
+// a struct passed by value, which would otherwise be silently left as is.
+// Maps and slices share their contents, so they can be passed directly.
+func checkSettable(v reflect.Value) error {
+	switch {
+	case !v.IsValid():
+		return fmt.Errorf("goenvsubst: value must be a non-nil pointer, got nil")
+	case v.Kind() == reflect.Ptr && v.IsNil():
+		return fmt.Errorf("goenvsubst: value must be a non-nil pointer, got nil %s", v.Type())
+	case v.Kind() == reflect.Ptr, v.Kind() == reflect.Map, v.Kind() == reflect.Slice, v.CanSet():
+		return nil
+	}
+	return fmt.Errorf("goenvsubst: value must be a non-nil pointer, got %s", v.Type())
+}
+
+// finish reports what the traversal observed once it completed without
+// other errors
+func (w *walker) finish() error {
+	if len(w.unset) > 0 {
+		return &UnsetError{Names: w.unset, Paths: w.unsetPaths}
+	}
+	return nil
+}
+
+// doValue recursively processes reflect.Value to expand environment
+// variables; path locates v within the outermost value for error messages
+func (w *walker) doValue(v reflect.Value, path string) error {
+	if w.skipsType(v.Type()) {
+		return nil
+	}
+	if handled, err := w.doHandler(v, path); handled {
+		return err
+	}
 	if v.Kind() == reflect.Ptr {
-		if v.IsNil() {
+		if v.IsNil() || w.skipsType(v.Type().Elem()) || !w.visit(v) {
 			return nil
 		}
 		v = v.Elem()
+		if handled, err := w.doHandler(v, path); handled {
+			return err
+		}
 	}
 
 	switch v.Kind() {
 	case reflect.String:
-		return doString(v)
+		return w.doString(v, path)
 	case reflect.Struct:
-		return doStruct(v)
+		if m, ok := concurrentMap(v); ok {
+			return w.doConcurrentMap(m, path)
+		}
+		return w.doStruct(v, path)
 	case reflect.Slice, reflect.Array:
-		return doSliceArray(v)
+		return w.doSliceArray(v, path)
 	case reflect.Map:
-		return doMap(v)
+		return w.doMap(v, path)
+	case reflect.Interface:
+		return w.doInterface(v, path)
+	}
+
+	return nil
+}
+
+// defaultSkipTypes are the types never descended into: their strings, if
+// any, are internals rather than configuration
+var defaultSkipTypes = []reflect.Type{
+	reflect.TypeFor[time.Time](),
+	reflect.TypeFor[big.Int](),
+	reflect.TypeFor[big.Float](),
+	reflect.TypeFor[big.Rat](),
+	reflect.TypeFor[sync.Mutex](),
+	reflect.TypeFor[sync.RWMutex](),
+	reflect.TypeFor[reflect.Value](),
+}
+
+// skipsType reports whether values of type t are left untouched, by default
+// or as requested with WithSkipTypes
+func (w *walker) skipsType(t reflect.Type) bool {
+	return slices.Contains(defaultSkipTypes, t) || slices.Contains(w.skipTypes, t)
+}
+
+// doInterface processes values held by interfaces, such as the trees of
+// map[string]any and []any produced by json.Unmarshal. Pointers, maps and
+// slices share their contents and are processed in place; other values
+// cannot be modified through the interface, so a copy is processed and
+// stored back when the interface itself is settable.
+func (w *walker) doInterface(v reflect.Value, path string) error {
+	if v.IsNil() {
+		return nil
 	}
 
+	elem := v.Elem()
+	switch elem.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		return w.doValue(elem, path)
+	}
+	if !v.CanSet() {
+		return nil
+	}
+
+	// With type inference, strings in an empty interface may become numbers
+	// or booleans
+	if w.inferTypes && elem.Type() == reflect.TypeFor[string]() && v.NumMethod() == 0 {
+		original := elem.String()
+		expanded, err := w.expand(original, path)
+		if err != nil {
+			return err
+		}
+		if expanded != original && !w.checking() {
+			v.Set(reflect.ValueOf(inferType(expanded)))
+		}
+		return nil
+	}
+
+	copied := reflect.New(elem.Type()).Elem()
+	copied.Set(elem)
+	if err := w.doValue(copied, path); err != nil {
+		return err
+	}
+	if !w.checking() {
+		v.Set(copied)
+	}
 	return nil
 }
 
 // doString processes string values for environment variable expansion
-func doString(v reflect.Value) error {
-	if v.CanSet() {
-		v.SetString(expandEnvVar(v.String()))
+func (w *walker) doString(v reflect.Value, path string) error {
+	if !v.CanSet() {
+		return nil
+	}
+	expanded, err := w.expand(v.String(), path)
+	if err != nil || w.checking() {
+		return err
 	}
+	v.SetString(expanded)
 	return nil
 }
 
 // doStruct processes struct values recursively
-func doStruct(v reflect.Value) error {
+func (w *walker) doStruct(v reflect.Value, path string) error {
+	if err := w.enter(path); err != nil {
+		return err
+	}
+	defer w.leave()
+
+	t := v.Type()
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
-		if field.CanSet() {
-			if err := doValue(field); err != nil {
+		if !field.CanSet() {
+			if w.unexportedEmbeds && t.Field(i).Anonymous {
+				if err := w.doEmbedded(field, fieldPath(path, t.Field(i).Name)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		fpath := fieldPath(path, t.Field(i).Name)
+		tag, err := parseTag(t.Field(i), fpath, w.referenceSyntax())
+		if err != nil {
+			if err = w.fail(fpath, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if tag.skip {
+			continue
+		}
+		if tag.when != nil {
+			matches, err := tag.when.matches(w.resolve)
+			if err != nil {
 				return err
 			}
+			if !matches {
+				continue
+			}
+		}
+
+		if tag.required || tag.secret || tag.defaultValue != nil || len(tag.transforms) > 0 || tag.from != "" {
+			err = w.doFieldWithOptions(field, tag, fpath)
+		} else {
+			err = w.doField(field, tag, fpath)
+		}
+		if err = w.fail(fpath, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doEmbedded processes the promoted fields of an embedded struct of an
+// unexported type. Reflection cannot set the field itself, only its exported
+// fields, so their tags apply while the embedded field's own tag does not.
+func (w *walker) doEmbedded(v reflect.Value, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return w.doStruct(v, path)
+}
+
+// doField processes a single struct field as its tag asks
+func (w *walker) doField(field reflect.Value, tag fieldTag, path string) error {
+	switch {
+	case tag.base64:
+		return w.doBase64(field, tag, path)
+	case tag.json:
+		return w.doJSONField(field, tag, path)
+	case tag.separator != "":
+		return w.doSplit(field, tag, path)
+	case tag.source != "" || tag.env != "":
+		return w.doSource(field, tag, path)
+	case tag.text:
+		return w.doText(field, path)
+	case tag.fixed:
+		return w.doFixed(field, path)
+	}
+	return w.doValue(field, path)
+}
+
+// doFieldWithOptions processes a field whose tag sets required, secret,
+// default, transforms or a source. The field gets a walker of its own: with
+// required, a reference to an unset variable anywhere in it is an error
+// whether or not strict mode is on, with secret, the values of the variables
+// it references are kept out of messages, with default, references to unset
+// or empty variables take the default, transforms apply to every string
+// expanded in it, and with a source its variables are resolved from that
+// source alone.
+func (w *walker) doFieldWithOptions(field reflect.Value, tag fieldTag, path string) error {
+	fw := &walker{
+		config:       w.config,
+		fieldDefault: tag.defaultValue,
+		transforms:   tag.transforms,
+		report:       w.report,
+		issues:       w.issues,
+		visited:      w.visited,
+		depth:        w.depth,
+	}
+	if tag.required {
+		fw.strict = true
+	}
+	if tag.from != "" {
+		r, err := w.sourceNamed(tag.from)
+		if err != nil {
+			return fmt.Errorf("goenvsubst: field %s: %w", path, err)
+		}
+		fw.resolver = r
+	}
+	if tag.secret {
+		c := &referenceCollector{w: &walker{config: w.config, visited: map[visitKey]bool{}}}
+		if err := c.field(field, tag, path); err != nil {
+			return err
+		}
+		fw.secrets = append(slices.Clone(w.secrets), c.names...)
+	}
+	if err := fw.doField(field, tag, path); err != nil {
+		return fw.redact(err)
+	}
+	if tag.required && len(fw.unset) > 0 && !w.checking() {
+		return &RequiredError{Name: fw.unset[0], Field: path}
+	}
+	for _, variable := range fw.unset {
+		for _, p := range fw.unsetPaths[variable] {
+			w.addUnset(variable, p)
 		}
 	}
 	return nil
 }
 
 // doSliceArray processes slice and array values recursively
-func doSliceArray(v reflect.Value) error {
+func (w *walker) doSliceArray(v reflect.Value, path string) error {
+	if v.Kind() == reflect.Slice && (v.Len() == 0 || !w.visit(v)) {
+		return nil
+	}
+	if err := w.enter(path); err != nil {
+		return err
+	}
+	defer w.leave()
+
 	for i := 0; i < v.Len(); i++ {
-		if err := doValue(v.Index(i)); err != nil {
+		if err := w.doValue(v.Index(i), indexPath(path, i)); err != nil {
 			return err
 		}
 	}
@@ -69,40 +457,280 @@ func doSliceArray(v reflect.Value) error {
 }
 
 // doMap processes map values recursively
-func doMap(v reflect.Value) error {
+func (w *walker) doMap(v reflect.Value, path string) error {
+	if v.IsNil() || !w.visit(v) {
+		return nil
+	}
+	if err := w.enter(path); err != nil {
+		return err
+	}
+	defer w.leave()
+
 	for _, key := range v.MapKeys() {
 		mapValue := v.MapIndex(key)
+		kpath := keyPath(path, key.Interface())
 		// For maps, we need to create a new value, modify it, and set it back
-		if mapValue.Kind() == reflect.String {
+		if w.skipsType(mapValue.Type()) {
+			continue
+		}
+		if mapValue.Kind() == reflect.String && handlerFor(mapValue.Type()) == nil {
 			original := mapValue.String()
-			expanded := expandEnvVar(original)
-			if expanded != original {
-				v.SetMapIndex(key, reflect.ValueOf(expanded))
+			expanded, err := w.expand(original, kpath)
+			if err != nil {
+				return err
+			}
+			if expanded != original && !w.checking() {
+				// Convert to the element type, which may be a defined string type
+				v.SetMapIndex(key, reflect.ValueOf(expanded).Convert(mapValue.Type()))
 			}
 		} else {
 			// For non-string values, create a copy and recurse
 			newValue := reflect.New(mapValue.Type()).Elem()
 			newValue.Set(mapValue)
-			if err := doValue(newValue); err != nil {
+			if err := w.doValue(newValue, kpath); err != nil {
 				return err
 			}
-			v.SetMapIndex(key, newValue)
+			if !w.checking() {
+				v.SetMapIndex(key, newValue)
+			}
 		}
 	}
+
+	if w.mapKeys && v.Type().Key().Kind() == reflect.String {
+		return w.fail(path, w.doMapKeys(v, path))
+	}
 	return nil
 }
 
-// expandEnvVar replaces environment variable references in the format $VAR_NAME
-// with their actual values from the environment. Returns empty string for
-// missing or empty environment variables.
-func expandEnvVar(s string) string {
-	if !strings.HasPrefix(s, "$") {
-		return s
+// doMapKeys expands the string keys of map v and moves their values to the
+// expanded keys. All keys are expanded before any is renamed, so keys that
+// would collide leave the keys untouched.
+func (w *walker) doMapKeys(v reflect.Value, path string) error {
+	keys := sortedMapKeys(v)
+	expandedKeys := make([]reflect.Value, len(keys))
+	origins := make(map[string]string, len(keys))
+	for i, key := range keys {
+		expanded, err := w.expand(key.String(), keyPath(path, key.Interface()))
+		if err != nil {
+			return err
+		}
+		if origin, ok := origins[expanded]; ok {
+			return &PathError{Path: displayPath(path), Err: fmt.Errorf("map keys %q and %q both expand to %q", origin, key.String(), expanded)}
+		}
+		origins[expanded] = key.String()
+
+		expandedKeys[i] = reflect.New(key.Type()).Elem()
+		expandedKeys[i].SetString(expanded)
+	}
+	if w.checking() {
+		return nil
+	}
+
+	// Remove every renamed key first, so a value is never written over one
+	// that has yet to be moved
+	values := make([]reflect.Value, len(keys))
+	for i, key := range keys {
+		if expandedKeys[i].String() != key.String() {
+			values[i] = v.MapIndex(key)
+			v.SetMapIndex(key, reflect.Value{})
+		}
 	}
+	for i, value := range values {
+		if value.IsValid() {
+			v.SetMapIndex(expandedKeys[i], value)
+		}
+	}
+	return nil
+}
+
+// expand expands the string found at path, reporting failures as a
+// *PathError
+func (w *walker) expand(s, path string) (string, error) {
+	expanded, err := w.expandTemplate(s, path)
+	return w.expanded(s, expanded, err, path)
+}
 
-	// Remove the $ prefix to get the variable name
-	varName := strings.TrimPrefix(s, "$")
+// expandVariable is like expand for a reference to the single variable
+// name, such as one given by the env tag, which reads the same whatever the
+// syntax
+func (w *walker) expandVariable(name, path string) (string, error) {
+	segment := Segment{Kind: PlaceholderSegment, Name: name, Braced: true}
+	expanded, err := w.expandPlaceholder(segment, segment.String(), path)
+	return w.expanded(segment.String(), expanded, err, path)
+}
+
+// expanded completes the expansion of s at path into expanded or err
+func (w *walker) expanded(s, expanded string, err error, path string) (string, error) {
+	if err != nil {
+		return s, w.fail(path, &PathError{Path: displayPath(path), Err: err})
+	}
+	expanded = applyTransforms(expanded, w.transforms)
+	if w.report != nil {
+		w.report.addValue(s, expanded, displayPath(path))
+	}
+	return expanded, nil
+}
+
+// expandTemplate replaces environment variable references in the format
+// $VAR_NAME or ${VAR_NAME}, or those of the configured syntax, with their
+// actual values from the environment, applying the operators of braced
+// references. Returns empty string for missing or empty environment
+// variables without a default.
+func (w *walker) expandTemplate(s, path string) (string, error) {
+	// produced counts the bytes that placeholders expanded to, so that
+	// WithMaxOutputSize stops runaway expansion before the result is built
+	produced := 0
+	expanded, err := w.referenceSyntax().Replace(s, func(placeholder Segment, text string) (string, error) {
+		value, err := w.expandPlaceholder(placeholder, text, path)
+		if err == nil && value != text && len(w.expanding) == 0 {
+			err = w.validate(placeholder.Name, value, path)
+			if err == nil && w.urlEscaping && len(placeholder.Modifiers) == 0 && inURLComponent(s, placeholder, text) {
+				value = escapeURLComponent(value)
+			}
+			if err == nil && w.onSubstitute != nil && !w.checking() {
+				w.onSubstitute(displayPath(path), placeholder.Name, text, w.redactString(value))
+			}
+		}
+		produced += len(value)
+		if err == nil && w.maxOutputSize > 0 && produced > w.maxOutputSize {
+			return "", &OutputSizeError{Max: w.maxOutputSize}
+		}
+		return value, err
+	})
+	if err == nil && w.maxOutputSize > 0 && len(expanded) > w.maxOutputSize {
+		return "", &OutputSizeError{Max: w.maxOutputSize}
+	}
+	return expanded, w.redact(err)
+}
+
+// validate runs the validators of WithValidator on value, which a reference
+// to name at path expanded to
+func (w *walker) validate(name, value, path string) error {
+	for _, validator := range w.validators {
+		if err := validator(displayPath(path), name, value); err != nil {
+			return &ValidationError{Name: name, Err: err}
+		}
+	}
+	return nil
+}
+
+// expandPlaceholder resolves a single placeholder, written as text, and
+// applies its operator and then its modifiers
+func (w *walker) expandPlaceholder(segment Segment, text, path string) (string, error) {
+	value, err := w.expandOperator(segment, text, path)
+	// A reference kept as written is left alone
+	if err != nil || len(segment.Modifiers) == 0 || value == text {
+		return value, err
+	}
+	for _, name := range segment.Modifiers {
+		modifier := modifierFor(name)
+		if modifier == nil {
+			return "", fmt.Errorf("unknown modifier %q", name)
+		}
+		if value, err = modifier(value); err != nil {
+			return "", fmt.Errorf("modifier %s of %s: %w", name, segment.Name, err)
+		}
+	}
+	return value, nil
+}
+
+// expandOperator resolves a single placeholder, written as text, and
+// applies its operator
+func (w *walker) expandOperator(segment Segment, text, path string) (string, error) {
+	permitted, err := w.permits(segment.Name)
+	if err != nil {
+		return "", err
+	}
+	if !permitted {
+		return "", &DeniedError{Name: segment.Name}
+	}
 
 	// Get the environment variable value
-	return os.Getenv(varName)
+	value, ok, source, err := w.resolveSource(segment.Name)
+	if err != nil {
+		return "", err
+	}
+	if w.report != nil {
+		w.report.addVariable(segment.Name, ok, source)
+	}
+	if ok && w.maxRecursion > 0 {
+		if value, err = w.expandValue(segment.Name, value, path); err != nil {
+			return "", err
+		}
+	}
+	// Case operators, like plain references, do not handle unset variables
+	plain := segment.Op == "" || isCaseOperator(segment.Op)
+	if value == "" && plain && w.fieldDefault != nil {
+		return *w.fieldDefault, nil
+	}
+	if !ok && plain {
+		if w.strict {
+			w.addUnset(segment.Name, displayPath(path))
+		}
+		if w.checking() {
+			w.addIssue(segment.Name, path)
+		}
+		if w.keepUnset {
+			return text, nil
+		}
+	}
+
+	// The colon forms treat an empty variable like an unset one
+	missing := !ok || (value == "" && strings.HasPrefix(segment.Op, ":"))
+
+	switch strings.TrimPrefix(segment.Op, ":") {
+	case "-":
+		if missing {
+			return w.expandTemplate(segment.Arg, path)
+		}
+	case "?":
+		if missing {
+			message, err := w.expandTemplate(segment.Arg, path)
+			if err != nil {
+				return "", err
+			}
+			return "", &RequiredError{Name: segment.Name, Message: message}
+		}
+	case "+":
+		if missing {
+			return "", nil
+		}
+		return w.expandTemplate(segment.Arg, path)
+	case "^^", ",,", "^", ",":
+		return changeCase(segment.Op, value), nil
+	}
+	return value, nil
+}
+
+// expandValue expands the references in value, the value of the variable
+// name, for WithRecursion. Only values that contain references count
+// towards the depth limit.
+func (w *walker) expandValue(name, value, path string) (string, error) {
+	placeholders, err := w.referenceSyntax().Find(value)
+	if err != nil {
+		return "", err
+	}
+	if len(placeholders) == 0 {
+		return w.expandTemplate(value, path)
+	}
+
+	chain := append(slices.Clone(w.expanding), name)
+	if i := slices.Index(w.expanding, name); i >= 0 {
+		return "", &CycleError{Chain: chain[i:]}
+	}
+	if len(w.expanding) >= w.maxRecursion {
+		return "", fmt.Errorf("goenvsubst: variables nest deeper than %d: %s", w.maxRecursion, strings.Join(chain, " -> "))
+	}
+
+	w.expanding = chain
+	defer func() { w.expanding = chain[:len(chain)-1] }()
+	return w.expandTemplate(value, path)
+}
+
+// referenceSyntax returns the configured syntax
+func (w *walker) referenceSyntax() Syntax {
+	if w.syntax == nil {
+		return ShellSyntax
+	}
+	return w.syntax
 }
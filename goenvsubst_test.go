@@ -1,9 +1,15 @@
 package goenvsubst_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/iamolegga/goenvsubst"
 )
@@ -18,6 +24,60 @@ var tests = []struct {
 		input:    &struct{ Value string }{"$TEST_VAR"},
 		expected: &struct{ Value string }{"test_value"},
 	},
+	{
+		name:     "braced reference",
+		input:    &struct{ Value string }{"${TEST_VAR}"},
+		expected: &struct{ Value string }{"test_value"},
+	},
+	{
+		name:     "braced references inside a string",
+		input:    &struct{ Value string }{"postgres://${TEST_VAR}:${ANOTHER_VAR}/app?x=${MISSING_VAR}"},
+		expected: &struct{ Value string }{"postgres://test_value:another_value/app?x="},
+	},
+	{
+		name:     "multiple bare references inside a string",
+		input:    &struct{ Value string }{"$TEST_VAR://$ANOTHER_VAR:$MISSING_VAR/path"},
+		expected: &struct{ Value string }{"test_value://another_value:/path"},
+	},
+	{
+		name:     "literal dollar signs",
+		input:    &struct{ Value string }{"costs 5$ or $1"},
+		expected: &struct{ Value string }{"costs 5$ or $1"},
+	},
+	{
+		name: "escaped dollar signs",
+		input: &struct{ A, B, C, D string }{
+			"$$TEST_VAR", "$${TEST_VAR}", "cost: $$$TEST_VAR", "${MISSING_VAR:-$${literal}}",
+		},
+		expected: &struct{ A, B, C, D string }{
+			"$TEST_VAR", "${TEST_VAR}", "cost: $test_value", "${literal}",
+		},
+	},
+	{
+		name: "default values",
+		input: &struct{ A, B, C, D, E string }{
+			"${MISSING_VAR:-fallback}", "${EMPTY_VAR:-fallback}", "${EMPTY_VAR-fallback}",
+			"${TEST_VAR:-fallback}", "${MISSING_VAR:-${ANOTHER_VAR}}",
+		},
+		expected: &struct{ A, B, C, D, E string }{
+			"fallback", "fallback", "", "test_value", "another_value",
+		},
+	},
+	{
+		name: "alternate values",
+		input: &struct{ A, B, C, D, E string }{
+			"${TEST_VAR:+--tls}", "${MISSING_VAR:+--tls}", "${EMPTY_VAR:+--tls}",
+			"${EMPTY_VAR+--tls}", "${TEST_VAR:+$ANOTHER_VAR}",
+		},
+		expected: &struct{ A, B, C, D, E string }{
+			"--tls", "", "", "--tls", "another_value",
+		},
+	},
+	{
+		name:     "required variables that are set",
+		input:    &struct{ A, B string }{"${TEST_VAR:?must be set}", "${EMPTY_VAR?must be set}"},
+		expected: &struct{ A, B string }{"test_value", ""},
+	},
 	{
 		name:     "string without substitution",
 		input:    &struct{ Value string }{"no_substitution"},
@@ -197,15 +257,6 @@ var tests = []struct {
 			return &struct{ Value string }{"test_value"}
 		}(),
 	},
-	{
-		name: "top-level nil pointer",
-		input: func() *string {
-			return nil
-		}(),
-		expected: func() *string {
-			return nil
-		}(),
-	},
 
 	// Complex nested structures
 	{
@@ -248,8 +299,68 @@ var tests = []struct {
 		input:    &map[string]*string{"key1": func() *string { s := "$TEST_VAR"; return &s }(), "key2": nil},
 		expected: &map[string]*string{"key1": func() *string { s := "test_value"; return &s }(), "key2": nil},
 	},
+	// Interfaces holding pointers
+	{
+		name:     "interface field holding pointer to struct",
+		input:    &struct{ Section any }{&struct{ Value string }{"$TEST_VAR"}},
+		expected: &struct{ Section any }{&struct{ Value string }{"test_value"}},
+	},
+	{
+		name: "map of interfaces holding pointers",
+		input: &map[string]fmt.Stringer{
+			"db":    &section{Host: "$TEST_VAR"},
+			"cache": &section{Host: "$ANOTHER_VAR"},
+			"nil":   nil,
+		},
+		expected: &map[string]fmt.Stringer{
+			"db":    &section{Host: "test_value"},
+			"cache": &section{Host: "another_value"},
+			"nil":   nil,
+		},
+	},
+	{
+		name:     "interface field holding pointer to string",
+		input:    &struct{ Value any }{func() *string { s := "$TEST_VAR"; return &s }()},
+		expected: &struct{ Value any }{func() *string { s := "test_value"; return &s }()},
+	},
+	{
+		name:     "interface field holding a string",
+		input:    &struct{ Value any }{"$TEST_VAR"},
+		expected: &struct{ Value any }{"test_value"},
+	},
+	{
+		name:     "interface field holding a struct value",
+		input:    &struct{ Section any }{struct{ Host string }{"$TEST_VAR"}},
+		expected: &struct{ Section any }{struct{ Host string }{"test_value"}},
+	},
+	{
+		name: "tree decoded from JSON",
+		input: &map[string]any{
+			"database": map[string]any{
+				"url":      "$TEST_VAR",
+				"replicas": []any{"$ANOTHER_VAR", map[string]any{"dsn": "$TEST_VAR"}},
+				"pool":     float64(10),
+			},
+			"debug": true,
+			"empty": nil,
+		},
+		expected: &map[string]any{
+			"database": map[string]any{
+				"url":      "test_value",
+				"replicas": []any{"another_value", map[string]any{"dsn": "test_value"}},
+				"pool":     float64(10),
+			},
+			"debug": true,
+			"empty": nil,
+		},
+	},
 }
 
+// section is a plugin-style configuration section stored behind an interface
+type section struct{ Host string }
+
+func (s *section) String() string { return s.Host }
+
 func TestDo(t *testing.T) {
 	// Set up test environment variables
 	os.Setenv("TEST_VAR", "test_value")
@@ -291,6 +402,8 @@ func compareValues(a, b reflect.Value) bool {
 	switch a.Kind() {
 	case reflect.Ptr:
 		return comparePointers(a, b)
+	case reflect.Interface:
+		return compareInterfaces(a, b)
 	case reflect.Struct:
 		return compareStructs(a, b)
 	case reflect.Slice:
@@ -313,6 +426,14 @@ func comparePointers(a, b reflect.Value) bool {
 	return compareValues(a.Elem(), b.Elem())
 }
 
+// compareInterfaces compares the values held by two interfaces
+func compareInterfaces(a, b reflect.Value) bool {
+	if a.IsNil() || b.IsNil() {
+		return a.IsNil() == b.IsNil()
+	}
+	return compareValues(a.Elem(), b.Elem())
+}
+
 // compareStructs compares two struct values field by field
 func compareStructs(a, b reflect.Value) bool {
 	for i := 0; i < a.NumField(); i++ {
@@ -350,3 +471,942 @@ func compareMaps(a, b reflect.Value) bool {
 	}
 	return true
 }
+
+func TestDoSyntaxError(t *testing.T) {
+	config := &struct{ Value string }{"postgres://${DB_HOST"}
+	var syntaxErr *goenvsubst.SyntaxError
+	if err := goenvsubst.Do(config); !errors.As(err, &syntaxErr) {
+		t.Errorf("Do() error = %v, want *SyntaxError", err)
+	}
+}
+
+func TestDoStrict(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("EMPTY_VAR", "")
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("EMPTY_VAR")
+	}()
+
+	config := &struct {
+		URL      string
+		Replicas []string
+		Port     string
+		Empty    string
+		Flag     string
+	}{
+		URL:      "postgres://$DB_HOST/$DB_NAME",
+		Replicas: []string{"$TEST_VAR", "$DB_HOST", "${REPLICA}"},
+		Port:     "${PORT:-8080}",
+		Empty:    "$EMPTY_VAR",
+		Flag:     "${TLS_CERT:+--tls}",
+	}
+
+	err := goenvsubst.Do(config, goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+	if expected := []string{"DB_HOST", "DB_NAME", "REPLICA"}; !reflect.DeepEqual(unsetErr.Names, expected) {
+		t.Errorf("Names = %v, want %v", unsetErr.Names, expected)
+	}
+	if expected := "goenvsubst: unset variables: DB_HOST (URL, Replicas[1]), DB_NAME (URL), REPLICA (Replicas[2])"; err.Error() != expected {
+		t.Errorf("Error() = %q, want %q", err.Error(), expected)
+	}
+	if config.Replicas[0] != "test_value" || config.Port != "8080" {
+		t.Errorf("Do() did not process the remaining values: %+v", config)
+	}
+
+	if err := goenvsubst.Do(&[]string{"$TEST_VAR", "$EMPTY_VAR"}, goenvsubst.Strict()); err != nil {
+		t.Errorf("Do() error = %v, want nil when all variables are set", err)
+	}
+}
+
+func TestDoWithLookup(t *testing.T) {
+	os.Setenv("FROM_ENV", "env_value")
+	defer os.Unsetenv("FROM_ENV")
+
+	fixtures := map[string]string{
+		"DB_HOST": "db.internal",
+		"EMPTY":   "",
+		"APP_ENV": "production",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := fixtures[name]
+		return value, ok
+	}
+
+	config := &struct {
+		URL     string
+		Port    string
+		Empty   string
+		FromEnv string
+		Prod    string `envwhen:"APP_ENV=production"`
+	}{
+		URL:     "postgres://$DB_HOST/app",
+		Port:    "${DB_PORT:-5432}",
+		Empty:   "${EMPTY-unset}",
+		FromEnv: "$FROM_ENV",
+		Prod:    "$DB_HOST",
+	}
+
+	if err := goenvsubst.Do(config, goenvsubst.WithLookup(lookup)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	expected := &struct {
+		URL     string
+		Port    string
+		Empty   string
+		FromEnv string
+		Prod    string `envwhen:"APP_ENV=production"`
+	}{"postgres://db.internal/app", "5432", "", "", "db.internal"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Do() = %+v, want %+v", config, expected)
+	}
+
+	err := goenvsubst.Do(&[]string{"$FROM_ENV"}, goenvsubst.WithLookup(lookup), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) || !reflect.DeepEqual(unsetErr.Names, []string{"FROM_ENV"}) {
+		t.Errorf("Do() error = %v, want FROM_ENV reported as unset", err)
+	}
+}
+
+func TestDoWithMap(t *testing.T) {
+	os.Setenv("TENANT", "from_env")
+	defer os.Unsetenv("TENANT")
+
+	tenants := map[string]map[string]string{
+		"acme":   {"TENANT": "acme", "DB_NAME": "acme_db"},
+		"globex": {"TENANT": "globex"},
+	}
+
+	for name, vars := range tenants {
+		config := &struct{ Tenant, DSN string }{"$TENANT", "postgres://db/${DB_NAME:-shared}"}
+		if err := goenvsubst.DoWithMap(config, vars); err != nil {
+			t.Fatalf("DoWithMap() error = %v", err)
+		}
+		if config.Tenant != name {
+			t.Errorf("Tenant = %q, want %q", config.Tenant, name)
+		}
+		if want := "postgres://db/" + map[string]string{"acme": "acme_db", "globex": "shared"}[name]; config.DSN != want {
+			t.Errorf("DSN = %q, want %q", config.DSN, want)
+		}
+	}
+
+	err := goenvsubst.DoWithMap(&[]string{"$TENANT"}, nil, goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Errorf("DoWithMap() error = %v, want *UnsetError for a nil map", err)
+	}
+}
+
+// URL is a defined string type
+type URL string
+
+func TestDoDefinedStringTypes(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	type config struct {
+		Field     URL
+		Pointer   *URL
+		Slice     []URL
+		Array     [2]URL
+		Map       map[string]URL
+		Keys      map[URL]URL
+		Any       any
+		AnyMap    map[string]any
+		Untouched map[string]URL
+	}
+	ptr := URL("$TEST_VAR")
+	c := &config{
+		Field:     "$TEST_VAR",
+		Pointer:   &ptr,
+		Slice:     []URL{"$TEST_VAR"},
+		Array:     [2]URL{"$TEST_VAR", "static"},
+		Map:       map[string]URL{"a": "$TEST_VAR/a"},
+		Keys:      map[URL]URL{"$TEST_VAR": "$TEST_VAR"},
+		Any:       URL("$TEST_VAR"),
+		AnyMap:    map[string]any{"url": URL("$TEST_VAR")},
+		Untouched: map[string]URL{"a": "static"},
+	}
+	if err := goenvsubst.Do(c, goenvsubst.WithMapKeys()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	want := &config{
+		Field:     "test_value",
+		Pointer:   c.Pointer,
+		Slice:     []URL{"test_value"},
+		Array:     [2]URL{"test_value", "static"},
+		Map:       map[string]URL{"a": "test_value/a"},
+		Keys:      map[URL]URL{"test_value": "test_value"},
+		Any:       URL("test_value"),
+		AnyMap:    map[string]any{"url": URL("test_value")},
+		Untouched: map[string]URL{"a": "static"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Do() = %+v, want %+v", c, want)
+	}
+	if *c.Pointer != "test_value" {
+		t.Errorf("Pointer = %q, want %q", *c.Pointer, "test_value")
+	}
+
+	issues, err := goenvsubst.Check(&map[string]URL{"a": "$MISSING_VAR"})
+	if err != nil || len(issues) != 1 {
+		t.Errorf("Check() = %v, %v, want one issue", issues, err)
+	}
+}
+
+func TestDoNonSettable(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{"nil", nil, "goenvsubst: value must be a non-nil pointer, got nil"},
+		{"nil pointer", (*string)(nil), "goenvsubst: value must be a non-nil pointer, got nil *string"},
+		{"struct by value", struct{ Value string }{"$TEST_VAR"}, "goenvsubst: value must be a non-nil pointer, got struct { Value string }"},
+		{"string by value", "$TEST_VAR", "goenvsubst: value must be a non-nil pointer, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := goenvsubst.Do(tt.input)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Do() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// Maps and slices share their contents and need no pointer
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+	values := []string{"$TEST_VAR"}
+	if err := goenvsubst.Do(values); err != nil || values[0] != "test_value" {
+		t.Errorf("Do() on a slice = %v, %q", err, values[0])
+	}
+}
+
+func TestDoPtr(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	config := struct{ Value string }{"$TEST_VAR"}
+	if err := goenvsubst.DoPtr(&config); err != nil {
+		t.Fatalf("DoPtr() error = %v", err)
+	}
+	if config.Value != "test_value" {
+		t.Errorf("Value = %q, want %q", config.Value, "test_value")
+	}
+
+	ptr := &struct{ Value string }{"$TEST_VAR"}
+	if err := goenvsubst.DoPtr(&ptr); err != nil {
+		t.Fatalf("DoPtr() error = %v", err)
+	}
+	if ptr.Value != "test_value" {
+		t.Errorf("Value through a pointer to a pointer = %q, want %q", ptr.Value, "test_value")
+	}
+}
+
+func TestDoWithKeepUnset(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("EMPTY_VAR", "")
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("EMPTY_VAR")
+	}()
+
+	config := &struct{ A, B, C, D, E string }{
+		"$TEST_VAR/$MISSING_VAR", "${MISSING_VAR}", "$EMPTY_VAR", "${MISSING_VAR:-default}", "${OTHER:-$MISSING_VAR}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithKeepUnset()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	expected := &struct{ A, B, C, D, E string }{
+		"test_value/$MISSING_VAR", "${MISSING_VAR}", "", "default", "$MISSING_VAR",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Do() = %+v, want %+v", config, expected)
+	}
+}
+
+func TestDoWithTypeInference(t *testing.T) {
+	os.Setenv("MAX_CONNS", "10")
+	os.Setenv("DEBUG", "true")
+	os.Setenv("RATIO", "0.5")
+	os.Setenv("HOST", "db.internal")
+	defer func() {
+		for _, name := range []string{"MAX_CONNS", "DEBUG", "RATIO", "HOST"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	config := map[string]any{
+		"max_conns": "$MAX_CONNS",
+		"debug":     "$DEBUG",
+		"ratio":     "$RATIO",
+		"host":      "$HOST",
+		"port":      "${PORT:-5432}",
+		"literal":   "10",
+		"number":    float64(3),
+	}
+	replicas := []any{"$MAX_CONNS", "$HOST"}
+	section := &struct{ Timeout any }{"$MAX_CONNS"}
+
+	for _, v := range []any{config, replicas, section} {
+		if err := goenvsubst.Do(v, goenvsubst.WithTypeInference()); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+
+	expected := map[string]any{
+		"max_conns": 10,
+		"debug":     true,
+		"ratio":     0.5,
+		"host":      "db.internal",
+		"port":      5432,
+		"literal":   "10",
+		"number":    float64(3),
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Do() = %#v, want %#v", config, expected)
+	}
+	if want := []any{10, "db.internal"}; !reflect.DeepEqual(replicas, want) {
+		t.Errorf("Do() = %#v, want %#v", replicas, want)
+	}
+	if section.Timeout != 10 {
+		t.Errorf("Timeout = %#v, want 10", section.Timeout)
+	}
+}
+
+func TestDoWithMapKeys(t *testing.T) {
+	os.Setenv("API_HOST", "api.example.com")
+	os.Setenv("WEB_HOST", "www.example.com")
+	os.Setenv("BACKEND", "10.0.0.1")
+	defer func() {
+		os.Unsetenv("API_HOST")
+		os.Unsetenv("WEB_HOST")
+		os.Unsetenv("BACKEND")
+	}()
+
+	type hostName string
+	routes := map[hostName]string{
+		"$API_HOST":   "$BACKEND:8080",
+		"${WEB_HOST}": "$BACKEND:80",
+		"localhost":   "127.0.0.1",
+	}
+	if err := goenvsubst.Do(routes, goenvsubst.WithMapKeys()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := map[hostName]string{
+		"api.example.com": "10.0.0.1:8080",
+		"www.example.com": "10.0.0.1:80",
+		"localhost":       "127.0.0.1",
+	}
+	if !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Do() = %v, want %v", routes, expected)
+	}
+
+	// Keys swapping places do not overwrite each other
+	os.Setenv("A", "b")
+	os.Setenv("B", "a")
+	defer func() {
+		os.Unsetenv("A")
+		os.Unsetenv("B")
+	}()
+	swapped := map[string]int{"$A": 1, "$B": 2}
+	if err := goenvsubst.Do(swapped, goenvsubst.WithMapKeys()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if want := map[string]int{"b": 1, "a": 2}; !reflect.DeepEqual(swapped, want) {
+		t.Errorf("Do() = %v, want %v", swapped, want)
+	}
+
+	colliding := map[string]string{"$API_HOST": "a", "api.example.com": "b"}
+	err := goenvsubst.Do(colliding, goenvsubst.WithMapKeys())
+	if want := `goenvsubst: (root): map keys "$API_HOST" and "api.example.com" both expand to "api.example.com"`; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+	if _, ok := colliding["$API_HOST"]; !ok || len(colliding) != 2 {
+		t.Errorf("Do() modified a map with colliding keys: %v", colliding)
+	}
+
+	unchanged := map[string]string{"$API_HOST": "x"}
+	if err := goenvsubst.Do(unchanged); err != nil || unchanged["$API_HOST"] != "x" {
+		t.Errorf("Do() without WithMapKeys changed keys: %v, %v", unchanged, err)
+	}
+}
+
+// Embedded structs, of an exported and an unexported type
+type (
+	Listener struct{ Addr string }
+	database struct {
+		DSN  string `envsubst:"required"`
+		pool string
+	}
+)
+
+func TestDoEmbeddedStructs(t *testing.T) {
+	vars := goenvsubst.MapResolver{"ADDR": ":8080", "DSN": "postgres://db"}
+
+	type withValues struct {
+		Listener
+		database
+	}
+	values := withValues{Listener{"$ADDR"}, database{DSN: "$DSN", pool: "$DSN"}}
+	if err := goenvsubst.Do(&values, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if values.Addr != ":8080" || values.DSN != "$DSN" {
+		t.Errorf("Do() = %+v, want only the exported embed substituted", values)
+	}
+	if err := goenvsubst.Do(&values, goenvsubst.WithResolver(vars), goenvsubst.WithUnexportedEmbeds()); err != nil {
+		t.Fatalf("Do() with WithUnexportedEmbeds error = %v", err)
+	}
+	if values.DSN != "postgres://db" || values.pool != "$DSN" {
+		t.Errorf("Do() with WithUnexportedEmbeds = %+v, want only the exported field of the unexported embed substituted", values)
+	}
+
+	type withPointers struct {
+		*Listener
+		*database
+	}
+	pointers := withPointers{&Listener{"$ADDR"}, &database{DSN: "$DSN"}}
+	if err := goenvsubst.Do(&pointers, goenvsubst.WithResolver(vars), goenvsubst.WithUnexportedEmbeds()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if pointers.Addr != ":8080" || pointers.DSN != "postgres://db" {
+		t.Errorf("Do() = %+v, %+v, want both embeds substituted", *pointers.Listener, *pointers.database)
+	}
+
+	// Nil embeds are left alone, and tags apply to promoted fields
+	var nils withPointers
+	if err := goenvsubst.Do(&nils, goenvsubst.WithResolver(vars), goenvsubst.WithUnexportedEmbeds()); err != nil {
+		t.Fatalf("Do() with nil embeds error = %v", err)
+	}
+	if nils.Listener != nil || nils.database != nil {
+		t.Errorf("Do() allocated nil embeds: %+v", nils)
+	}
+	err := goenvsubst.Do(&withValues{database: database{DSN: "$MISSING_DSN"}}, goenvsubst.WithResolver(vars), goenvsubst.WithUnexportedEmbeds())
+	if want := "goenvsubst: field database.DSN: MISSING_DSN is not set"; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+}
+
+func TestDoWithSkipTypes(t *testing.T) {
+	type vendorClient struct{ Endpoint string }
+	type rawSQL string
+	type config struct {
+		Client    vendorClient
+		ClientPtr *vendorClient
+		Queries   map[string]rawSQL
+		Values    []any
+		Started   time.Time `env:"STARTED"`
+		URL       string
+	}
+	vars := goenvsubst.MapResolver{"HOST": "db.internal", "STARTED": "2024-01-02T03:04:05Z"}
+
+	c := config{
+		Client:    vendorClient{"$HOST"},
+		ClientPtr: &vendorClient{"$HOST"},
+		Queries:   map[string]rawSQL{"count": "SELECT $HOST"},
+		Values:    []any{vendorClient{"$HOST"}, "$HOST"},
+		URL:       "http://$HOST",
+	}
+	err := goenvsubst.Do(&c, goenvsubst.WithResolver(vars),
+		goenvsubst.WithSkipTypes(reflect.TypeFor[vendorClient](), reflect.TypeFor[rawSQL]()))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := config{
+		Client:    vendorClient{"$HOST"},
+		ClientPtr: &vendorClient{"$HOST"},
+		Queries:   map[string]rawSQL{"count": "SELECT $HOST"},
+		Values:    []any{vendorClient{"$HOST"}, "db.internal"},
+		Started:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		URL:       "http://db.internal",
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Do() = %+v, want %+v", c, expected)
+	}
+}
+
+func TestDoCycles(t *testing.T) {
+	vars := goenvsubst.MapResolver{"HOST": "db.internal"}
+
+	type node struct {
+		Name     string
+		Next     *node
+		Parent   *node
+		Children []*node
+	}
+	root := &node{Name: "$HOST"}
+	child := &node{Name: "child of $HOST", Parent: root}
+	root.Children = []*node{child}
+	root.Next = child
+	child.Next = root
+	if err := goenvsubst.Do(root, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if root.Name != "db.internal" || child.Name != "child of db.internal" {
+		t.Errorf("Do() = %q, %q", root.Name, child.Name)
+	}
+
+	// Maps and slices can refer to themselves through interfaces
+	m := map[string]any{"host": "$HOST"}
+	m["self"] = m
+	s := []any{"$HOST", nil}
+	s[1] = s
+	if err := goenvsubst.Do(&[]any{m, s}, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if m["host"] != "db.internal" || s[0] != "db.internal" {
+		t.Errorf("Do() = %v, %v", m["host"], s[0])
+	}
+
+	// A shared value is substituted once, so an escaped $ stays escaped
+	shared := &section{"$$HOST"}
+	pair := struct{ A, B *section }{shared, shared}
+	if err := goenvsubst.Do(&pair, goenvsubst.WithResolver(vars)); err != nil || shared.Host != "$HOST" {
+		t.Errorf("Do() = %q, %v, want %q", shared.Host, err, "$HOST")
+	}
+}
+
+func TestDoWithMaxDepth(t *testing.T) {
+	var tree any
+	if err := json.Unmarshal([]byte(`{"a": {"b": [{"c": "$HOST"}]}}`), &tree); err != nil {
+		t.Fatal(err)
+	}
+	vars := goenvsubst.WithResolver(goenvsubst.MapResolver{"HOST": "db.internal"})
+
+	if err := goenvsubst.Do(&tree, vars, goenvsubst.WithMaxDepth(4)); err != nil {
+		t.Errorf("Do() within the limit error = %v", err)
+	}
+	err := goenvsubst.Do(&tree, vars, goenvsubst.WithMaxDepth(3))
+	var depthErr *goenvsubst.DepthError
+	if !errors.As(err, &depthErr) || depthErr.Max != 3 {
+		t.Fatalf("Do() error = %v, want a *DepthError", err)
+	}
+	if want := "goenvsubst: [a][b][0]: value nests deeper than 3 levels"; err.Error() != want {
+		t.Errorf("Do() error = %q, want %q", err, want)
+	}
+}
+
+func TestDoWithValidator(t *testing.T) {
+	vars := goenvsubst.MapResolver{"API_URL": "://bad", "HOST": "db.internal"}
+	validURL := func(path, varName, value string) error {
+		if !strings.HasSuffix(varName, "_URL") {
+			return nil
+		}
+		_, err := url.ParseRequestURI(value)
+		return err
+	}
+	notEmpty := func(path, varName, value string) error {
+		if value == "" {
+			return errors.New("must not be empty")
+		}
+		return nil
+	}
+	opts := []goenvsubst.Option{goenvsubst.WithResolver(vars), goenvsubst.WithValidator(notEmpty), goenvsubst.WithValidator(validURL)}
+
+	config := struct{ Host, API, Port string }{"$HOST", "$API_URL", "${PORT:-8080}"}
+	err := goenvsubst.Do(&config, opts...)
+	var validationErr *goenvsubst.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Name != "API_URL" {
+		t.Fatalf("Do() error = %v, want a *ValidationError for API_URL", err)
+	}
+	if want := `goenvsubst: API: invalid value of API_URL: parse "://bad": missing protocol scheme`; err.Error() != want {
+		t.Errorf("Do() error = %q, want %q", err, want)
+	}
+
+	issues, err := goenvsubst.Check(&struct{ Host, API, Missing string }{"$HOST", "$API_URL", "$MISSING_VAR"}, opts...)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`API: invalid value of API_URL: parse "://bad": missing protocol scheme`,
+		"Missing: MISSING_VAR is not set",
+		"Missing: invalid value of MISSING_VAR: must not be empty",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() issues = %q, want %q", got, want)
+	}
+}
+
+func TestDoWithPrefix(t *testing.T) {
+	vars := goenvsubst.MapResolver{"MYAPP_DB_HOST": "myapp-db", "DB_HOST": "shared-db", "LOG_LEVEL": "info"}
+
+	config := &struct{ Host, Level, Port string }{"$DB_HOST", "$LOG_LEVEL", "${PORT:-8080}"}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithPrefix("MYAPP_")); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Host != "myapp-db" || config.Level != "info" || config.Port != "8080" {
+		t.Errorf("Do() with WithPrefix = %+v", config)
+	}
+
+	config = &struct{ Host, Level, Port string }{"$DB_HOST", "$LOG_LEVEL", "${PORT:-8080}"}
+	err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithPrefixOnly("MYAPP_"), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+	if want := []string{"LOG_LEVEL"}; !reflect.DeepEqual(unsetErr.Names, want) {
+		t.Errorf("Names = %v, want %v", unsetErr.Names, want)
+	}
+	if config.Host != "myapp-db" {
+		t.Errorf("Host = %q, want %q", config.Host, "myapp-db")
+	}
+}
+
+func TestDoWithProfile(t *testing.T) {
+	vars := goenvsubst.MapResolver{"DB_HOST_PROD": "prod-db", "DB_HOST": "local-db", "LOG_LEVEL": "info"}
+
+	config := &struct{ Host, Level string }{"$DB_HOST", "$LOG_LEVEL"}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithProfile("prod")); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Host != "prod-db" || config.Level != "info" {
+		t.Errorf("Do() with WithProfile = %+v", config)
+	}
+
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"PROD", "prod-db"},
+		{"staging", "local-db"},
+		{"", "local-db"},
+	}
+	for _, tt := range tests {
+		vars := goenvsubst.MapResolver{"DB_HOST_PROD": "prod-db", "DB_HOST": "local-db", "APP_PROFILE": tt.profile}
+		host := "$DB_HOST"
+		if err := goenvsubst.Do(&host, goenvsubst.WithResolver(vars), goenvsubst.WithProfileFrom("APP_PROFILE")); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		if host != tt.want {
+			t.Errorf("APP_PROFILE=%q: DB_HOST = %q, want %q", tt.profile, host, tt.want)
+		}
+	}
+}
+
+func TestDoWithCaseInsensitive(t *testing.T) {
+	os.Setenv("GOENVSUBST_TEST_HOME", "/home/test")
+	defer os.Unsetenv("GOENVSUBST_TEST_HOME")
+
+	config := &struct{ Home, Missing string }{"$GoEnvSubst_Test_Home", "$GOENVSUBST_TEST_MISSING"}
+	if err := goenvsubst.Do(config, goenvsubst.WithCaseInsensitive()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Home != "/home/test" || config.Missing != "" {
+		t.Errorf("Do() = %+v", config)
+	}
+
+	// The exact spelling wins, other spellings are tried in a stable order
+	vars := goenvsubst.MapResolver{"path": "lower", "Path": "mixed", "PATH": "upper"}
+	for name, want := range map[string]string{"Path": "mixed", "pATH": "upper"} {
+		got, err := goenvsubst.ExpandWith("$"+name, vars, goenvsubst.WithCaseInsensitive())
+		if err != nil || got != want {
+			t.Errorf("ExpandWith($%s) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	lookup := func(name string) (string, bool) {
+		if name == "TOKEN" {
+			return "secret", true
+		}
+		return "", false
+	}
+	got, err := goenvsubst.Expand("$token", goenvsubst.WithLookup(lookup), goenvsubst.WithCaseInsensitive())
+	if err != nil || got != "secret" {
+		t.Errorf("Expand() = %q, %v, want %q", got, err, "secret")
+	}
+}
+
+func TestDoWithAllowlist(t *testing.T) {
+	vars := goenvsubst.MapResolver{"APP_HOST": "db", "PORT": "5432", "AWS_SECRET_ACCESS_KEY": "secret", "APP_TOKEN": "token"}
+	opts := []goenvsubst.Option{
+		goenvsubst.WithResolver(vars),
+		goenvsubst.WithAllowlist("APP_*", "PORT"),
+		goenvsubst.WithDenylist("*_TOKEN"),
+	}
+
+	config := &struct{ Host, Port string }{"$APP_HOST", "${PORT}"}
+	if err := goenvsubst.Do(config, opts...); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Host != "db" || config.Port != "5432" {
+		t.Errorf("Do() = %+v", config)
+	}
+
+	for _, input := range []string{"$AWS_SECRET_ACCESS_KEY", "${APP_TOKEN:-x}", "${APP_MISSING:-$AWS_SECRET_ACCESS_KEY}"} {
+		config := &struct{ Value string }{input}
+		err := goenvsubst.Do(config, opts...)
+		var deniedErr *goenvsubst.DeniedError
+		if !errors.As(err, &deniedErr) {
+			t.Errorf("Do(%q) error = %v, want *DeniedError", input, err)
+		}
+		if config.Value != input {
+			t.Errorf("Do(%q) modified the value to %q", input, config.Value)
+		}
+	}
+
+	err := goenvsubst.Do(&struct{ Value string }{"$AWS_SECRET_ACCESS_KEY"}, goenvsubst.WithDenylist("AWS_*"))
+	if want := "goenvsubst: Value: variable AWS_SECRET_ACCESS_KEY is not allowed"; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+
+	err = goenvsubst.Do(&struct{ Value string }{"$PORT"}, goenvsubst.WithAllowlist("[PORT"))
+	if err == nil {
+		t.Error("Do() with a malformed pattern succeeded")
+	}
+}
+
+func TestDoWithRecursion(t *testing.T) {
+	vars := goenvsubst.MapResolver{
+		"FULL_URL": "https://$HOST/${URL_PATH}",
+		"HOST":     "$DB_HOST:$PORT",
+		"DB_HOST":  "db",
+		"PORT":     "5432",
+		"URL_PATH": "app",
+		"PRICE":    "$$5",
+		"A":        "x$B",
+		"B":        "${C:-y}",
+		"C":        "$A",
+	}
+
+	config := &struct{ URL, Price, Plain string }{"$FULL_URL", "$PRICE", "$DB_HOST"}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithRecursion(5)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := &struct{ URL, Price, Plain string }{"https://db:5432/app", "$5", "db"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Do() = %+v, want %+v", config, expected)
+	}
+
+	// Without the option values are used as they are
+	config = &struct{ URL, Price, Plain string }{"$FULL_URL", "$PRICE", "$DB_HOST"}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.URL != "https://$HOST/${URL_PATH}" {
+		t.Errorf("URL = %q, want it unexpanded", config.URL)
+	}
+
+	err := goenvsubst.Do(&struct{ Value string }{"$A"}, goenvsubst.WithResolver(vars), goenvsubst.WithRecursion(5))
+	if want := "goenvsubst: Value: variables refer to each other: A -> B -> C -> A"; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+	var cycleErr *goenvsubst.CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Do() error = %v, want *CycleError", err)
+	}
+
+	// The chain starts where the cycle does
+	vars["START"] = "$B"
+	_, err = goenvsubst.Expand("$START", goenvsubst.WithResolver(vars), goenvsubst.WithRecursion(5))
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Expand() error = %v, want *CycleError", err)
+	}
+	if want := []string{"B", "C", "A", "B"}; !reflect.DeepEqual(cycleErr.Chain, want) {
+		t.Errorf("Chain = %v, want %v", cycleErr.Chain, want)
+	}
+
+	// Values without references do not count towards the limit
+	if _, err := goenvsubst.Expand("$FULL_URL", goenvsubst.WithResolver(vars), goenvsubst.WithRecursion(2)); err != nil {
+		t.Errorf("Expand() error = %v", err)
+	}
+	_, err = goenvsubst.Expand("$FULL_URL", goenvsubst.WithResolver(vars), goenvsubst.WithRecursion(1))
+	if want := "goenvsubst: variables nest deeper than 1: FULL_URL -> HOST"; err == nil || err.Error() != want {
+		t.Errorf("Expand() error = %v, want %s", err, want)
+	}
+}
+
+func TestDoWithMaxOutputSize(t *testing.T) {
+	// Every level repeats the one below ten times, for 3 GB at LOL9
+	vars := goenvsubst.MapResolver{"LOL0": "lol"}
+	for i := 1; i < 10; i++ {
+		vars[fmt.Sprintf("LOL%d", i)] = strings.Repeat(fmt.Sprintf("$LOL%d", i-1), 10)
+	}
+	value := struct{ Laughs string }{"$LOL9"}
+	err := goenvsubst.Do(&value, goenvsubst.WithResolver(vars), goenvsubst.WithRecursion(10), goenvsubst.WithMaxOutputSize(1<<16))
+	var sizeErr *goenvsubst.OutputSizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Max != 1<<16 {
+		t.Fatalf("Do() error = %v, want an *OutputSizeError", err)
+	}
+	if want := "goenvsubst: Laughs: expansion exceeds the maximum size of 65536 bytes"; err.Error() != want {
+		t.Errorf("Do() error = %q, want %q", err, want)
+	}
+
+	// Literal text counts too
+	pair := goenvsubst.WithResolver(goenvsubst.MapResolver{"A": "123456"})
+	if got, err := goenvsubst.Expand("$A-$A", pair, goenvsubst.WithMaxOutputSize(13)); err != nil || got != "123456-123456" {
+		t.Errorf("Expand() = %q, %v", got, err)
+	}
+	if _, err := goenvsubst.Expand("$A-$A", pair, goenvsubst.WithMaxOutputSize(12)); !errors.As(err, &sizeErr) {
+		t.Errorf("Expand() error = %v, want an *OutputSizeError", err)
+	}
+}
+
+func TestDoErrorPath(t *testing.T) {
+	os.Setenv("BAD_PORT", "http")
+	defer os.Unsetenv("BAD_PORT")
+
+	type Replica struct{ DSN string }
+	type Config struct {
+		Database struct {
+			Replicas []Replica
+		}
+		Labels map[string][]string
+	}
+
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		path    string
+		errText string
+	}{
+		{
+			name: "syntax error in a nested slice",
+			config: func(c *Config) {
+				c.Database.Replicas = []Replica{{}, {}, {DSN: "postgres://${DB_HOST"}}
+			},
+			path:    "Database.Replicas[2].DSN",
+			errText: "goenvsubst: Database.Replicas[2].DSN: syntax error at 1:12: unterminated ${",
+		},
+		{
+			name: "required variable in a map",
+			config: func(c *Config) {
+				c.Labels = map[string][]string{"team": {"${TEAM:?team label}"}}
+			},
+			path:    "Labels[team][0]",
+			errText: "goenvsubst: Labels[team][0]: TEAM: team label",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config Config
+			tt.config(&config)
+			err := goenvsubst.Do(&config)
+
+			var pathErr *goenvsubst.PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("Do() error = %v, want *PathError", err)
+			}
+			if pathErr.Path != tt.path {
+				t.Errorf("Path = %q, want %q", pathErr.Path, tt.path)
+			}
+			if err.Error() != tt.errText {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.errText)
+			}
+		})
+	}
+
+	// Conversion errors name the full path of the field
+	type Server struct {
+		Port int `env:"BAD_PORT"`
+	}
+	err := goenvsubst.Do(&struct{ Servers map[string]Server }{map[string]Server{"api": {}}})
+	if want := `goenvsubst: field Servers[api].Port: parse int: strconv.ParseInt: parsing "http": invalid syntax`; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+}
+
+func TestDoRequiredVariable(t *testing.T) {
+	os.Setenv("EMPTY_VAR", "")
+	os.Setenv("CONTEXT", "auth")
+	defer func() {
+		os.Unsetenv("EMPTY_VAR")
+		os.Unsetenv("CONTEXT")
+	}()
+
+	tests := []struct {
+		name    string
+		input   string
+		message string
+		errText string
+	}{
+		{"unset", "${JWT_SECRET:?jwt secret must be set}", "jwt secret must be set", "goenvsubst: Value: JWT_SECRET: jwt secret must be set"},
+		{"empty with colon", "${EMPTY_VAR:?empty}", "empty", "goenvsubst: Value: EMPTY_VAR: empty"},
+		{"unset without colon", "${JWT_SECRET?}", "", "goenvsubst: Value: JWT_SECRET is not set"},
+		{"message with reference", "${JWT_SECRET:?needed by $CONTEXT}", "needed by auth", "goenvsubst: Value: JWT_SECRET: needed by auth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &struct{ Value string }{tt.input}
+			err := goenvsubst.Do(config)
+
+			var requiredErr *goenvsubst.RequiredError
+			if !errors.As(err, &requiredErr) {
+				t.Fatalf("Do() error = %v, want *RequiredError", err)
+			}
+			if requiredErr.Message != tt.message {
+				t.Errorf("Message = %q, want %q", requiredErr.Message, tt.message)
+			}
+			if err.Error() != tt.errText {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.errText)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain and braced", "$TEST_VAR/${TEST_VAR}", "test_value/test_value"},
+		{"default", "${MISSING_VAR:-fallback}", "fallback"},
+		{"escape", "$$TEST_VAR", "$TEST_VAR"},
+		{"no references", "static", "static"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := goenvsubst.Expand(tt.input)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expand() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	var syntaxErr *goenvsubst.SyntaxError
+	if _, err := goenvsubst.Expand("${TEST_VAR"); !errors.As(err, &syntaxErr) {
+		t.Errorf("Expand() error = %v, want *SyntaxError", err)
+	}
+	var unsetErr *goenvsubst.UnsetError
+	if _, err := goenvsubst.Expand("$MISSING_VAR", goenvsubst.Strict()); !errors.As(err, &unsetErr) {
+		t.Errorf("Expand() error = %v, want *UnsetError", err)
+	}
+	// A bare string has no path worth naming
+	if _, err := goenvsubst.Expand("$MISSING_A ${MISSING_B}", goenvsubst.Strict()); err == nil || err.Error() != "goenvsubst: unset variables: MISSING_A, MISSING_B" {
+		t.Errorf("Expand() error = %v, want the names alone", err)
+	}
+}
+
+func TestExpandWith(t *testing.T) {
+	os.Setenv("TEST_VAR", "from_env")
+	defer os.Unsetenv("TEST_VAR")
+
+	got, err := goenvsubst.ExpandWith("${TEST_VAR}-${OTHER:-x}", goenvsubst.MapResolver{"TEST_VAR": "from_map"})
+	if err != nil {
+		t.Fatalf("ExpandWith() error = %v", err)
+	}
+	if got != "from_map-x" {
+		t.Errorf("ExpandWith() = %q, want %q", got, "from_map-x")
+	}
+}
@@ -0,0 +1,74 @@
+package goenvsubst
+
+import (
+	"reflect"
+	"sync"
+)
+
+// handlers substitute values of the types registered with RegisterHandler,
+// keyed by type
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[reflect.Type]func(v reflect.Value, resolve Resolver) error{}
+)
+
+// RegisterHandler makes every call substitute values of type t with fn
+// instead of descending into them, for types that the built-in handling of
+// kinds cannot reach, such as wrappers with unexported fields, option types
+// or protobuf well-known types. fn receives the value, which can be set
+// unless it was passed to Do directly, and a Resolver that looks variables
+// up as references do, honoring the options of the call such as
+// WithResolver, WithPrefix and WithAllowlist. An error returned by fn fails
+// the call like any other substitution error. Check calls fn with a copy of
+// the value, so values it sets are discarded.
+//
+// t is matched exactly, so a handler for a struct type applies to its values
+// behind pointers as well, and one for a pointer type only to the pointers.
+// Registering a type again replaces its handler, and a nil fn removes it.
+// RegisterHandler is meant to be called from init functions; it is safe
+// for concurrent use.
+func RegisterHandler(t reflect.Type, fn func(v reflect.Value, resolve Resolver) error) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if fn == nil {
+		delete(handlers, t)
+		return
+	}
+	handlers[t] = fn
+}
+
+// handlerFor returns the handler registered for t, or nil
+func handlerFor(t reflect.Type) func(v reflect.Value, resolve Resolver) error {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return handlers[t]
+}
+
+// doHandler substitutes v with the handler registered for its type, and
+// reports whether there is one
+func (w *walker) doHandler(v reflect.Value, path string) (bool, error) {
+	fn := handlerFor(v.Type())
+	if fn == nil {
+		return false, nil
+	}
+
+	target := v
+	if w.checking() {
+		target = reflect.New(v.Type()).Elem()
+		target.Set(v)
+	}
+	resolve := ResolverFunc(func(name string) (string, bool, error) {
+		permitted, err := w.permits(name)
+		if err != nil {
+			return "", false, err
+		}
+		if !permitted {
+			return "", false, &DeniedError{Name: name}
+		}
+		return w.resolve(name)
+	})
+	if err := fn(target, resolve); err != nil {
+		return true, w.fail(path, &PathError{Path: displayPath(path), Err: err})
+	}
+	return true, nil
+}
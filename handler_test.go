@@ -0,0 +1,84 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// envRef names a variable and holds its value once resolved
+type envRef struct{ name, value string }
+
+func newEnvRef(name string) envRef { return envRef{name: name} }
+
+func registerEnvRef(t *testing.T) {
+	goenvsubst.RegisterHandler(reflect.TypeFor[envRef](), func(v reflect.Value, resolve goenvsubst.Resolver) error {
+		ref := v.Addr().Interface().(*envRef)
+		value, ok, err := resolve.Resolve(ref.name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%s is not set", ref.name)
+		}
+		ref.value = value
+		return nil
+	})
+	t.Cleanup(func() { goenvsubst.RegisterHandler(reflect.TypeFor[envRef](), nil) })
+}
+
+func TestRegisterHandler(t *testing.T) {
+	registerEnvRef(t)
+	vars := goenvsubst.MapResolver{"DB_PASSWORD": "hunter2", "API_TOKEN": "t0ken"}
+
+	config := struct {
+		Password envRef
+		Token    *envRef
+		Refs     []envRef
+		ByName   map[string]envRef
+	}{
+		Password: newEnvRef("DB_PASSWORD"),
+		Token:    &envRef{name: "API_TOKEN"},
+		Refs:     []envRef{newEnvRef("API_TOKEN")},
+		ByName:   map[string]envRef{"db": newEnvRef("DB_PASSWORD")},
+	}
+	if err := goenvsubst.Do(&config, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Password.value != "hunter2" || config.Token.value != "t0ken" ||
+		config.Refs[0].value != "t0ken" || config.ByName["db"].value != "hunter2" {
+		t.Errorf("Do() = %+v", config)
+	}
+
+	missing := struct{ Password envRef }{newEnvRef("MISSING_VAR")}
+	err := goenvsubst.Do(&missing, goenvsubst.WithResolver(vars))
+	if want := "goenvsubst: Password: MISSING_VAR is not set"; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+
+	// The resolver honors the options of the call
+	err = goenvsubst.Do(&config, goenvsubst.WithResolver(vars), goenvsubst.WithAllowlist("API_*"))
+	var denied *goenvsubst.DeniedError
+	if !errors.As(err, &denied) || denied.Name != "DB_PASSWORD" {
+		t.Errorf("Do() with WithAllowlist error = %v, want a *DeniedError for DB_PASSWORD", err)
+	}
+}
+
+func TestRegisterHandlerCheck(t *testing.T) {
+	registerEnvRef(t)
+
+	config := struct{ Password, Token envRef }{newEnvRef("DB_PASSWORD"), newEnvRef("MISSING_VAR")}
+	issues, err := goenvsubst.Check(&config, goenvsubst.WithResolver(goenvsubst.MapResolver{"DB_PASSWORD": "hunter2"}))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Path != "Token" {
+		t.Errorf("Check() = %v, want one issue at Token", issues)
+	}
+	if config.Password.value != "" {
+		t.Errorf("Check() modified the value: %+v", config.Password)
+	}
+}
@@ -0,0 +1,258 @@
+package goenvsubst
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DoRequest replaces environment variable references in an http.Request
+// template before it is sent: the URL (user info, host, path, query values
+// and fragment), header values and basic-auth credentials. A URL that is a
+// single reference, such as one built with http.NewRequest("GET",
+// "$API_URL", nil), is re-parsed after substitution, and req.Host follows
+// the new URL host unless it was set explicitly. opts apply as in Do.
+func DoRequest(req *http.Request, opts ...Option) error {
+	if req == nil {
+		return nil
+	}
+
+	w := New(opts...).walker()
+	if req.URL != nil {
+		u, err := w.expandURL(req.URL)
+		if err != nil {
+			return err
+		}
+		if req.Host == "" || req.Host == req.URL.Host {
+			req.Host = u.Host
+		}
+		req.URL = u
+	}
+
+	if err := w.doValue(reflect.ValueOf(map[string][]string(req.Header)), "Header"); err != nil {
+		return err
+	}
+
+	if username, password, ok := req.BasicAuth(); ok {
+		username, err := w.expand(username, "BasicAuth.Username")
+		if err != nil {
+			return err
+		}
+		password, err := w.expand(password, "BasicAuth.Password")
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(username, password)
+	}
+	return w.finish()
+}
+
+// DoHeader replaces environment variable references in all values of h.
+// Header names are never modified. opts apply as in Do.
+func DoHeader(h http.Header, opts ...Option) error {
+	return Do(map[string][]string(h), opts...)
+}
+
+// expandURL returns a copy of u with references expanded in every component
+// that may carry them
+func (w *walker) expandURL(u *url.URL) (*url.URL, error) {
+	out := *u
+
+	if u.User != nil {
+		username, err := w.expand(u.User.Username(), "URL.User.Username")
+		if err != nil {
+			return nil, err
+		}
+		if password, ok := u.User.Password(); ok {
+			password, err := w.expand(password, "URL.User.Password")
+			if err != nil {
+				return nil, err
+			}
+			out.User = url.UserPassword(username, password)
+		} else {
+			out.User = url.User(username)
+		}
+	}
+
+	components := []struct {
+		path  string
+		value *string
+	}{{"URL.Host", &out.Host}, {"URL.Path", &out.Path}, {"URL.Fragment", &out.Fragment}}
+	for _, c := range components {
+		expanded, err := w.expand(*c.value, c.path)
+		if err != nil {
+			return nil, err
+		}
+		*c.value = expanded
+	}
+	out.RawPath, out.RawFragment = "", ""
+
+	if u.RawQuery != "" {
+		// Malformed queries are left as they are rather than dropped
+		if query, err := url.ParseQuery(u.RawQuery); err == nil {
+			before := query.Encode()
+			if err := w.doValue(reflect.ValueOf(map[string][]string(query)), "URL.Query"); err != nil {
+				return nil, err
+			}
+			// Re-encode only when something changed, to keep the original order
+			if after := query.Encode(); after != before {
+				out.RawQuery = after
+			}
+		}
+	}
+
+	// A relative URL whose path was a reference may now hold a whole URL
+	if u.Scheme == "" && u.Host == "" && u.Opaque == "" && out.Path != u.Path {
+		s := out.Path
+		if out.RawQuery != "" {
+			s += "?" + out.RawQuery
+		}
+		if out.Fragment != "" {
+			s += "#" + url.PathEscape(out.Fragment)
+		}
+		return url.Parse(s)
+	}
+	return &out, nil
+}
+
+// defaultHTTPTimeout limits the requests of an HTTPResolver without a
+// Timeout
+const defaultHTTPTimeout = 10 * time.Second
+
+// maxHTTPValueSize limits the size of the values an HTTPResolver reads
+const maxHTTPValueSize = 1 << 20
+
+// HTTPResolver fetches values from an HTTP endpoint, such as an internal
+// configuration service: a name is a path relative to BaseURL, and resolves
+// to the body of the response to a GET request, without the trailing
+// newline. Give it a scheme to reference values as ${http:feature/flags/foo}:
+//
+//	goenvsubst.WithScheme("http", &goenvsubst.HTTPResolver{BaseURL: "https://config.internal/v1/"})
+//
+// A name must not hold empty, "." or ".." segments, so it cannot leave the
+// path of BaseURL. A 404 response means the value is unset; other responses
+// besides 200, and values larger than 1 MiB, make the call fail. Values are
+// cached for TTL, or for the lifetime of the resolver if TTL is zero. An
+// HTTPResolver must not be copied after first use; its methods are safe for
+// concurrent use.
+type HTTPResolver struct {
+	// BaseURL is the URL that names are resolved against.
+	BaseURL string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Auth, if set, is called with every request before it is sent, to add
+	// credentials such as an Authorization header. An error fails the call.
+	Auth func(req *http.Request) error
+	// Timeout limits each attempt of a request; zero means 10 seconds.
+	Timeout time.Duration
+	// Retries is how many times a request is retried after a network error
+	// or a 429 or 5xx response, waiting 100ms and then twice as long before
+	// each retry.
+	Retries int
+	// TTL limits how long values are cached; zero caches them for the
+	// lifetime of the resolver.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]httpValue
+}
+
+// httpValue is a value an HTTPResolver fetched
+type httpValue struct {
+	value   string
+	found   bool
+	fetched time.Time
+}
+
+// Resolve fetches the value at the path name.
+func (h *HTTPResolver) Resolve(name string) (string, bool, error) {
+	// The lock only guards the cache, so slow requests for different names
+	// do not wait for each other
+	h.mu.Lock()
+	v, ok := h.cache[name]
+	h.mu.Unlock()
+	if ok && (h.TTL <= 0 || time.Since(v.fetched) < h.TTL) {
+		return v.value, v.found, nil
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", false, fmt.Errorf("invalid path %q", name)
+		}
+	}
+	target, err := url.JoinPath(h.BaseURL, name)
+	if err != nil {
+		return "", false, err
+	}
+	v, retryable, err := h.fetch(target)
+	for retry, wait := 0, 100*time.Millisecond; retryable && retry < h.Retries; retry, wait = retry+1, 2*wait {
+		time.Sleep(wait)
+		v, retryable, err = h.fetch(target)
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cache == nil {
+		h.cache = map[string]httpValue{}
+	}
+	h.cache[name] = v
+	return v.value, v.found, nil
+}
+
+// fetch sends a single request for target, and reports whether a failure
+// may not recur if the request is retried
+func (h *HTTPResolver) fetch(target string) (httpValue, bool, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return httpValue{}, false, err
+	}
+	if h.Auth != nil {
+		if err := h.Auth(req); err != nil {
+			return httpValue{}, false, fmt.Errorf("authenticate: %w", err)
+		}
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return httpValue{}, true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return httpValue{fetched: time.Now()}, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return httpValue{}, true, fmt.Errorf("GET %s: %s", target, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return httpValue{}, false, fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	// Read one byte more than allowed to tell a value at the limit from one
+	// that exceeds it
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPValueSize+1))
+	if err != nil {
+		return httpValue{}, true, err
+	}
+	if len(body) > maxHTTPValueSize {
+		return httpValue{}, false, fmt.Errorf("GET %s: value larger than %d bytes", target, maxHTTPValueSize)
+	}
+	return httpValue{value: trimNewline(string(body)), found: true, fetched: time.Now()}, false, nil
+}
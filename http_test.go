@@ -0,0 +1,189 @@
+package goenvsubst_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func setHTTPEnv(t *testing.T) {
+	t.Helper()
+	vars := map[string]string{
+		"API_URL":   "https://api.example.com/v1/users?limit=10",
+		"API_HOST":  "api.example.com",
+		"API_TOKEN": "secret-token",
+		"API_USER":  "admin",
+		"API_PASS":  "p@ss",
+		"TENANT":    "acme",
+	}
+	for k, v := range vars {
+		os.Setenv(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range vars {
+			os.Unsetenv(k)
+		}
+	})
+}
+
+func TestDoRequestWholeURL(t *testing.T) {
+	setHTTPEnv(t)
+
+	req, err := http.NewRequest(http.MethodGet, "$API_URL", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "$API_TOKEN")
+	req.Header.Set("X-Static", "static")
+
+	if err := goenvsubst.DoRequest(req); err != nil {
+		t.Fatalf("DoRequest() error = %v", err)
+	}
+
+	if got := req.URL.String(); got != "https://api.example.com/v1/users?limit=10" {
+		t.Errorf("URL = %q", got)
+	}
+	if req.Host != "api.example.com" {
+		t.Errorf("Host = %q, want %q", req.Host, "api.example.com")
+	}
+	if got := req.Header.Get("Authorization"); got != "secret-token" {
+		t.Errorf("Authorization = %q, want %q", got, "secret-token")
+	}
+	if got := req.Header.Get("X-Static"); got != "static" {
+		t.Errorf("X-Static = %q, want %q", got, "static")
+	}
+}
+
+func TestDoRequestURLComponents(t *testing.T) {
+	setHTTPEnv(t)
+
+	req, err := http.NewRequest(http.MethodGet, "https://$API_HOST/$TENANT?tenant=$TENANT&page=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("$API_USER", "$API_PASS")
+
+	if err := goenvsubst.DoRequest(req); err != nil {
+		t.Fatalf("DoRequest() error = %v", err)
+	}
+
+	if req.URL.Host != "api.example.com" || req.Host != "api.example.com" {
+		t.Errorf("URL.Host = %q, Host = %q", req.URL.Host, req.Host)
+	}
+	if req.URL.Path != "/acme" {
+		t.Errorf("Path = %q, want %q", req.URL.Path, "/acme")
+	}
+	if got := req.URL.Query().Get("tenant"); got != "acme" {
+		t.Errorf("tenant query = %q, want %q", got, "acme")
+	}
+	if got := req.URL.Query().Get("page"); got != "2" {
+		t.Errorf("page query = %q, want %q", got, "2")
+	}
+
+	username, password, ok := req.BasicAuth()
+	if !ok || username != "admin" || password != "p@ss" {
+		t.Errorf("BasicAuth() = %q, %q, %v", username, password, ok)
+	}
+}
+
+func TestDoRequestKeepsExplicitHost(t *testing.T) {
+	setHTTPEnv(t)
+
+	req, err := http.NewRequest(http.MethodGet, "https://$API_HOST/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "virtual.example.com"
+
+	if err := goenvsubst.DoRequest(req); err != nil {
+		t.Fatalf("DoRequest() error = %v", err)
+	}
+	if req.Host != "virtual.example.com" {
+		t.Errorf("Host = %q, want %q", req.Host, "virtual.example.com")
+	}
+}
+
+func TestDoHeader(t *testing.T) {
+	setHTTPEnv(t)
+
+	h := http.Header{
+		"Authorization": {"$API_TOKEN"},
+		"X-Tenant":      {"$TENANT", "static"},
+	}
+	if err := goenvsubst.DoHeader(h); err != nil {
+		t.Fatalf("DoHeader() error = %v", err)
+	}
+
+	if got := h.Get("Authorization"); got != "secret-token" {
+		t.Errorf("Authorization = %q, want %q", got, "secret-token")
+	}
+	if got := h.Values("X-Tenant"); len(got) != 2 || got[0] != "acme" || got[1] != "static" {
+		t.Errorf("X-Tenant = %q", got)
+	}
+}
+
+func TestHTTPResolver(t *testing.T) {
+	var requests, failures int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/feature/flags/foo":
+			fmt.Fprintln(w, "on")
+		case "/v1/large":
+			w.Write(make([]byte, 1<<20+1))
+		case "/v1/flaky":
+			if failures++; failures < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "recovered")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	resolver := &goenvsubst.HTTPResolver{
+		BaseURL: server.URL + "/v1/",
+		Auth: func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer t0k3n")
+			return nil
+		},
+		Retries: 2,
+	}
+	config := &struct{ Foo, Again, Flaky, Missing string }{
+		"${http:feature/flags/foo}", "${http:feature/flags/foo}", "${http:flaky}", "${http:feature/flags/bar:-off}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("http", resolver)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Foo != "on" || config.Again != "on" || config.Flaky != "recovered" || config.Missing != "off" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if requests != 5 {
+		t.Errorf("requests = %d, want one per value plus two retries", requests)
+	}
+
+	for _, name := range []string{"../admin", "feature/../../admin", "/etc/passwd", "feature//foo", "./foo", "feature/"} {
+		if _, _, err := resolver.Resolve(name); err == nil || !strings.Contains(err.Error(), "invalid path") {
+			t.Errorf("Resolve(%q) error = %v, want an invalid path", name, err)
+		}
+	}
+	if _, _, err := resolver.Resolve("large"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Resolve() of a large value error = %v", err)
+	}
+
+	unauthenticated := &goenvsubst.HTTPResolver{BaseURL: server.URL + "/v1/"}
+	if _, _, err := unauthenticated.Resolve("feature/flags/foo"); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("Resolve() without credentials error = %v", err)
+	}
+}
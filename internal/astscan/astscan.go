@@ -0,0 +1,141 @@
+// Package astscan holds what the scan command and the analyzer share to find
+// the uses of goenvsubst in Go source, so that both find the same entry
+// points and read struct tags the same way.
+package astscan
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// PkgPath is the import path of the package whose uses are found
+const PkgPath = "github.com/iamolegga/goenvsubst"
+
+var (
+	// StructFuncs are the functions of the package that take a structure
+	// to substitute or check first
+	StructFuncs = []string{"Do", "DoPtr", "DoCopy", "DoWithMap", "DoWithReport", "Check"}
+	// StringFuncs are the functions of the package that take a template
+	// first
+	StringFuncs = []string{"Expand", "ExpandWith"}
+)
+
+// The methods of an Expander that take a structure and a template first
+const (
+	StructMethod = "Do"
+	StringMethod = "Expand"
+)
+
+// Unwrap returns the expression behind & and parentheses.
+func Unwrap(expr ast.Expr) ast.Expr {
+	for {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.UnaryExpr:
+			if e.Op != token.AND {
+				return expr
+			}
+			expr = e.X
+		default:
+			return expr
+		}
+	}
+}
+
+// UnwrapGeneric returns the function behind an explicit instantiation such
+// as goenvsubst.DoCopy[Config].
+func UnwrapGeneric(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		return e.X
+	case *ast.IndexListExpr:
+		return e.X
+	}
+	return expr
+}
+
+// Assignments calls fn with every variable that n, an assignment or a
+// variable declaration, gives a value, and that value.
+func Assignments(n ast.Node, fn func(name *ast.Ident, value ast.Expr)) {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if len(n.Lhs) != len(n.Rhs) {
+			return
+		}
+		for i, lhs := range n.Lhs {
+			if ident, ok := lhs.(*ast.Ident); ok {
+				fn(ident, n.Rhs[i])
+			}
+		}
+	case *ast.ValueSpec:
+		if len(n.Names) != len(n.Values) {
+			return
+		}
+		for i, ident := range n.Names {
+			fn(ident, n.Values[i])
+		}
+	}
+}
+
+// TagTemplates returns the templates of a struct tag that reference
+// variables: the variable of an env tag that is a bare name, the variable
+// an envwhen tag tests, and the whole envsubst tag, whose template and
+// default hold references while its other options hold none.
+func TagTemplates(tag reflect.StructTag) []string {
+	var templates []string
+	if name, ok := tag.Lookup("env"); ok && isName(name) {
+		templates = append(templates, "${"+name+"}")
+	}
+	if when, ok := tag.Lookup("envwhen"); ok {
+		if name, _, found := strings.Cut(when, "="); found && isName(strings.TrimSpace(name)) {
+			templates = append(templates, "${"+strings.TrimSpace(name)+"}")
+		}
+	}
+	if options, ok := tag.Lookup("envsubst"); ok && options != "-" {
+		templates = append(templates, options)
+	}
+	return templates
+}
+
+// TagOptions returns the trimmed options of the envsubst tag of a struct
+// tag, with commas inside ${...}, such as those of ${HOSTS:-a,b}, kept
+// within their option. The default takes the rest of the tag.
+func TagOptions(tag reflect.StructTag) []string {
+	value, ok := tag.Lookup("envsubst")
+	if !ok || value == "" {
+		return nil
+	}
+	var options []string
+	depth, start := 0, 0
+	for i := 0; i <= len(value); i++ {
+		switch {
+		case i == len(value) || value[i] == ',' && depth == 0:
+			option := strings.TrimSpace(value[start:i])
+			if strings.HasPrefix(option, "default=") {
+				return append(options, strings.TrimSpace(value[start:]))
+			}
+			options = append(options, option)
+			start = i + 1
+		case strings.HasPrefix(value[i:], "${"):
+			depth++
+			i++
+		case value[i] == '}' && depth > 0:
+			depth--
+		}
+	}
+	return options
+}
+
+// isName reports whether s is a variable name: letters, digits and
+// underscores, not starting with a digit
+func isName(s string) bool {
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
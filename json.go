@@ -0,0 +1,321 @@
+package goenvsubst
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DoJSON replaces environment variable references in the string values of a
+// JSON document and returns the rewritten document. Object keys, numbers,
+// booleans and null are never substituted, and substituted strings are
+// escaped again, so values containing quotes or newlines still produce valid
+// JSON. Everything else, including whitespace and key order, is copied byte
+// for byte. opts apply as in Do; errors name the value by its keys and
+// indices, as in [db][hosts][0].
+func DoJSON(data []byte, opts ...Option) ([]byte, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("goenvsubst: json: %w", err)
+	}
+
+	return New(opts...).expandJSON(data)
+}
+
+// expandJSON rewrites the valid JSON document data as DoJSON does
+func (e *Expander) expandJSON(data []byte) ([]byte, error) {
+	r := &jsonRewriter{w: e.walker(), in: data, out: make([]byte, 0, len(data))}
+	for _, path := range e.config.jsonPaths {
+		selector, err := parseJSONPointer(path)
+		if err != nil {
+			return nil, err
+		}
+		r.selectors = append(r.selectors, selector)
+	}
+	if err := r.value(""); err != nil {
+		return nil, err
+	}
+	r.space()
+	if err := r.w.finish(); err != nil {
+		return nil, err
+	}
+	return r.out, nil
+}
+
+// JSONDecoder reads JSON values from a stream like json.Decoder and
+// substitutes the references in their string values before decoding them.
+// Everything the target decodes, including unexported types and types with
+// UnmarshalJSON methods that Do cannot reach, sees substituted values, so it
+// replaces unmarshaling followed by Do.
+type JSONDecoder struct {
+	dec      *json.Decoder
+	expander *Expander
+
+	disallowUnknownFields bool
+	useNumber             bool
+}
+
+// NewJSONDecoder returns a JSONDecoder reading from r. opts apply as in Do.
+func NewJSONDecoder(r io.Reader, opts ...Option) *JSONDecoder {
+	return &JSONDecoder{dec: json.NewDecoder(r), expander: New(opts...)}
+}
+
+// DisallowUnknownFields is like json.Decoder.DisallowUnknownFields.
+func (d *JSONDecoder) DisallowUnknownFields() {
+	d.disallowUnknownFields = true
+}
+
+// UseNumber is like json.Decoder.UseNumber.
+func (d *JSONDecoder) UseNumber() {
+	d.useNumber = true
+}
+
+// More is like json.Decoder.More.
+func (d *JSONDecoder) More() bool {
+	return d.dec.More()
+}
+
+// Decode reads the next JSON value, substitutes it as DoJSON does and
+// stores the result in the value pointed to by v, like json.Decoder.Decode.
+// It returns io.EOF at the end of the stream.
+func (d *JSONDecoder) Decode(v any) error {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return err
+	}
+	expanded, err := d.expander.expandJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(expanded))
+	if d.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if d.useNumber {
+		dec.UseNumber()
+	}
+	return dec.Decode(v)
+}
+
+// jsonRewriter copies a valid JSON document while expanding string values
+type jsonRewriter struct {
+	w   *walker
+	in  []byte
+	pos int
+	out []byte
+	// selectors hold the segments of the pointers of WithJSONPaths, and
+	// pointer those of the value at the current position
+	selectors [][]string
+	pointer   []string
+}
+
+// value copies the value at the current position, which path names
+func (r *jsonRewriter) value(path string) error {
+	r.space()
+	switch r.in[r.pos] {
+	case '{':
+		return r.container('}', func(i int) error {
+			key, err := r.key()
+			if err != nil {
+				return err
+			}
+			r.space()
+			r.copy(1) // :
+			return r.element(keyPath(path, key), key)
+		})
+	case '[':
+		return r.container(']', func(i int) error {
+			return r.element(indexPath(path, i), strconv.Itoa(i))
+		})
+	case '"':
+		return r.str(path)
+	}
+
+	// A number, true, false or null
+	start := r.pos
+	for r.pos < len(r.in) && strings.IndexByte(",]} \t\r\n", r.in[r.pos]) < 0 {
+		r.pos++
+	}
+	r.out = append(r.out, r.in[start:r.pos]...)
+	return nil
+}
+
+// element copies the value of an object member or array element, which
+// segment names in JSON Pointers
+func (r *jsonRewriter) element(path, segment string) error {
+	r.pointer = append(r.pointer, segment)
+	err := r.value(path)
+	r.pointer = r.pointer[:len(r.pointer)-1]
+	return err
+}
+
+// selected reports whether the value at the current position is selected by
+// WithJSONPaths, which selects all of them when it is not given
+func (r *jsonRewriter) selected() bool {
+	if len(r.selectors) == 0 {
+		return true
+	}
+	for _, selector := range r.selectors {
+		if matchJSONPointer(selector, r.pointer) {
+			return true
+		}
+	}
+	return false
+}
+
+// container copies an object or array up to its closing byte, calling
+// element for each of its elements
+func (r *jsonRewriter) container(closing byte, element func(i int) error) error {
+	r.copy(1)
+	r.space()
+	for i := 0; r.in[r.pos] != closing; i++ {
+		if err := element(i); err != nil {
+			return err
+		}
+		r.space()
+		if r.in[r.pos] == ',' {
+			r.copy(1)
+			r.space()
+		}
+	}
+	r.copy(1)
+	return nil
+}
+
+// key copies an object key and returns it decoded
+func (r *jsonRewriter) key() (string, error) {
+	r.space()
+	raw := r.in[r.pos:r.stringEnd()]
+	var key string
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return "", fmt.Errorf("goenvsubst: json: %w", err)
+	}
+	r.copy(len(raw))
+	return key, nil
+}
+
+// str copies or expands a string value
+func (r *jsonRewriter) str(path string) error {
+	raw := r.in[r.pos:r.stringEnd()]
+	var original string
+	if err := json.Unmarshal(raw, &original); err != nil {
+		return fmt.Errorf("goenvsubst: json: %w", err)
+	}
+
+	if !r.selected() {
+		r.copy(len(raw))
+		return nil
+	}
+	expanded, err := r.w.expand(original, path)
+	if err != nil {
+		return err
+	}
+	if expanded == original {
+		r.copy(len(raw))
+		return nil
+	}
+	r.pos += len(raw)
+	r.out, err = appendJSONString(r.out, expanded)
+	return err
+}
+
+// stringEnd returns the offset just past the string starting at the current
+// position
+func (r *jsonRewriter) stringEnd() int {
+	for i := r.pos + 1; i < len(r.in); i++ {
+		switch r.in[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(r.in)
+}
+
+// space copies any whitespace at the current position
+func (r *jsonRewriter) space() {
+	start := r.pos
+	for r.pos < len(r.in) && strings.IndexByte(" \t\r\n", r.in[r.pos]) >= 0 {
+		r.pos++
+	}
+	r.out = append(r.out, r.in[start:r.pos]...)
+}
+
+// copy copies the next n bytes
+func (r *jsonRewriter) copy(n int) {
+	r.out = append(r.out, r.in[r.pos:r.pos+n]...)
+	r.pos += n
+}
+
+// parseJSONPointer splits a JSON Pointer into its unescaped segments
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	rest, ok := strings.CutPrefix(pointer, "/")
+	if !ok {
+		return nil, fmt.Errorf("goenvsubst: json: invalid path %q: must start with /", pointer)
+	}
+	segments := strings.Split(rest, "/")
+	for i, segment := range segments {
+		segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+	}
+	return segments, nil
+}
+
+// matchJSONPointer reports whether pointer lies within the value selected by
+// selector, whose * segments match any segment
+func matchJSONPointer(selector, pointer []string) bool {
+	if len(pointer) < len(selector) {
+		return false
+	}
+	for i, segment := range selector {
+		if segment != "*" && segment != pointer[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendJSONString encodes s as a JSON string. Unlike json.Marshal it leaves
+// <, > and & as they are, since the document is not HTML.
+func appendJSONString(out []byte, s string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return nil, fmt.Errorf("goenvsubst: json: %w", err)
+	}
+	return append(out, bytes.TrimSuffix(b.Bytes(), []byte("\n"))...), nil
+}
+
+// doJSONField fills a field with the JSON value held by the variable named by
+// its env tag, or produced by the template given in its tag, such as a
+// whole struct or map delivered as one variable. The value is data: it is
+// unmarshaled as is, without substituting references inside it. An empty
+// result leaves the field unchanged.
+func (w *walker) doJSONField(v reflect.Value, tag fieldTag, path string) error {
+	if tag.source == "" && tag.env == "" {
+		return fmt.Errorf("goenvsubst: field %s: json option requires an env tag or a template", path)
+	}
+	expanded, err := w.expandSource(tag, path)
+	if err != nil || expanded == "" {
+		return err
+	}
+
+	target := v.Addr()
+	if w.checking() {
+		// Unmarshal into a scratch value to find values it rejects
+		target = reflect.New(v.Type())
+	}
+	if err := json.Unmarshal([]byte(expanded), target.Interface()); err != nil {
+		return fmt.Errorf("goenvsubst: field %s: decode json: %w", path, err)
+	}
+	return nil
+}
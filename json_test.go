@@ -0,0 +1,254 @@
+package goenvsubst_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoJSON(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("TRICKY_VAR", "say \"hi\"\n<b>&</b> \\ é")
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("TRICKY_VAR")
+	}()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "top-level string",
+			input:    `"$TEST_VAR"`,
+			expected: `"test_value"`,
+		},
+		{
+			name:     "missing variable",
+			input:    `"$MISSING_VAR"`,
+			expected: `""`,
+		},
+		{
+			name:     "object values are expanded, keys are not",
+			input:    `{"$TEST_VAR": "static", "key": "$TEST_VAR"}`,
+			expected: `{"$TEST_VAR": "static", "key": "test_value"}`,
+		},
+		{
+			name:     "values are escaped",
+			input:    `{"message": "${TRICKY_VAR}"}`,
+			expected: `{"message": "say \"hi\"\n<b>&</b> \\ é"}`,
+		},
+		{
+			name:     "escaped templates are decoded first",
+			input:    `["$TEST_VAR", "\"$TEST_VAR\""]`,
+			expected: `["test_value", "\"test_value\""]`,
+		},
+		{
+			name:     "other values are copied",
+			input:    `[42, -1.5e3, true, false, null, "$TEST_VAR"]`,
+			expected: `[42, -1.5e3, true, false, null, "test_value"]`,
+		},
+		{
+			name:     "formatting is kept",
+			input:    "{\n  \"db\": {\n    \"hosts\": [ \"$TEST_VAR\" ,\"b\" ],\n    \"empty\": {}, \"none\": []\n  }\n}\n",
+			expected: "{\n  \"db\": {\n    \"hosts\": [ \"test_value\" ,\"b\" ],\n    \"empty\": {}, \"none\": []\n  }\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := goenvsubst.DoJSON([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("DoJSON() error = %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("DoJSON() = %s, want %s", out, tt.expected)
+			}
+			if !json.Valid(out) {
+				t.Errorf("DoJSON() = %s, not valid JSON", out)
+			}
+		})
+	}
+}
+
+func TestDoJSONErrors(t *testing.T) {
+	if _, err := goenvsubst.DoJSON([]byte(`{"a": "$TEST_VAR",}`)); err == nil {
+		t.Error("DoJSON() expected error for invalid JSON")
+	}
+
+	_, err := goenvsubst.DoJSON([]byte(`{"db": {"hosts": ["ok", "${MISSING_VAR:?required}"]}}`))
+	var pathErr *goenvsubst.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("DoJSON() error = %v, want *PathError", err)
+	}
+	if want := "[db][hosts][1]"; pathErr.Path != want {
+		t.Errorf("Path = %q, want %q", pathErr.Path, want)
+	}
+}
+
+func TestDoJSONPaths(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	input := `{"spec": {"containers": [
+		{"name": "$TEST_VAR", "env": [{"name": "A", "value": "$TEST_VAR"}, {"name": "B", "value": "${TEST_VAR}"}]},
+		{"env": [{"value": "$TEST_VAR"}], "args": ["$TEST_VAR"]}
+	]}, "data": {"user~/note": "$TEST_VAR", "other": "$TEST_VAR"}}`
+	want := `{"spec": {"containers": [
+		{"name": "$TEST_VAR", "env": [{"name": "A", "value": "test_value"}, {"name": "B", "value": "test_value"}]},
+		{"env": [{"value": "test_value"}], "args": ["$TEST_VAR"]}
+	]}, "data": {"user~/note": "$TEST_VAR", "other": "test_value"}}`
+
+	out, err := goenvsubst.DoJSON([]byte(input), goenvsubst.WithJSONPaths("/spec/containers/*/env/*/value", "/data/other"))
+	if err != nil {
+		t.Fatalf("DoJSON() error = %v", err)
+	}
+	if string(out) != want {
+		t.Errorf("DoJSON() = %s, want %s", out, want)
+	}
+
+	// A pointer selects everything inside the value, escapes are decoded
+	out, err = goenvsubst.DoJSON([]byte(`{"a~/b": ["$TEST_VAR", {"c": "$TEST_VAR"}], "d": "$TEST_VAR"}`), goenvsubst.WithJSONPaths("/a~0~1b"))
+	if err != nil {
+		t.Fatalf("DoJSON() error = %v", err)
+	}
+	if want := `{"a~/b": ["test_value", {"c": "test_value"}], "d": "$TEST_VAR"}`; string(out) != want {
+		t.Errorf("DoJSON() = %s, want %s", out, want)
+	}
+
+	if _, err := goenvsubst.DoJSON([]byte(`{}`), goenvsubst.WithJSONPaths("spec")); err == nil {
+		t.Error("DoJSON() expected error for a pointer without a leading /")
+	}
+}
+
+// secret keeps its value unexported, out of reach of Do
+type secret struct{ value string }
+
+func (s *secret) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &s.value)
+}
+
+func TestJSONDecoder(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	type config struct {
+		Host   string `json:"host"`
+		Token  secret `json:"token"`
+		Port   any    `json:"port"`
+		Static string `json:"$TEST_VAR"`
+	}
+
+	dec := goenvsubst.NewJSONDecoder(strings.NewReader(`
+		{"host": "$TEST_VAR", "token": "${TEST_VAR}-token", "port": 8080, "$TEST_VAR": "key"}
+		{"host": "second"}
+	`))
+	dec.UseNumber()
+
+	var first, second config
+	if err := dec.Decode(&first); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if first.Host != "test_value" || first.Token.value != "test_value-token" || first.Static != "key" {
+		t.Errorf("Decode() = %+v", first)
+	}
+	if _, ok := first.Port.(json.Number); !ok {
+		t.Errorf("Port = %T, want json.Number", first.Port)
+	}
+	if !dec.More() {
+		t.Fatal("More() = false, want true")
+	}
+	if err := dec.Decode(&second); err != nil || second.Host != "second" {
+		t.Errorf("Decode() = %+v, %v", second, err)
+	}
+	if err := dec.Decode(&second); err != io.EOF {
+		t.Errorf("Decode() error = %v, want io.EOF", err)
+	}
+}
+
+func TestJSONDecoderErrors(t *testing.T) {
+	dec := goenvsubst.NewJSONDecoder(strings.NewReader(`{"host": "x", "unknown": 1}`))
+	dec.DisallowUnknownFields()
+	var v struct {
+		Host string `json:"host"`
+	}
+	if err := dec.Decode(&v); err == nil {
+		t.Error("Decode() expected error for unknown field")
+	}
+
+	dec = goenvsubst.NewJSONDecoder(strings.NewReader(`{"host": "$MISSING_VAR"}`), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if err := dec.Decode(&v); !errors.As(err, &unsetErr) {
+		t.Errorf("Decode() error = %v, want *UnsetError", err)
+	}
+}
+
+func TestDoJSONTag(t *testing.T) {
+	os.Setenv("FEATURE_FLAGS_JSON", `{"beta": true, "rollout": 25, "owner": "$NOT_EXPANDED"}`)
+	os.Setenv("LIMITS_JSON", `{"cpu": "500m"}`)
+	defer func() {
+		os.Unsetenv("FEATURE_FLAGS_JSON")
+		os.Unsetenv("LIMITS_JSON")
+	}()
+
+	type flags struct {
+		Beta    bool   `json:"beta"`
+		Rollout int    `json:"rollout"`
+		Owner   string `json:"owner"`
+		Region  string `json:"region"`
+	}
+	config := &struct {
+		Flags   flags             `env:"FEATURE_FLAGS_JSON" envsubst:"json"`
+		Pointer *flags            `envsubst:"${FEATURE_FLAGS_JSON},json"`
+		Limits  map[string]string `envsubst:"${LIMITS_JSON},json"`
+		Missing map[string]string `env:"MISSING_VAR" envsubst:"json"`
+	}{Flags: flags{Region: "eu"}}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	want := flags{Beta: true, Rollout: 25, Owner: "$NOT_EXPANDED", Region: "eu"}
+	if config.Flags != want {
+		t.Errorf("Flags = %+v, want %+v", config.Flags, want)
+	}
+	if want.Region = ""; config.Pointer == nil || *config.Pointer != want {
+		t.Errorf("Pointer = %+v, want %+v", config.Pointer, want)
+	}
+	if config.Limits["cpu"] != "500m" {
+		t.Errorf("Limits = %v", config.Limits)
+	}
+	if config.Missing != nil {
+		t.Errorf("Missing = %v, want nil", config.Missing)
+	}
+}
+
+func TestDoJSONTagErrors(t *testing.T) {
+	os.Setenv("FEATURE_FLAGS_JSON", `{"beta": "yes"}`)
+	defer os.Unsetenv("FEATURE_FLAGS_JSON")
+
+	invalid := &struct {
+		Flags struct {
+			Beta bool `json:"beta"`
+		} `env:"FEATURE_FLAGS_JSON" envsubst:"json"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "field Flags") {
+		t.Errorf("Do() error = %v, want a decode error naming the field", err)
+	}
+	if issues, err := goenvsubst.Check(invalid); err != nil || len(issues) != 1 {
+		t.Errorf("Check() = %v, %v, want one issue", issues, err)
+	}
+
+	noSource := &struct {
+		Flags map[string]bool `envsubst:"json"`
+	}{}
+	if err := goenvsubst.Do(noSource); err == nil {
+		t.Error("Do() expected error for the json option without a source")
+	}
+}
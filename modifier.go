@@ -0,0 +1,73 @@
+package goenvsubst
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// modifiers transform the values of placeholders such as ${NAME|urlencode},
+// keyed by name
+var (
+	modifiersMu sync.RWMutex
+	modifiers   = map[string]func(value string) (string, error){
+		"urlencode": func(value string) (string, error) {
+			return escapeURLComponent(value), nil
+		},
+		"urldecode": url.QueryUnescape,
+		"b64enc": func(value string) (string, error) {
+			return base64.StdEncoding.EncodeToString([]byte(value)), nil
+		},
+		"b64dec": func(value string) (string, error) {
+			decoded, err := decodeBase64(value)
+			return string(decoded), err
+		},
+		"quote": func(value string) (string, error) {
+			return strconv.Quote(value), nil
+		},
+		"trim": func(value string) (string, error) {
+			return strings.TrimSpace(value), nil
+		},
+		"upper": func(value string) (string, error) {
+			return strings.ToUpper(value), nil
+		},
+		"lower": func(value string) (string, error) {
+			return strings.ToLower(value), nil
+		},
+	}
+)
+
+// RegisterModifier makes name available as a modifier of braced
+// placeholders, as in ${NAME|name}, in addition to the built-in urlencode,
+// urldecode, b64enc, b64dec, quote, trim, upper and lower. fn receives the
+// value of the placeholder, after its operator applies, and returns the
+// value to substitute; an error fails the substitution like any other.
+// Modifiers are checked when a template is parsed, so one must be
+// registered before templates that use it are processed.
+//
+// name must be a valid variable name. Registering a name again replaces
+// its modifier, including a built-in one, and a nil fn removes it.
+// RegisterModifier is meant to be called from init functions; it is safe
+// for concurrent use.
+func RegisterModifier(name string, fn func(value string) (string, error)) {
+	if !isName(name) {
+		panic(fmt.Sprintf("goenvsubst: invalid modifier name %q", name))
+	}
+	modifiersMu.Lock()
+	defer modifiersMu.Unlock()
+	if fn == nil {
+		delete(modifiers, name)
+		return
+	}
+	modifiers[name] = fn
+}
+
+// modifierFor returns the modifier registered as name, or nil
+func modifierFor(name string) func(value string) (string, error) {
+	modifiersMu.RLock()
+	defer modifiersMu.RUnlock()
+	return modifiers[name]
+}
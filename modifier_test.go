@@ -0,0 +1,75 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestExpandModifiers(t *testing.T) {
+	vars := goenvsubst.WithResolver(goenvsubst.MapResolver{
+		"PASSWORD": "p@ss word/1",
+		"CERT":     "aGVsbG8=",
+		"NAME":     ` Say "hi" `,
+		"ESCAPED":  "a%2Fb",
+	})
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"postgres://admin:${PASSWORD|urlencode}@db", "postgres://admin:p%40ss%20word%2F1@db"},
+		{"${ESCAPED|urldecode}", "a/b"},
+		{"${CERT|b64dec}", "hello"},
+		{"${CERT|b64dec|b64enc}", "aGVsbG8="},
+		{"${NAME|quote}", `" Say \"hi\" "`},
+		{"${NAME|trim|upper}", `SAY "HI"`},
+		{"${MISSING_VAR|quote:-a b}", `"a b"`},
+		{"${MISSING_VAR|lower}", ""},
+	}
+	for _, tt := range tests {
+		got, err := goenvsubst.Expand(tt.input, vars)
+		if err != nil || got != tt.expected {
+			t.Errorf("Expand(%q) = %q, %v, want %q", tt.input, got, err, tt.expected)
+		}
+	}
+
+	_, err := goenvsubst.Expand("${NAME|b64dec}", vars)
+	if err == nil || !strings.Contains(err.Error(), "modifier b64dec of NAME") {
+		t.Errorf("Expand() error = %v, want a b64dec failure", err)
+	}
+	if got, _ := goenvsubst.Expand("${MISSING_VAR|quote}", vars, goenvsubst.WithKeepUnset()); got != "${MISSING_VAR|quote}" {
+		t.Errorf("Expand() with WithKeepUnset = %q", got)
+	}
+}
+
+func TestRegisterModifier(t *testing.T) {
+	goenvsubst.RegisterModifier("reverse", func(value string) (string, error) {
+		if value == "" {
+			return "", errors.New("empty value")
+		}
+		runes := []rune(value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+	t.Cleanup(func() { goenvsubst.RegisterModifier("reverse", nil) })
+
+	vars := goenvsubst.WithResolver(goenvsubst.MapResolver{"HOST": "db.internal", "EMPTY": ""})
+	config := struct{ Host, Empty string }{"${HOST|reverse|upper}", "${EMPTY|reverse}"}
+	err := goenvsubst.Do(&config, vars)
+	if want := "goenvsubst: Empty: modifier reverse of EMPTY: empty value"; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+	if config.Host != "LANRETNI.BD" {
+		t.Errorf("Do() Host = %q", config.Host)
+	}
+
+	goenvsubst.RegisterModifier("reverse", nil)
+	var syntaxErr *goenvsubst.SyntaxError
+	if _, err := goenvsubst.Expand("${HOST|reverse}", vars); !errors.As(err, &syntaxErr) {
+		t.Errorf("Expand() with a removed modifier error = %v, want *SyntaxError", err)
+	}
+}
@@ -0,0 +1,262 @@
+package goenvsubst
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DoMsgpack replaces environment variable references in the string values of
+// a MessagePack payload and returns the re-encoded payload. Map keys, binary
+// data, extensions and all non-string values are copied byte for byte, so the
+// output differs from the input only where a string was substituted. The
+// payload may hold several concatenated values. opts apply as in Do; errors
+// name the value by its keys and indices, as in [db][hosts][0].
+func DoMsgpack(data []byte, opts ...Option) ([]byte, error) {
+	r := &msgpackRewriter{w: New(opts...).walker(), in: data, out: make([]byte, 0, len(data))}
+	for r.pos < len(r.in) {
+		if err := r.value("", true); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.w.finish(); err != nil {
+		return nil, err
+	}
+	return r.out, nil
+}
+
+// maxMsgpackDepth bounds how deeply arrays and maps may nest whatever
+// WithMaxDepth allows, as encoding/json does, so a small payload of nested
+// headers cannot exhaust the stack
+const maxMsgpackDepth = 10000
+
+// msgpackRewriter copies a MessagePack stream while expanding string values
+type msgpackRewriter struct {
+	w   *walker
+	in  []byte
+	pos int
+	out []byte
+}
+
+// value copies a single value, which path names; strings are expanded when
+// expand is true
+func (r *msgpackRewriter) value(path string, expand bool) error {
+	start := r.pos
+	b, err := r.byte()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case b <= 0x7f, b >= 0xe0, b == 0xc0, b == 0xc2, b == 0xc3:
+		// fixint, nil and booleans have no payload
+		return r.copy(start, 0)
+	case b >= 0x80 && b <= 0x8f:
+		return r.container(start, int(b&0x0f), true, path, expand)
+	case b >= 0x90 && b <= 0x9f:
+		return r.container(start, int(b&0x0f), false, path, expand)
+	case b >= 0xa0 && b <= 0xbf:
+		return r.str(start, int(b&0x1f), path, expand)
+	}
+
+	switch b {
+	case 0xcc, 0xd0:
+		return r.copy(start, 1)
+	case 0xcd, 0xd1:
+		return r.copy(start, 2)
+	case 0xca, 0xce, 0xd2:
+		return r.copy(start, 4)
+	case 0xcb, 0xcf, 0xd3:
+		return r.copy(start, 8)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext: one type byte and 1, 2, 4, 8 or 16 data bytes
+		return r.copy(start, 1+1<<(b-0xd4))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.length(1 << (b - 0xc4))
+		if err != nil {
+			return err
+		}
+		return r.copy(start, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.length(1 << (b - 0xc7))
+		if err != nil {
+			return err
+		}
+		return r.copy(start, 1+n)
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.length(1 << (b - 0xd9))
+		if err != nil {
+			return err
+		}
+		return r.str(start, n, path, expand)
+	case 0xdc, 0xdd:
+		n, err := r.length(2 << (b - 0xdc))
+		if err != nil {
+			return err
+		}
+		return r.container(start, n, false, path, expand)
+	case 0xde, 0xdf:
+		n, err := r.length(2 << (b - 0xde))
+		if err != nil {
+			return err
+		}
+		return r.container(start, n, true, path, expand)
+	}
+
+	return fmt.Errorf("goenvsubst: msgpack: invalid type byte 0x%02x at offset %d", b, start)
+}
+
+// container copies an array or map header and its n elements. Map keys,
+// including the strings nested in them, are never expanded.
+func (r *msgpackRewriter) container(start, n int, isMap bool, path string, expand bool) error {
+	if r.w.depth >= maxMsgpackDepth {
+		return fmt.Errorf("goenvsubst: msgpack: %s nests deeper than %d levels", displayPath(path), maxMsgpackDepth)
+	}
+	if err := r.w.enter(path); err != nil {
+		return err
+	}
+	defer r.w.leave()
+
+	r.out = append(r.out, r.in[start:r.pos]...)
+	for i := 0; i < n; i++ {
+		epath := indexPath(path, i)
+		if isMap {
+			keyStart := r.pos
+			if err := r.value(epath, false); err != nil {
+				return err
+			}
+			epath = keyPath(path, msgpackKey(r.in[keyStart:r.pos]))
+		}
+		if err := r.value(epath, expand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// str copies or expands a string whose n bytes follow the header
+func (r *msgpackRewriter) str(start, n int, path string, expand bool) error {
+	if !expand {
+		return r.copy(start, n)
+	}
+	if err := r.need(n); err != nil {
+		return err
+	}
+	original := string(r.in[r.pos : r.pos+n])
+	r.pos += n
+
+	expanded, err := r.w.expand(original, path)
+	if err != nil {
+		return err
+	}
+	if expanded == original {
+		r.out = append(r.out, r.in[start:r.pos]...)
+		return nil
+	}
+	r.out = appendMsgpackStr(r.out, expanded)
+	return nil
+}
+
+// copy advances past n payload bytes and copies the value starting at start
+func (r *msgpackRewriter) copy(start, n int) error {
+	if err := r.need(n); err != nil {
+		return err
+	}
+	r.pos += n
+	r.out = append(r.out, r.in[start:r.pos]...)
+	return nil
+}
+
+// byte reads a single byte
+func (r *msgpackRewriter) byte() (byte, error) {
+	if err := r.need(1); err != nil {
+		return 0, err
+	}
+	b := r.in[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// length reads a big-endian length of size bytes
+func (r *msgpackRewriter) length(size int) (int, error) {
+	if err := r.need(size); err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch size {
+	case 1:
+		n = uint64(r.in[r.pos])
+	case 2:
+		n = uint64(binary.BigEndian.Uint16(r.in[r.pos:]))
+	case 4:
+		n = uint64(binary.BigEndian.Uint32(r.in[r.pos:]))
+	}
+	r.pos += size
+	if n > uint64(len(r.in)) {
+		// No valid payload can be longer than the input itself
+		return 0, r.truncated()
+	}
+	return int(n), nil
+}
+
+// need ensures n more bytes are available
+func (r *msgpackRewriter) need(n int) error {
+	if n > len(r.in)-r.pos {
+		return r.truncated()
+	}
+	return nil
+}
+
+func (r *msgpackRewriter) truncated() error {
+	return fmt.Errorf("goenvsubst: msgpack: unexpected end of data at offset %d", r.pos)
+}
+
+// msgpackKey returns how the encoded map key raw is named in paths: strings
+// and integers by their value, other keys by their bytes in hexadecimal
+func msgpackKey(raw []byte) any {
+	b := raw[0]
+	switch {
+	case b <= 0x7f:
+		return int(b)
+	case b >= 0xe0:
+		return int(int8(b))
+	case b >= 0xa0 && b <= 0xbf:
+		return string(raw[1:])
+	}
+	switch b {
+	case 0xd9, 0xda, 0xdb:
+		return string(raw[1+1<<(b-0xd9):])
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		var n uint64
+		for _, c := range raw[1:] {
+			n = n<<8 | uint64(c)
+		}
+		return n
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		var n uint64
+		for _, c := range raw[1:] {
+			n = n<<8 | uint64(c)
+		}
+		// Sign-extend from the encoded width
+		shift := 64 - 8*(len(raw)-1)
+		return int64(n<<shift) >> shift
+	}
+	return fmt.Sprintf("%#x", raw)
+}
+
+// appendMsgpackStr encodes s using the smallest string format
+func appendMsgpackStr(out []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		out = append(out, 0xa0|byte(n))
+	case n <= 0xff:
+		out = append(out, 0xd9, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0xda)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, 0xdb)
+		out = binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+	return append(out, s...)
+}
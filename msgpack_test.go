@@ -0,0 +1,135 @@
+package goenvsubst_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// mpStr encodes s as a MessagePack fixstr or str8
+func mpStr(s string) []byte {
+	if len(s) <= 31 {
+		return append([]byte{0xa0 | byte(len(s))}, s...)
+	}
+	return append([]byte{0xd9, byte(len(s))}, s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestDoMsgpack(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("LONG_VAR", strings.Repeat("x", 40))
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("LONG_VAR")
+	}()
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected []byte
+	}{
+		{
+			name:     "top-level string",
+			input:    mpStr("$TEST_VAR"),
+			expected: mpStr("test_value"),
+		},
+		{
+			name:     "missing variable",
+			input:    mpStr("$MISSING_VAR"),
+			expected: mpStr(""),
+		},
+		{
+			name:     "string grows into str8",
+			input:    mpStr("$LONG_VAR"),
+			expected: mpStr(strings.Repeat("x", 40)),
+		},
+		{
+			name:     "map values are expanded, keys are not",
+			input:    concat([]byte{0x82}, mpStr("$TEST_VAR"), mpStr("static"), mpStr("key"), mpStr("$TEST_VAR")),
+			expected: concat([]byte{0x82}, mpStr("$TEST_VAR"), mpStr("static"), mpStr("key"), mpStr("test_value")),
+		},
+		{
+			name:     "strings nested in keys are not expanded",
+			input:    concat([]byte{0x81, 0x91}, mpStr("$TEST_VAR"), mpStr("$TEST_VAR")),
+			expected: concat([]byte{0x81, 0x91}, mpStr("$TEST_VAR"), mpStr("test_value")),
+		},
+		{
+			name:     "array with mixed types",
+			input:    concat([]byte{0x95, 0x2a, 0xc3, 0xc0, 0xcb, 1, 2, 3, 4, 5, 6, 7, 8}, mpStr("$TEST_VAR")),
+			expected: concat([]byte{0x95, 0x2a, 0xc3, 0xc0, 0xcb, 1, 2, 3, 4, 5, 6, 7, 8}, mpStr("test_value")),
+		},
+		{
+			name:     "binary and extension data are copied",
+			input:    []byte{0x92, 0xc4, 0x02, '$', 'A', 0xd6, 0xff, 1, 2, 3, 4},
+			expected: []byte{0x92, 0xc4, 0x02, '$', 'A', 0xd6, 0xff, 1, 2, 3, 4},
+		},
+		{
+			name:     "nested containers",
+			input:    concat([]byte{0xdc, 0x00, 0x01, 0x81}, mpStr("db"), []byte{0x91}, mpStr("$TEST_VAR")),
+			expected: concat([]byte{0xdc, 0x00, 0x01, 0x81}, mpStr("db"), []byte{0x91}, mpStr("test_value")),
+		},
+		{
+			name:     "concatenated values",
+			input:    concat(mpStr("$TEST_VAR"), []byte{0x01}),
+			expected: concat(mpStr("test_value"), []byte{0x01}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := goenvsubst.DoMsgpack(tt.input)
+			if err != nil {
+				t.Fatalf("DoMsgpack() error = %v", err)
+			}
+			if !bytes.Equal(out, tt.expected) {
+				t.Errorf("DoMsgpack() = %x, want %x", out, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDoMsgpackInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"reserved type byte", []byte{0xc1}},
+		{"truncated string", []byte{0xa5, 'a'}},
+		{"truncated array", []byte{0x92, 0x01}},
+		{"truncated length", []byte{0xda, 0x00}},
+		{"deeply nested arrays", append(bytes.Repeat([]byte{0x91}, 1<<20), 0xc0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := goenvsubst.DoMsgpack(tt.input); err == nil {
+				t.Error("DoMsgpack() expected error")
+			}
+		})
+	}
+}
+
+func TestDoMsgpackErrorPath(t *testing.T) {
+	input := concat([]byte{0x81}, mpStr("db"), []byte{0x92, 0xc0}, mpStr("${UNTERMINATED"))
+	_, err := goenvsubst.DoMsgpack(input)
+	var perr *goenvsubst.PathError
+	if !errors.As(err, &perr) || perr.Path != "[db][1]" {
+		t.Errorf("DoMsgpack() error = %v, want a *PathError at [db][1]", err)
+	}
+}
+
+func TestDoMsgpackMaxDepth(t *testing.T) {
+	input := concat([]byte{0x91, 0x91, 0x91}, mpStr("$TEST_VAR"))
+	_, err := goenvsubst.DoMsgpack(input, goenvsubst.WithMaxDepth(2))
+	var derr *goenvsubst.DepthError
+	if !errors.As(err, &derr) {
+		t.Errorf("DoMsgpack() error = %v, want a *DepthError", err)
+	}
+}
@@ -0,0 +1,380 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+)
+
+// Option changes how Do and the other entry points resolve references.
+type Option func(*config)
+
+// config holds the settings assembled from the Options of a call
+type config struct {
+	strict     bool
+	keepUnset  bool
+	inferTypes bool
+	mapKeys    bool
+	// unexportedEmbeds enables WithUnexportedEmbeds
+	unexportedEmbeds bool
+	resolver         Resolver
+	// skipTypes holds the types of WithSkipTypes
+	skipTypes []reflect.Type
+	syntax    Syntax
+	// maxOutputSize, if positive, limits the size of expanded strings
+	maxOutputSize int
+	// maxDepth, if positive, limits how deeply containers may nest
+	maxDepth int
+	// maxRecursion, if positive, enables recursive expansion of resolved
+	// values and limits how deeply their references may nest
+	maxRecursion int
+	// allow and deny hold the glob patterns of WithAllowlist and
+	// WithDenylist
+	allow []string
+	deny  []string
+	// secrets holds the variables of WithSecretVars
+	secrets []string
+	// redactSubstrings enables WithRedactSubstrings
+	redactSubstrings bool
+	// onSubstitute is the callback of WithOnSubstitute
+	onSubstitute func(path, varName, oldValue, newValue string)
+	// validators hold the functions of WithValidator
+	validators []func(path, varName, value string) error
+	// urlEscaping enables WithURLEscaping
+	urlEscaping bool
+	// jsonPaths holds the JSON Pointers of WithJSONPaths
+	jsonPaths []string
+	// sources holds the sources of WithSources, which envsource tags
+	// name
+	sources []Source
+	// wrappers holds the options such as WithPrefix given since the
+	// resolver was last replaced, which also apply to the source of an
+	// envsource tag
+	wrappers []func(Resolver) Resolver
+}
+
+// wrapResolver makes wrap apply to the resolver configured so far
+func (c *config) wrapResolver(wrap func(base Resolver) Resolver) {
+	c.resolver = wrap(c.resolver)
+	c.wrappers = append(c.wrappers, wrap)
+}
+
+// Strict makes substitution fail fast on missing configuration: once the
+// whole value has been processed, an *UnsetError lists every variable that
+// was referenced but is not set. References whose operator handles an unset
+// variable, such as ${NAME:-default} or ${NAME:+alternate}, are not
+// reported, and variables that are set to an empty string count as set.
+func Strict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithKeepUnset leaves references to unset variables in the output exactly
+// as written, so $MISSING_VAR stays $MISSING_VAR instead of becoming an
+// empty string and a later stage can resolve it. Only plain references are
+// kept: operators such as ${NAME:-default} still apply.
+func WithKeepUnset() Option {
+	return func(c *config) {
+		c.keepUnset = true
+	}
+}
+
+// WithTypeInference stores the substituted strings held by interface{}
+// values, such as the values of a map[string]any decoded from JSON, as a
+// bool, int or float64 when they look like one. Only strings changed by
+// substitution are converted, so "$MAX_CONNS" becomes the int 10 while a
+// literal "10" stays a string. Without this option such strings are
+// expanded and stay strings.
+func WithTypeInference() Option {
+	return func(c *config) {
+		c.inferTypes = true
+	}
+}
+
+// WithJSONPaths limits DoJSON and JSONDecoder to the parts of a document
+// selected by the JSON Pointers (RFC 6901) paths, so documents that embed
+// user content can be substituted safely. A pointer selects the value it
+// points to and everything inside it, and a * segment matches any key or
+// index: "/spec/containers/*/env/*/value" selects the env values of every
+// container. Other strings are copied unchanged. A pointer that is not empty
+// and does not start with "/" makes the call fail.
+func WithJSONPaths(paths ...string) Option {
+	return func(c *config) {
+		c.jsonPaths = append(c.jsonPaths, paths...)
+	}
+}
+
+// WithMapKeys also expands references in the keys of maps with string keys,
+// such as a routing table keyed by host names from the environment. Values
+// are moved to their expanded keys; if two keys would expand to the same
+// key, no key is changed and an error is returned.
+func WithMapKeys() Option {
+	return func(c *config) {
+		c.mapKeys = true
+	}
+}
+
+// WithOnSubstitute calls fn for every reference that is substituted, so
+// that applications can log, meter or audit the substitutions, as in
+// "Database.URL <- $DATABASE_URL". path locates the value, varName is the
+// referenced variable, oldValue the reference as written, such as
+// ${PORT:-8080}, and newValue what it was replaced with. The values of the
+// variables of WithSecretVars are replaced in newValue by their names and
+// fingerprints, as in error messages. References left as written with
+// WithKeepUnset are not reported, nor are those in the values of variables
+// expanded with WithRecursion, and Check does not call fn.
+func WithOnSubstitute(fn func(path, varName, oldValue, newValue string)) Option {
+	return func(c *config) {
+		c.onSubstitute = fn
+	}
+}
+
+// WithValidator checks every value that a reference expands to with fn,
+// during the same pass, such as that it is not empty, matches a pattern or
+// parses as a URL. path locates the value holding the reference, varName
+// is the referenced variable and value what the reference expanded to, so
+// a default counts for an unset variable. An error returned by fn makes the
+// call fail with a *ValidationError naming the variable, wrapped in a
+// *PathError; Check reports it as an issue. References left as written
+// with WithKeepUnset, and those in the values of variables expanded with
+// WithRecursion, are not validated. Repeated options add validators, which
+// run in order.
+func WithValidator(fn func(path, varName, value string) error) Option {
+	return func(c *config) {
+		c.validators = append(c.validators, fn)
+	}
+}
+
+// WithUnexportedEmbeds also processes the promoted fields of embedded
+// structs whose type is unexported, such as the exported fields of an
+// embedded baseConfig. Embedded structs of exported types are always
+// processed, like any other field. The embedded field itself cannot be set,
+// so a nil pointer to an unexported type is never allocated and is skipped,
+// and DoCopy shares the struct it points to with the original, which is
+// then substituted as well.
+func WithUnexportedEmbeds() Option {
+	return func(c *config) {
+		c.unexportedEmbeds = true
+	}
+}
+
+// WithSkipTypes leaves values of the given types untouched, wherever they
+// are found, such as types from other packages whose exported string fields
+// are internals rather than configuration. time.Time, big.Int, big.Float,
+// big.Rat, sync.Mutex, sync.RWMutex and reflect.Value are always skipped.
+// Pointers to a skipped type are skipped too. Fields tagged with an env
+// source or template are still filled, since they replace the value rather
+// than descend into it.
+func WithSkipTypes(types ...reflect.Type) Option {
+	return func(c *config) {
+		c.skipTypes = append(c.skipTypes, types...)
+	}
+}
+
+// WithMaxOutputSize limits the size in bytes that a string, or the text given
+// to Expand, may expand to, including the values of variables expanded with
+// WithRecursion and defaults. Exceeding it makes the call fail with an
+// *OutputSizeError as soon as the expanded parts outgrow the limit, so a
+// template whose references multiply each other, in the manner of "billion
+// laughs" attacks, cannot exhaust memory. ExpandReader applies the limit to
+// each chunk it expands rather than to the whole stream.
+func WithMaxOutputSize(bytes int) Option {
+	return func(c *config) {
+		c.maxOutputSize = bytes
+	}
+}
+
+// WithMaxDepth limits how deeply structs, slices, arrays and maps may nest
+// in the processed value: the outermost container is at depth 1, and one
+// nested deeper than maxDepth makes the call fail with a *DepthError. Pointer
+// cycles, such as the back-references of a tree, never need it, since every
+// pointer, map and slice is processed once however often it is reachable;
+// it bounds the work done on untrusted or generated values, such as
+// deeply nested JSON.
+func WithMaxDepth(maxDepth int) Option {
+	return func(c *config) {
+		c.maxDepth = maxDepth
+	}
+}
+
+// WithRecursion also expands references in the values of variables, until
+// no references are left: if FULL_URL is set to https://$HOST/$PATH, then
+// $FULL_URL expands to the URL with HOST and PATH substituted. Values are
+// templates in that mode, so a $$ in a value becomes $. maxDepth limits how
+// deeply variables may refer to each other; exceeding it makes the call
+// fail with an error naming the chain of variables, and a variable that
+// refers back to itself, directly or through others, with a *CycleError.
+func WithRecursion(maxDepth int) Option {
+	return func(c *config) {
+		c.maxRecursion = maxDepth
+	}
+}
+
+// WithLookup resolves variables with lookup instead of os.LookupEnv, for
+// example from test fixtures, a snapshot of the environment or any other
+// key/value store. lookup reports whether the variable is set, with the same
+// meaning as for os.LookupEnv: everything else, including the operators,
+// strict mode and envwhen conditions, works as with the environment.
+func WithLookup(lookup func(name string) (string, bool)) Option {
+	return WithResolver(ResolverFunc(func(name string) (string, bool, error) {
+		value, ok := lookup(name)
+		return value, ok, nil
+	}))
+}
+
+// WithResolver resolves variables with r instead of the process
+// environment. Use a ChainResolver to combine several sources; an error
+// returned by r makes the call fail.
+func WithResolver(r Resolver) Option {
+	return func(c *config) {
+		c.resolver = r
+		c.wrappers = nil
+	}
+}
+
+// WithPrefix looks every variable up with prefix prepended, falling back to
+// the bare name: with WithPrefix("MYAPP_"), $DB_HOST resolves to MYAPP_DB_HOST
+// if it is set and to DB_HOST otherwise. It lets applications that share one
+// environment deploy the same template without their variables colliding.
+// The prefix applies to the resolver configured so far, so give WithPrefix
+// after WithResolver or WithLookup.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &prefixResolver{base: base, prefix: prefix, fallback: true}
+		})
+	}
+}
+
+// WithPrefixOnly is like WithPrefix without the fallback: $DB_HOST is only
+// ever resolved as MYAPP_DB_HOST.
+func WithPrefixOnly(prefix string) Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &prefixResolver{base: base, prefix: prefix}
+		})
+	}
+}
+
+// WithProfile looks every variable up with the suffix _<PROFILE> first,
+// falling back to the bare name: with WithProfile("prod"), $DB_HOST resolves
+// to DB_HOST_PROD if it is set and to DB_HOST otherwise, so one template
+// serves every environment. The profile is upper-cased; an empty profile
+// disables the suffix. Like WithPrefix, it applies to the resolver
+// configured so far.
+func WithProfile(profile string) Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &profileResolver{base: base, profile: profile}
+		})
+	}
+}
+
+// WithProfileFrom is like WithProfile with the profile read from the
+// variable name, such as APP_PROFILE, through the resolver configured so
+// far. Substitution proceeds without a suffix while the variable is unset
+// or empty.
+func WithProfileFrom(name string) Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &profileResolver{base: base, variable: name}
+		})
+	}
+}
+
+// WithCaseInsensitive matches variable names regardless of case, as Windows
+// does: $Path finds PATH. A variable spelled exactly as referenced is
+// preferred; otherwise the names of the process environment or of a
+// MapResolver are searched, and other resolvers are asked for the upper and
+// lower case forms of the name. It applies to the resolver configured so
+// far, so give it after WithResolver or WithLookup.
+func WithCaseInsensitive() Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &foldResolver{base: base}
+		})
+	}
+}
+
+// WithScheme resolves the references of the form ${scheme:path}, such as
+// ${file:/run/secrets/db_password} or ${vault:kv/data/app#db_password}, with
+// r, which receives the path as the name to resolve. Other references go to
+// the resolver configured so far, as do those of schemes without a
+// WithScheme, which are normally unset. The path ends at the closing brace
+// or at one of the operators ":-", ":?" and ":+", so
+// ${file:/run/secrets/db_password:-dev} has a default. Like WithPrefix, it
+// applies to the resolver configured so far, so give it after WithResolver
+// or WithLookup; repeated options add schemes. Allowlists and denylists
+// match scheme references by their whole text.
+func WithScheme(scheme string, r Resolver) Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &schemeResolver{base: base, scheme: scheme, r: r}
+		})
+	}
+}
+
+// WithFileFallback follows the convention of the official Docker images for
+// secrets: when a variable is unset but the variable named after it with
+// the suffix _FILE is set, as DB_PASSWORD_FILE is for $DB_PASSWORD, the
+// value is read from the file at that path, without the trailing newline.
+// A variable that is set, even to an empty value, takes precedence over its
+// file, and a missing or unreadable file makes the call fail. It applies to
+// the resolver configured so far, so give it after WithResolver or
+// WithLookup.
+func WithFileFallback() Option {
+	return func(c *config) {
+		c.wrapResolver(func(base Resolver) Resolver {
+			return &fileFallbackResolver{base: base}
+		})
+	}
+}
+
+// WithAllowlist only lets references expand variables whose names match one
+// of the glob patterns, written as for path.Match: WithAllowlist("APP_*",
+// "PORT") permits $APP_HOST and $PORT. A reference to any other variable
+// makes the call fail with a *DeniedError before the variable is looked up,
+// so templates from untrusted sources cannot read secrets such as
+// $AWS_SECRET_ACCESS_KEY. Repeated options add to the list.
+func WithAllowlist(patterns ...string) Option {
+	return func(c *config) {
+		c.allow = append(c.allow, patterns...)
+	}
+}
+
+// WithDenylist is the reverse of WithAllowlist: references to variables
+// whose names match one of the glob patterns make the call fail with a
+// *DeniedError. A name matching both lists is denied.
+func WithDenylist(patterns ...string) Option {
+	return func(c *config) {
+		c.deny = append(c.deny, patterns...)
+	}
+}
+
+// permits reports whether the allowlist and denylist let references expand
+// the variable name
+func (c *config) permits(name string) (bool, error) {
+	denied, err := matchAny(c.deny, name)
+	if err != nil || denied {
+		return false, err
+	}
+	if len(c.allow) == 0 {
+		return true, nil
+	}
+	return matchAny(c.allow, name)
+}
+
+// matchAny reports whether name matches one of the glob patterns
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("goenvsubst: invalid pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,448 @@
+package goenvsubst
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SegmentKind tells literal text and placeholders apart.
+type SegmentKind int
+
+const (
+	// LiteralSegment is text that is copied to the output unchanged.
+	LiteralSegment SegmentKind = iota
+	// PlaceholderSegment is a reference to a variable.
+	PlaceholderSegment
+)
+
+// Position locates a byte within a parsed string. For multi-line input, such
+// as a whole configuration file, Line and Column point into that text, which
+// is what editors expect for diagnostics and highlighting.
+type Position struct {
+	// Offset is the byte offset, starting at 0.
+	Offset int
+	// Line is the line number, starting at 1.
+	Line int
+	// Column is the byte offset within the line, starting at 1.
+	Column int
+}
+
+// Advance returns the position just past text, which starts at p. Starting
+// from Position{Line: 1, Column: 1}, it converts offsets of a text into
+// positions as a scanner moves through it, counting every byte once.
+func (p Position) Advance(text string) Position {
+	p.Offset += len(text)
+	if n := strings.Count(text, "\n"); n > 0 {
+		p.Line += n
+		p.Column = len(text) - strings.LastIndexByte(text, '\n')
+	} else {
+		p.Column += len(text)
+	}
+	return p
+}
+
+// Segment is one piece of a parsed string: either literal text or a
+// placeholder referencing a variable.
+type Segment struct {
+	Kind SegmentKind
+	// Literal is the text of a literal segment.
+	Literal string
+	// Escaped reports whether a literal segment was written as an escape,
+	// such as $$; its Literal is then the escaped text, such as a single $.
+	Escaped bool
+	// Name is the variable referenced by a placeholder segment. For a
+	// scheme reference such as ${file:/run/secrets/db_password} it is the
+	// scheme and the path, as written.
+	Name string
+	// Braced reports whether the placeholder was written as ${NAME}.
+	Braced bool
+	// Modifiers lists the modifiers of a braced placeholder such as
+	// ${NAME|urlencode}, in the order they apply.
+	Modifiers []string
+	// Op is the operator of a braced placeholder such as ${NAME:-default},
+	// or empty. Placeholders of other syntaxes may use the same operators.
+	// Supported operators are ":-" and "-" (default value), ":?" and "?"
+	// (required variable) and ":+" and "+" (alternate value); the colon
+	// forms treat a variable that is set but empty as unset. The case
+	// operators "^^", ",,", "^" and "," take no Arg.
+	Op string
+	// Arg is the operator's argument: the default value, the error message
+	// or the alternate value. It is itself a template and may contain
+	// references.
+	Arg string
+	// Start and End delimit the segment within the parsed string; End is
+	// exclusive.
+	Start, End Position
+
+	// form is the syntax the placeholder was written in
+	form segmentForm
+}
+
+// segmentForm tells the built-in syntaxes apart, so that a placeholder can
+// be written back the way it was parsed
+type segmentForm int
+
+const (
+	shellForm segmentForm = iota
+	k8sForm
+	windowsForm
+	actionsForm
+	templateForm
+)
+
+// String renders the segment back into the syntax it was parsed with, so
+// that a parsed (and possibly rewritten) template can be reassembled by
+// concatenating its segments. Segments built by hand are rendered in
+// ShellSyntax.
+func (s Segment) String() string {
+	if s.Kind != PlaceholderSegment {
+		if s.Escaped {
+			// Every built-in syntax escapes its delimiter by doubling it
+			return s.Literal + s.Literal
+		}
+		return s.Literal
+	}
+	switch s.form {
+	case k8sForm:
+		return "$(" + s.Name + ")"
+	case windowsForm:
+		return "%" + s.Name + "%"
+	case actionsForm:
+		if s.Op == ":-" {
+			return "${{ env." + s.Name + " || '" + strings.ReplaceAll(s.Arg, "'", "''") + "' }}"
+		}
+		return "${{ env." + s.Name + " }}"
+	case templateForm:
+		return templateText("." + s.Name)
+	}
+	if s.Braced {
+		var modifiers string
+		for _, modifier := range s.Modifiers {
+			modifiers += "|" + modifier
+		}
+		return "${" + s.Name + modifiers + s.Op + s.Arg + "}"
+	}
+	return "$" + s.Name
+}
+
+// SyntaxError reports a malformed placeholder.
+type SyntaxError struct {
+	// Pos is where the malformed placeholder starts.
+	Pos Position
+	// Msg describes the problem.
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("goenvsubst: syntax error at %d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+// Parse splits s into literal and placeholder segments using exactly the
+// grammar Do applies, so external tools can analyze or rewrite templates
+// without reimplementing it:
+//
+//   - $NAME and ${NAME} reference the variable NAME anywhere in the string;
+//     NAME consists of letters, digits and underscores and does not start
+//     with a digit, and the bare form takes the longest such name
+//   - ${NAME:-default} and ${NAME-default} fall back to default when NAME is
+//     unset or empty, respectively unset
+//   - ${NAME:?message} and ${NAME?message} make expansion fail with message
+//     when NAME is unset or empty, respectively unset
+//   - ${NAME:+alternate} and ${NAME+alternate} expand to alternate when NAME
+//     is set and not empty, respectively set, and to nothing otherwise
+//   - ${NAME^^} and ${NAME,,} expand to the value of NAME in upper and lower
+//     case, and ${NAME^} and ${NAME,} with only its first letter changed
+//   - ${scheme:path}, such as ${file:/run/secrets/db_password}, references
+//     a value of another source, which WithScheme selects by scheme; the
+//     path extends to the closing brace or to one of the operators ":-",
+//     ":?" and ":+", and may not contain references
+//   - ${NAME|urlencode} passes the value through a modifier, which is
+//     registered with RegisterModifier; modifiers follow the name, may be
+//     chained as in ${NAME|trim|urlencode} and may be followed by an
+//     operator, as in ${NAME|urlencode:-default}, whose result they then
+//     apply to
+//   - $$ is an escaped, literal $, so "$$HOME" is the text $HOME; it gets a
+//     segment of its own with Escaped set
+//   - a $ that does not start a reference is literal text
+//
+// An empty string has no segments, and an unterminated or malformed ${...}
+// is a *SyntaxError.
+func Parse(s string) ([]Segment, error) {
+	return shellParser{}.parse(s)
+}
+
+// shellParser splits templates in the syntax described at Parse
+type shellParser struct {
+	// lenient makes a $ that does not start a well-formed reference literal
+	// text instead of a *SyntaxError
+	lenient bool
+	// names, if set, are the only variables references are recognized to;
+	// the text after the $ of another reference is scanned again, and $$ is
+	// not an escape. It implies lenient.
+	names []string
+}
+
+// parse splits s into literal and placeholder segments
+func (p shellParser) parse(s string) ([]Segment, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var segments []Segment
+	positions := newPositioner(s)
+	literalStart := 0
+	flushLiteral := func(end int) {
+		if end > literalStart {
+			segments = append(segments, Segment{
+				Kind:    LiteralSegment,
+				Literal: s[literalStart:end],
+				Start:   positions.at(literalStart),
+				End:     positions.at(end),
+			})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '$' {
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(s[i:], "$$") && p.names == nil {
+			flushLiteral(i)
+			segments = append(segments, Segment{
+				Kind:    LiteralSegment,
+				Literal: "$",
+				Escaped: true,
+				Start:   positions.at(i),
+				End:     positions.at(i + 2),
+			})
+			i += 2
+			literalStart = i
+			continue
+		}
+
+		segment, end, err := parsePlaceholder(s, i)
+		if err != nil && !p.lenient && p.names == nil {
+			return nil, positions.locate(err)
+		}
+		if err != nil || end == i || (p.names != nil && !slices.Contains(p.names, segment.Name)) {
+			// A lone $, or one that does not start a reference recognized
+			// in lenient or restricted mode, is literal text
+			i++
+			continue
+		}
+
+		flushLiteral(i)
+		segment.Start, segment.End = positions.at(i), positions.at(end)
+		segments = append(segments, segment)
+		i, literalStart = end, end
+	}
+	flushLiteral(len(s))
+	return segments, nil
+}
+
+// parsePlaceholder parses the reference starting with the $ at s[i] and
+// returns it with the offset just past it. An end equal to i means the $
+// does not start a reference. Errors are *SyntaxErrors that only record
+// the offset of their position.
+func parsePlaceholder(s string, i int) (Segment, int, error) {
+	rest := s[i+1:]
+
+	if strings.HasPrefix(rest, "{") {
+		return parseBraced(s, i)
+	}
+
+	n := 0
+	for n < len(rest) && isNameChar(rest[n], n == 0) {
+		n++
+	}
+	if n == 0 {
+		return Segment{}, i, nil
+	}
+	return Segment{Kind: PlaceholderSegment, Name: rest[:n]}, i + 1 + n, nil
+}
+
+// operators lists the supported operators, longer ones first so that ":-"
+// is not taken for "-"
+var operators = []string{":-", ":?", ":+", "^^", ",,", "-", "?", "+", "^", ","}
+
+// isCaseOperator reports whether op changes the case of the value, and
+// takes no argument
+func isCaseOperator(op string) bool {
+	switch op {
+	case "^^", ",,", "^", ",":
+		return true
+	}
+	return false
+}
+
+// parseBraced parses the ${...} reference starting at s[i]
+func parseBraced(s string, i int) (Segment, int, error) {
+	closing := matchingBrace(s, i+2)
+	if closing < 0 {
+		return Segment{}, 0, &SyntaxError{Pos: Position{Offset: i}, Msg: "unterminated ${"}
+	}
+	content := s[i+2 : closing]
+
+	n := 0
+	for n < len(content) && isNameChar(content[n], n == 0) {
+		n++
+	}
+	segment := Segment{Kind: PlaceholderSegment, Name: content[:n], Braced: true}
+	if n == 0 {
+		return Segment{}, 0, &SyntaxError{Pos: Position{Offset: i}, Msg: fmt.Sprintf("invalid variable name %q", content)}
+	}
+
+	rest := content[n:]
+	for strings.HasPrefix(rest, "|") {
+		m := 1
+		for m < len(rest) && isNameChar(rest[m], m == 1) {
+			m++
+		}
+		modifier := rest[1:m]
+		if modifierFor(modifier) == nil {
+			return Segment{}, 0, &SyntaxError{Pos: Position{Offset: i}, Msg: fmt.Sprintf("unknown modifier %q", modifier)}
+		}
+		segment.Modifiers = append(segment.Modifiers, modifier)
+		rest = rest[m:]
+	}
+
+	if len(segment.Modifiers) == 0 && isSchemeReference(rest) {
+		// ${scheme:path}: the path extends to a colon operator or the end
+		path := rest[1:]
+		if j := indexColonOperator(path); j >= 0 {
+			path = path[:j]
+		}
+		if path == "" || strings.Contains(path, "$") {
+			return Segment{}, 0, &SyntaxError{Pos: Position{Offset: i}, Msg: fmt.Sprintf("invalid path %q", path)}
+		}
+		segment.Name += ":" + path
+		rest = rest[1+len(path):]
+	}
+
+	if rest != "" {
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				segment.Op, segment.Arg = op, rest[len(op):]
+				break
+			}
+		}
+		if segment.Op == "" {
+			return Segment{}, 0, &SyntaxError{Pos: Position{Offset: i}, Msg: fmt.Sprintf("invalid variable name %q", content)}
+		}
+		if isCaseOperator(segment.Op) && segment.Arg != "" {
+			return Segment{}, 0, &SyntaxError{Pos: Position{Offset: i}, Msg: fmt.Sprintf("%s takes no pattern, got %q", segment.Op, segment.Arg)}
+		}
+
+		// The argument is a template of its own; report its errors at their
+		// position within s
+		if _, err := Parse(segment.Arg); err != nil {
+			var syntaxErr *SyntaxError
+			if errors.As(err, &syntaxErr) {
+				argStart := closing - len(segment.Arg)
+				return Segment{}, 0, &SyntaxError{Pos: Position{Offset: argStart + syntaxErr.Pos.Offset}, Msg: syntaxErr.Msg}
+			}
+			return Segment{}, 0, err
+		}
+	}
+
+	return segment, closing + 1, nil
+}
+
+// isSchemeReference reports whether rest, which follows the name of a
+// braced reference, makes the name the scheme of a ${scheme:path} reference
+func isSchemeReference(rest string) bool {
+	return strings.HasPrefix(rest, ":") && indexColonOperator(rest) != 0
+}
+
+// indexColonOperator returns the offset of the first of the operators
+// ":-", ":?" and ":+" in s, or -1
+func indexColonOperator(s string) int {
+	for j := 0; j < len(s)-1; j++ {
+		if s[j] == ':' && strings.IndexByte("-?+", s[j+1]) >= 0 {
+			return j
+		}
+	}
+	return -1
+}
+
+// matchingBrace returns the offset of the } closing a ${ whose content starts
+// at s[start], skipping over nested ${...} references and $$ escapes, or -1
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for j := start; j < len(s); j++ {
+		switch {
+		case strings.HasPrefix(s[j:], "$$"):
+			j++
+		case strings.HasPrefix(s[j:], "${"):
+			depth++
+			j++
+		case s[j] == '}':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// isName reports whether s is a valid variable name: letters, digits and
+// underscores, not starting with a digit
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNameChar reports whether c may appear in a variable name, at its start
+// if first is set
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		return true
+	case '0' <= c && c <= '9':
+		return !first
+	}
+	return false
+}
+
+// positioner converts byte offsets within s into Positions. Offsets are
+// expected to increase, as a scanner moves through s, so that every byte is
+// counted once; an offset before the previous one starts over.
+type positioner struct {
+	s    string
+	last Position
+}
+
+func newPositioner(s string) *positioner {
+	return &positioner{s: s, last: Position{Line: 1, Column: 1}}
+}
+
+// at returns the position of the byte at offset
+func (p *positioner) at(offset int) Position {
+	if offset < p.last.Offset {
+		p.last = Position{Line: 1, Column: 1}
+	}
+	p.last = p.last.Advance(p.s[p.last.Offset:offset])
+	return p.last
+}
+
+// locate completes the position of err, if it is a *SyntaxError that only
+// records its offset
+func (p *positioner) locate(err error) error {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) && syntaxErr.Pos.Line == 0 {
+		syntaxErr.Pos = p.at(syntaxErr.Pos.Offset)
+	}
+	return err
+}
@@ -0,0 +1,314 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []goenvsubst.Segment
+	}{
+		{
+			name:     "empty string",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:  "literal",
+			input: "no_substitution",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "no_substitution",
+					Start:   goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:     goenvsubst.Position{Offset: 15, Line: 1, Column: 16},
+				},
+			},
+		},
+		{
+			name:  "placeholder",
+			input: "$DATABASE_URL",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:  goenvsubst.PlaceholderSegment,
+					Name:  "DATABASE_URL",
+					Start: goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:   goenvsubst.Position{Offset: 13, Line: 1, Column: 14},
+				},
+			},
+		},
+		{
+			name:  "multi-line literal",
+			input: "line1\nline2",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "line1\nline2",
+					Start:   goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:     goenvsubst.Position{Offset: 11, Line: 2, Column: 6},
+				},
+			},
+		},
+		{
+			name:  "dollar sign only",
+			input: "$",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "$",
+					Start:   goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:     goenvsubst.Position{Offset: 1, Line: 1, Column: 2},
+				},
+			},
+		},
+		{
+			name:  "bare placeholders inside a string",
+			input: "$SCHEME://$HOST:8080/5$",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:  goenvsubst.PlaceholderSegment,
+					Name:  "SCHEME",
+					Start: goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:   goenvsubst.Position{Offset: 7, Line: 1, Column: 8},
+				},
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "://",
+					Start:   goenvsubst.Position{Offset: 7, Line: 1, Column: 8},
+					End:     goenvsubst.Position{Offset: 10, Line: 1, Column: 11},
+				},
+				{
+					Kind:  goenvsubst.PlaceholderSegment,
+					Name:  "HOST",
+					Start: goenvsubst.Position{Offset: 10, Line: 1, Column: 11},
+					End:   goenvsubst.Position{Offset: 15, Line: 1, Column: 16},
+				},
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: ":8080/5$",
+					Start:   goenvsubst.Position{Offset: 15, Line: 1, Column: 16},
+					End:     goenvsubst.Position{Offset: 23, Line: 1, Column: 24},
+				},
+			},
+		},
+		{
+			name:  "braced placeholders inside a string",
+			input: "db://${DB_HOST}:${DB_PORT}",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "db://",
+					Start:   goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:     goenvsubst.Position{Offset: 5, Line: 1, Column: 6},
+				},
+				{
+					Kind:   goenvsubst.PlaceholderSegment,
+					Name:   "DB_HOST",
+					Braced: true,
+					Start:  goenvsubst.Position{Offset: 5, Line: 1, Column: 6},
+					End:    goenvsubst.Position{Offset: 15, Line: 1, Column: 16},
+				},
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: ":",
+					Start:   goenvsubst.Position{Offset: 15, Line: 1, Column: 16},
+					End:     goenvsubst.Position{Offset: 16, Line: 1, Column: 17},
+				},
+				{
+					Kind:   goenvsubst.PlaceholderSegment,
+					Name:   "DB_PORT",
+					Braced: true,
+					Start:  goenvsubst.Position{Offset: 16, Line: 1, Column: 17},
+					End:    goenvsubst.Position{Offset: 26, Line: 1, Column: 27},
+				},
+			},
+		},
+		{
+			name:  "braced placeholder on a later line",
+			input: "a\n${B}",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "a\n",
+					Start:   goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:     goenvsubst.Position{Offset: 2, Line: 2, Column: 1},
+				},
+				{
+					Kind:   goenvsubst.PlaceholderSegment,
+					Name:   "B",
+					Braced: true,
+					Start:  goenvsubst.Position{Offset: 2, Line: 2, Column: 1},
+					End:    goenvsubst.Position{Offset: 6, Line: 2, Column: 5},
+				},
+			},
+		},
+		{
+			name:  "escaped dollar sign",
+			input: "$$HOME=$HOME",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "$",
+					Escaped: true,
+					Start:   goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:     goenvsubst.Position{Offset: 2, Line: 1, Column: 3},
+				},
+				{
+					Kind:    goenvsubst.LiteralSegment,
+					Literal: "HOME=",
+					Start:   goenvsubst.Position{Offset: 2, Line: 1, Column: 3},
+					End:     goenvsubst.Position{Offset: 7, Line: 1, Column: 8},
+				},
+				{
+					Kind:  goenvsubst.PlaceholderSegment,
+					Name:  "HOME",
+					Start: goenvsubst.Position{Offset: 7, Line: 1, Column: 8},
+					End:   goenvsubst.Position{Offset: 12, Line: 1, Column: 13},
+				},
+			},
+		},
+		{
+			name:  "operators",
+			input: "${A:-x}${B?must ${C} be set}",
+			expected: []goenvsubst.Segment{
+				{
+					Kind:   goenvsubst.PlaceholderSegment,
+					Name:   "A",
+					Braced: true,
+					Op:     ":-",
+					Arg:    "x",
+					Start:  goenvsubst.Position{Offset: 0, Line: 1, Column: 1},
+					End:    goenvsubst.Position{Offset: 7, Line: 1, Column: 8},
+				},
+				{
+					Kind:   goenvsubst.PlaceholderSegment,
+					Name:   "B",
+					Braced: true,
+					Op:     "?",
+					Arg:    "must ${C} be set",
+					Start:  goenvsubst.Position{Offset: 7, Line: 1, Column: 8},
+					End:    goenvsubst.Position{Offset: 28, Line: 1, Column: 29},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := goenvsubst.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(segments, tt.expected) {
+				t.Errorf("Parse() = %+v, want %+v", segments, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected goenvsubst.Position
+	}{
+		{"prefix-${UNTERMINATED", goenvsubst.Position{Offset: 7, Line: 1, Column: 8}},
+		{"${}", goenvsubst.Position{Offset: 0, Line: 1, Column: 1}},
+		{"x\n${1ABC}", goenvsubst.Position{Offset: 2, Line: 2, Column: 1}},
+		{"${HAS SPACE}", goenvsubst.Position{Offset: 0, Line: 1, Column: 1}},
+		{"${A:}", goenvsubst.Position{Offset: 0, Line: 1, Column: 1}},
+		{"x${file:/run/$NAME}", goenvsubst.Position{Offset: 1, Line: 1, Column: 2}},
+		{"${A:-${}}", goenvsubst.Position{Offset: 5, Line: 1, Column: 6}},
+		{"x${A^^[a-z]}", goenvsubst.Position{Offset: 1, Line: 1, Column: 2}},
+		{"${A|nope}", goenvsubst.Position{Offset: 0, Line: 1, Column: 1}},
+		{"${A|}", goenvsubst.Position{Offset: 0, Line: 1, Column: 1}},
+		{"${A|urlencode:-${}}", goenvsubst.Position{Offset: 15, Line: 1, Column: 16}},
+		{"$A\n$B\nx${A:-\n${}}", goenvsubst.Position{Offset: 13, Line: 4, Column: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := goenvsubst.Parse(tt.input)
+			var syntaxErr *goenvsubst.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse() error = %v, want *SyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.expected {
+				t.Errorf("Parse() error position = %+v, want %+v", syntaxErr.Pos, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPositionAdvance(t *testing.T) {
+	start := goenvsubst.Position{Line: 1, Column: 1}
+	tests := []struct {
+		text     string
+		expected goenvsubst.Position
+	}{
+		{"", goenvsubst.Position{Offset: 0, Line: 1, Column: 1}},
+		{"abc", goenvsubst.Position{Offset: 3, Line: 1, Column: 4}},
+		{"ab\n", goenvsubst.Position{Offset: 3, Line: 2, Column: 1}},
+		{"a\nb\ncd", goenvsubst.Position{Offset: 6, Line: 3, Column: 3}},
+	}
+
+	for _, tt := range tests {
+		if got := start.Advance(tt.text); got != tt.expected {
+			t.Errorf("Advance(%q) = %+v, want %+v", tt.text, got, tt.expected)
+		}
+	}
+	if got := start.Advance("a\n").Advance("bc"); got != (goenvsubst.Position{Offset: 4, Line: 2, Column: 3}) {
+		t.Errorf("chained Advance() = %+v", got)
+	}
+}
+
+func TestSegmentStringRoundTrip(t *testing.T) {
+	for _, input := range []string{"", "static", "$TEST_VAR", "$", "a${B}c${D}", "$A-$B_1.$2", "${A:-${B:?msg}}", "${A-}", "${A:+--flag}", "$$HOME", "5$$$A", "${A:-$${B}}", "${A^^}${B,}", "${A|trim|urlencode:-x}", "${file:/run/secrets/db:-${B}}", "${vault:kv/data/app#db}"} {
+		segments, err := goenvsubst.Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", input, err)
+		}
+
+		var b strings.Builder
+		for _, segment := range segments {
+			b.WriteString(segment.String())
+		}
+		if b.String() != input {
+			t.Errorf("reassembled %q, want %q", b.String(), input)
+		}
+	}
+}
+
+func TestSegmentStringSyntaxes(t *testing.T) {
+	tests := []struct {
+		syntax   goenvsubst.Syntax
+		input    string
+		expected []string
+	}{
+		{goenvsubst.K8sSyntax, "--port=$(PORT) $$(HOST)", []string{"$(PORT)"}},
+		{goenvsubst.WindowsSyntax, `%ProgramFiles(x86)%\%APP%`, []string{"%ProgramFiles(x86)%", "%APP%"}},
+		{goenvsubst.ActionsSyntax, "${{env.HOST}}:${{ env.PORT || 'it''s' }}", []string{"${{ env.HOST }}", "${{ env.PORT || 'it''s' }}"}},
+		{goenvsubst.TemplateSyntax, "{{.HOST}}", []string{"{{ .HOST }}"}},
+	}
+
+	for _, tt := range tests {
+		placeholders, err := tt.syntax.Find(tt.input)
+		if err != nil {
+			t.Fatalf("Find(%q) error = %v", tt.input, err)
+		}
+		var got []string
+		for _, placeholder := range placeholders {
+			got = append(got, placeholder.String())
+		}
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("placeholders of %q render as %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// rootPath is how the outermost value is named in error messages
+const rootPath = "(root)"
+
+// fieldPath appends a struct field name to path
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// indexPath appends a slice or array index to path
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// keyPath appends a map key to path
+func keyPath(path string, key any) string {
+	return path + "[" + fmt.Sprint(key) + "]"
+}
+
+// displayPath returns path, or rootPath for the outermost value
+func displayPath(path string) string {
+	if path == "" {
+		return rootPath
+	}
+	return path
+}
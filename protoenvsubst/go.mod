@@ -0,0 +1,9 @@
+module github.com/iamolegga/goenvsubst/protoenvsubst
+
+go 1.24.4
+
+require (
+	github.com/iamolegga/goenvsubst v1.0.0
+	google.golang.org/protobuf v1.36.12
+)
+
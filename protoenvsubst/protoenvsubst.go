@@ -0,0 +1,224 @@
+// Package protoenvsubst replaces environment variable references in the
+// string fields of protobuf messages. Unlike goenvsubst.Do on generated
+// structs, it works through protoreflect, so it also covers dynamicpb
+// messages and messages packed into google.protobuf.Any.
+package protoenvsubst
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// anyFullName is the name of the well-known google.protobuf.Any message
+const anyFullName protoreflect.FullName = "google.protobuf.Any"
+
+// TypeResolver finds the concrete message types of Any payloads and any
+// extensions they contain. *protoregistry.Types implements it.
+type TypeResolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// Do recursively replaces environment variable references in all string
+// fields of m, including repeated fields, map values, nested messages and
+// the payloads of google.protobuf.Any fields, which are unpacked, processed
+// and packed again. Map keys are never modified. Any payloads are unpacked
+// with protoregistry.GlobalTypes, which holds every generated message linked
+// into the binary, and payloads whose type cannot be resolved cause an
+// error. opts apply as in goenvsubst.Do; with goenvsubst.Strict, the unset
+// variables of all fields are reported together.
+func Do(m proto.Message, opts ...goenvsubst.Option) error {
+	return DoWithTypes(m, protoregistry.GlobalTypes, opts...)
+}
+
+// DoWithTypes is like Do but unpacks google.protobuf.Any fields with the
+// registry types, such as one holding dynamicpb message types.
+func DoWithTypes(m proto.Message, types TypeResolver, opts ...goenvsubst.Option) error {
+	if m == nil {
+		return nil
+	}
+	c := &config{types: types, expander: goenvsubst.New(opts...)}
+	if err := c.message(m.ProtoReflect()); err != nil {
+		return err
+	}
+	if c.unset != nil {
+		return fmt.Errorf("protoenvsubst: %w", c.unset)
+	}
+	return nil
+}
+
+// config carries the registry and the expander of a single Do call, and
+// the unset variables of Strict, which are collected across all fields so
+// that they are reported together
+type config struct {
+	types    TypeResolver
+	expander *goenvsubst.Expander
+	unset    *goenvsubst.UnsetError
+}
+
+// message processes every populated field of m
+func (c *config) message(m protoreflect.Message) error {
+	if !m.IsValid() {
+		return nil
+	}
+	if m.Descriptor().FullName() == anyFullName {
+		return c.any(m)
+	}
+
+	// Collect the fields first: mutating a message while ranging over it is
+	// not allowed
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+
+	for _, fd := range fields {
+		if err := c.field(m, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field processes a single populated field of m
+func (c *config) field(m protoreflect.Message, fd protoreflect.FieldDescriptor) error {
+	switch {
+	case fd.IsMap():
+		return c.mapField(m.Mutable(fd).Map(), fd.MapValue())
+	case fd.IsList():
+		return c.listField(m.Mutable(fd).List(), fd)
+	case isMessage(fd):
+		return c.message(m.Mutable(fd).Message())
+	case fd.Kind() == protoreflect.StringKind:
+		expanded, changed, err := c.expand(m.Get(fd).String(), string(fd.FullName()))
+		if err != nil {
+			return err
+		}
+		if changed {
+			m.Set(fd, protoreflect.ValueOfString(expanded))
+		}
+	}
+	return nil
+}
+
+// listField processes the elements of a repeated field
+func (c *config) listField(list protoreflect.List, fd protoreflect.FieldDescriptor) error {
+	for i := 0; i < list.Len(); i++ {
+		switch {
+		case isMessage(fd):
+			if err := c.message(list.Get(i).Message()); err != nil {
+				return err
+			}
+		case fd.Kind() == protoreflect.StringKind:
+			expanded, changed, err := c.expand(list.Get(i).String(), fmt.Sprintf("%s[%d]", fd.FullName(), i))
+			if err != nil {
+				return err
+			}
+			if changed {
+				list.Set(i, protoreflect.ValueOfString(expanded))
+			}
+		}
+	}
+	return nil
+}
+
+// mapField processes the values of a map field; keys are never modified
+func (c *config) mapField(mp protoreflect.Map, valueFd protoreflect.FieldDescriptor) error {
+	var keys []protoreflect.MapKey
+	mp.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	for _, key := range keys {
+		switch {
+		case isMessage(valueFd):
+			if err := c.message(mp.Mutable(key).Message()); err != nil {
+				return err
+			}
+		case valueFd.Kind() == protoreflect.StringKind:
+			expanded, changed, err := c.expand(mp.Get(key).String(), fmt.Sprintf("%s[%v]", valueFd.ContainingMessage().FullName(), key.Interface()))
+			if err != nil {
+				return err
+			}
+			if changed {
+				mp.Set(key, protoreflect.ValueOfString(expanded))
+			}
+		}
+	}
+	return nil
+}
+
+// any unpacks a google.protobuf.Any, processes the payload and packs it
+// again. It works on the reflective view so that dynamic Any messages are
+// handled the same way as *anypb.Any.
+func (c *config) any(m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+	typeURLFd, valueFd := fields.ByName("type_url"), fields.ByName("value")
+	if typeURLFd == nil || valueFd == nil {
+		return fmt.Errorf("protoenvsubst: malformed %s descriptor", anyFullName)
+	}
+
+	typeURL := m.Get(typeURLFd).String()
+	if typeURL == "" {
+		return nil
+	}
+
+	mt, err := c.types.FindMessageByURL(typeURL)
+	if err != nil {
+		return fmt.Errorf("protoenvsubst: resolve Any type %q: %w", typeURL, err)
+	}
+
+	payload := mt.New()
+	value := m.Get(valueFd).Bytes()
+	if err := (proto.UnmarshalOptions{Resolver: c.types}).Unmarshal(value, payload.Interface()); err != nil {
+		return fmt.Errorf("protoenvsubst: unpack Any type %q: %w", typeURL, err)
+	}
+	if err := c.message(payload); err != nil {
+		return err
+	}
+
+	packed, err := proto.MarshalOptions{Deterministic: true}.Marshal(payload.Interface())
+	if err != nil {
+		return fmt.Errorf("protoenvsubst: pack Any type %q: %w", typeURL, err)
+	}
+	m.Set(valueFd, protoreflect.ValueOfBytes(packed))
+	return nil
+}
+
+// isMessage reports whether values of fd are messages
+func isMessage(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind
+}
+
+// expand substitutes s, found at path, through goenvsubst and reports
+// whether it changed. The variables of an *UnsetError are recorded and s
+// is left as it is.
+func (c *config) expand(s, path string) (string, bool, error) {
+	expanded, err := c.expander.Expand(s)
+	var unset *goenvsubst.UnsetError
+	if errors.As(err, &unset) {
+		if c.unset == nil {
+			c.unset = &goenvsubst.UnsetError{Paths: map[string][]string{}}
+		}
+		for _, name := range unset.Names {
+			if !slices.Contains(c.unset.Names, name) {
+				c.unset.Names = append(c.unset.Names, name)
+			}
+			c.unset.Paths[name] = append(c.unset.Paths[name], path)
+		}
+		return s, false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("protoenvsubst: %s: %w", path, err)
+	}
+	return expanded, expanded != s, nil
+}
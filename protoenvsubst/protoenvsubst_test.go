@@ -0,0 +1,225 @@
+package protoenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/protoenvsubst"
+)
+
+func setenv(t *testing.T) {
+	t.Helper()
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("ANOTHER_VAR", "another_value")
+	t.Cleanup(func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("ANOTHER_VAR")
+	})
+}
+
+// configDescriptor describes
+//
+//	message Config {
+//	  string url = 1;
+//	  repeated string hosts = 2;
+//	  map<string, string> env = 3;
+//	  google.protobuf.Any extra = 4;
+//	  int32 port = 5;
+//	}
+func configDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	label := func(l descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto_Label { return &l }
+	typ := func(t descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto_Type { return &t }
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("config.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/any.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Config"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("url"), Number: proto.Int32(1), Label: label(descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+				{Name: proto.String("hosts"), Number: proto.Int32(2), Label: label(descriptorpb.FieldDescriptorProto_LABEL_REPEATED), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+				{Name: proto.String("env"), Number: proto.Int32(3), Label: label(descriptorpb.FieldDescriptorProto_LABEL_REPEATED), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_MESSAGE), TypeName: proto.String(".test.Config.EnvEntry")},
+				{Name: proto.String("extra"), Number: proto.Int32(4), Label: label(descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_MESSAGE), TypeName: proto.String(".google.protobuf.Any")},
+				{Name: proto.String("port"), Number: proto.Int32(5), Label: label(descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_INT32)},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("EnvEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("key"), Number: proto.Int32(1), Label: label(descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+					{Name: proto.String("value"), Number: proto.Int32(2), Label: label(descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL), Type: typ(descriptorpb.FieldDescriptorProto_TYPE_STRING)},
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("Config")
+}
+
+func TestDoWellKnownTypes(t *testing.T) {
+	setenv(t)
+
+	s := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"url":   structpb.NewStringValue("$TEST_VAR"),
+		"list":  structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{structpb.NewStringValue("$ANOTHER_VAR")}}),
+		"$KEY":  structpb.NewNumberValue(1),
+		"plain": structpb.NewStringValue("static"),
+	}}
+
+	if err := protoenvsubst.Do(s); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if got := s.Fields["url"].GetStringValue(); got != "test_value" {
+		t.Errorf("url = %q, want %q", got, "test_value")
+	}
+	if got := s.Fields["list"].GetListValue().Values[0].GetStringValue(); got != "another_value" {
+		t.Errorf("list[0] = %q, want %q", got, "another_value")
+	}
+	if _, ok := s.Fields["$KEY"]; !ok {
+		t.Error("map key $KEY was modified")
+	}
+	if got := s.Fields["plain"].GetStringValue(); got != "static" {
+		t.Errorf("plain = %q, want %q", got, "static")
+	}
+}
+
+func TestDoAny(t *testing.T) {
+	setenv(t)
+
+	packed, err := anypb.New(wrapperspb.String("$TEST_VAR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := protoenvsubst.Do(packed); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	var unpacked wrapperspb.StringValue
+	if err := packed.UnmarshalTo(&unpacked); err != nil {
+		t.Fatal(err)
+	}
+	if unpacked.Value != "test_value" {
+		t.Errorf("unpacked = %q, want %q", unpacked.Value, "test_value")
+	}
+}
+
+func TestDoAnyUnknownType(t *testing.T) {
+	packed, err := anypb.New(wrapperspb.String("$TEST_VAR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := protoenvsubst.DoWithTypes(packed, new(protoregistry.Types)); err == nil {
+		t.Error("Do() expected error for unresolvable Any type")
+	}
+}
+
+func TestDoDynamic(t *testing.T) {
+	setenv(t)
+	desc := configDescriptor(t)
+
+	// A dynamic message packed into the Any field of another dynamic message,
+	// resolved through a custom registry
+	types := new(protoregistry.Types)
+	if err := types.RegisterMessage(dynamicpb.NewMessageType(desc)); err != nil {
+		t.Fatal(err)
+	}
+
+	inner := dynamicpb.NewMessage(desc)
+	inner.Set(desc.Fields().ByName("url"), protoreflect.ValueOfString("$ANOTHER_VAR"))
+	innerBytes, err := proto.Marshal(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := dynamicpb.NewMessage(desc)
+	m.Set(desc.Fields().ByName("url"), protoreflect.ValueOfString("$TEST_VAR"))
+	m.Set(desc.Fields().ByName("port"), protoreflect.ValueOfInt32(8080))
+	hosts := m.Mutable(desc.Fields().ByName("hosts")).List()
+	hosts.Append(protoreflect.ValueOfString("$TEST_VAR"))
+	hosts.Append(protoreflect.ValueOfString("static"))
+	env := m.Mutable(desc.Fields().ByName("env")).Map()
+	env.Set(protoreflect.ValueOfString("$KEY").MapKey(), protoreflect.ValueOfString("$ANOTHER_VAR"))
+	m.Set(desc.Fields().ByName("extra"), protoreflect.ValueOfMessage((&anypb.Any{
+		TypeUrl: "type.googleapis.com/test.Config",
+		Value:   innerBytes,
+	}).ProtoReflect()))
+
+	if err := protoenvsubst.DoWithTypes(m, types); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if got := m.Get(desc.Fields().ByName("url")).String(); got != "test_value" {
+		t.Errorf("url = %q, want %q", got, "test_value")
+	}
+	if got := hosts.Get(0).String(); got != "test_value" {
+		t.Errorf("hosts[0] = %q, want %q", got, "test_value")
+	}
+	if got := hosts.Get(1).String(); got != "static" {
+		t.Errorf("hosts[1] = %q, want %q", got, "static")
+	}
+	if got := env.Get(protoreflect.ValueOfString("$KEY").MapKey()).String(); got != "another_value" {
+		t.Errorf("env[$KEY] = %q, want %q", got, "another_value")
+	}
+	if got := m.Get(desc.Fields().ByName("port")).Int(); got != 8080 {
+		t.Errorf("port = %d, want 8080", got)
+	}
+
+	extra := m.Get(desc.Fields().ByName("extra")).Message().Interface()
+	unpacked := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(extra.(*anypb.Any).Value, unpacked); err != nil {
+		t.Fatal(err)
+	}
+	if got := unpacked.Get(desc.Fields().ByName("url")).String(); got != "another_value" {
+		t.Errorf("extra.url = %q, want %q", got, "another_value")
+	}
+}
+
+func TestDoOptions(t *testing.T) {
+	s := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"host": structpb.NewStringValue("$PROTO_HOST"),
+	}}
+	vars := goenvsubst.MapResolver{"PROTO_HOST": "db.internal"}
+
+	if err := protoenvsubst.Do(s, goenvsubst.WithResolver(vars)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if got := s.Fields["host"].GetStringValue(); got != "db.internal" {
+		t.Errorf("host = %q, want %q", got, "db.internal")
+	}
+
+	// The unset variables of every field are reported together
+	missing := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"host": structpb.NewStringValue("$PROTO_MISSING"),
+		"port": structpb.NewStringValue("$PROTO_MISSING_PORT"),
+	}}
+	var unsetErr *goenvsubst.UnsetError
+	if err := protoenvsubst.Do(missing, goenvsubst.Strict()); !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *goenvsubst.UnsetError", err)
+	}
+	if names := slices.Sorted(slices.Values(unsetErr.Names)); !slices.Equal(names, []string{"PROTO_MISSING", "PROTO_MISSING_PORT"}) {
+		t.Errorf("Names = %v, want both variables", unsetErr.Names)
+	}
+}
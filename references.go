@@ -0,0 +1,164 @@
+package goenvsubst
+
+import (
+	"reflect"
+	"slices"
+)
+
+// References returns the distinct names of the variables that Do would
+// look up for v, sorted, without resolving any of them, for example to
+// document the variables a configuration needs or to generate a schema of
+// deployment values. It covers the references in strings, including those
+// in defaults and alternate values such as ${PORT:-$DEFAULT_PORT}, the
+// variables named by env, envsubst and envwhen tags, and with WithMapKeys
+// the references in map keys. Names are listed as written, before
+// WithPrefix applies. opts apply as in Do; a malformed reference or tag
+// makes References fail.
+//
+// Values are scanned as Do walks them, so fields behind nil pointers and
+// elements of empty slices are not covered. The -list flag of the
+// goenvsubst command does the same for files.
+func References(v any, opts ...Option) ([]string, error) {
+	c := &referenceCollector{w: New(opts...).walker()}
+	if err := c.value(reflect.ValueOf(v), ""); err != nil {
+		return nil, err
+	}
+	slices.Sort(c.names)
+	return c.names, nil
+}
+
+// referenceCollector gathers the variables referenced in a value
+type referenceCollector struct {
+	w     *walker
+	names []string
+}
+
+// add records a reference to the variable name
+func (c *referenceCollector) add(name string) {
+	if !slices.Contains(c.names, name) {
+		c.names = append(c.names, name)
+	}
+}
+
+// template records the references in the template s, found at path
+func (c *referenceCollector) template(s, path string) error {
+	placeholders, err := c.w.referenceSyntax().Find(s)
+	if err != nil {
+		return &PathError{Path: displayPath(path), Err: err}
+	}
+	for _, placeholder := range placeholders {
+		c.add(placeholder.Name)
+		if placeholder.Arg != "" {
+			if err := c.template(placeholder.Arg, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// value records the references in v, found at path
+func (c *referenceCollector) value(v reflect.Value, path string) error {
+	if !v.IsValid() || c.w.skipsType(v.Type()) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || !c.w.visit(v) {
+			return nil
+		}
+		return c.value(v.Elem(), path)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return c.value(v.Elem(), path)
+	case reflect.String:
+		return c.template(v.String(), path)
+	case reflect.Struct:
+		if m, ok := concurrentMap(v); ok {
+			var err error
+			m.Range(func(key, value any) bool {
+				err = c.value(reflect.ValueOf(value), keyPath(path, key))
+				return err == nil
+			})
+			return err
+		}
+		return c.fields(v, path)
+	case reflect.Slice:
+		if v.Len() == 0 || !c.w.visit(v) {
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := c.value(v.Index(i), indexPath(path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() || !c.w.visit(v) {
+			return nil
+		}
+		for _, key := range sortedMapKeys(v) {
+			kpath := keyPath(path, key.Interface())
+			if c.w.mapKeys && key.Kind() == reflect.String {
+				if err := c.template(key.String(), kpath); err != nil {
+					return err
+				}
+			}
+			if err := c.value(v.MapIndex(key), kpath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fields records the references in the fields of the struct v and in their
+// tags
+func (c *referenceCollector) fields(v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := t.Field(i)
+		fpath := fieldPath(path, field.Name)
+		if !field.IsExported() {
+			if c.w.unexportedEmbeds && field.Anonymous {
+				if err := c.value(v.Field(i), fpath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		tag, err := parseTag(field, fpath, c.w.referenceSyntax())
+		if err != nil {
+			return err
+		}
+		if tag.skip {
+			continue
+		}
+		if tag.when != nil {
+			c.add(tag.when.variable)
+		}
+		if err := c.field(v.Field(i), tag, fpath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field records the references of the field v, found at path, whose tag
+// is tag
+func (c *referenceCollector) field(v reflect.Value, tag fieldTag, path string) error {
+	switch {
+	case tag.env != "":
+		c.add(tag.env)
+	case tag.source != "":
+		return c.template(tag.source, path)
+	default:
+		return c.value(v, path)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package goenvsubst_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestReferences(t *testing.T) {
+	type Database struct {
+		URL      string
+		Replicas []string
+		Password string `envsubst:"-"`
+	}
+	type Config struct {
+		Database  *Database
+		Port      int    `env:"PORT"`
+		Listen    string `envsubst:"${HOST}:${PORT:-$DEFAULT_PORT}"`
+		SentryDSN string `envwhen:"APP_ENV=production"`
+		Labels    map[string]any
+		Escaped   string
+	}
+	config := &Config{
+		Database: &Database{URL: "postgres://$DB_HOST/${DB_NAME:?required}", Replicas: []string{"$DB_HOST", "$REPLICA"}, Password: "$NOT_LOOKED_UP"},
+		Labels:   map[string]any{"team": "$TEAM", "${REGION}": []any{"$ZONE"}},
+		Escaped:  "$$LITERAL",
+	}
+
+	got, err := goenvsubst.References(config)
+	if err != nil {
+		t.Fatalf("References() error = %v", err)
+	}
+	expected := []string{"APP_ENV", "DB_HOST", "DB_NAME", "DEFAULT_PORT", "HOST", "PORT", "REPLICA", "TEAM", "ZONE"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("References() = %v, want %v", got, expected)
+	}
+
+	got, err = goenvsubst.References(config, goenvsubst.WithMapKeys())
+	if err != nil || !reflect.DeepEqual(got, []string{"APP_ENV", "DB_HOST", "DB_NAME", "DEFAULT_PORT", "HOST", "PORT", "REGION", "REPLICA", "TEAM", "ZONE"}) {
+		t.Errorf("References() with WithMapKeys = %v, %v", got, err)
+	}
+	if config.Database.URL != "postgres://$DB_HOST/${DB_NAME:?required}" {
+		t.Errorf("References() modified the value: %q", config.Database.URL)
+	}
+
+	_, err = goenvsubst.References(&[]string{"ok", "${BROKEN"})
+	if want := "goenvsubst: [1]: syntax error at 1:1: unterminated ${"; err == nil || err.Error() != want {
+		t.Errorf("References() error = %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,69 @@
+package goenvsubst
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Report describes what a substitution did. It holds variable names and
+// paths but never values, so it can be logged without exposing secrets.
+type Report struct {
+	// Referenced lists every variable whose value was looked up, in the
+	// order of first reference, each listed once.
+	Referenced []string
+	// Resolved lists the referenced variables that were set.
+	Resolved []string
+	// Unset lists the referenced variables that were not set.
+	Unset []string
+	// Sources maps the resolved variables to the names of the sources that
+	// supplied them: "env" for the process environment, "dotenv" for the
+	// files of WithDotenv, the scheme for references such as ${vault:...},
+	// and the Name of the Source for WithSources. Variables of resolvers
+	// that do not tell their source are left out.
+	Sources map[string]string
+	// Changed lists the paths of the values that substitution modified,
+	// such as Database.Replicas[2].DSN.
+	Changed []string
+	// Empty lists the paths of the values that were not empty before
+	// substitution and are empty after it.
+	Empty []string
+}
+
+// DoWithReport is like Do but also returns a Report of the variables it
+// looked up and the values it modified. On error the report covers the work
+// done up to the failure.
+func DoWithReport(v any, opts ...Option) (*Report, error) {
+	w := New(opts...).walker()
+	w.report = &Report{}
+	err := w.do(reflect.ValueOf(v))
+	return w.report, err
+}
+
+// addVariable records a lookup of name, which source supplied if set
+func (r *Report) addVariable(name string, set bool, source string) {
+	if slices.Contains(r.Referenced, name) {
+		return
+	}
+	r.Referenced = append(r.Referenced, name)
+	if !set {
+		r.Unset = append(r.Unset, name)
+		return
+	}
+	r.Resolved = append(r.Resolved, name)
+	if source != "" {
+		if r.Sources == nil {
+			r.Sources = map[string]string{}
+		}
+		r.Sources[name] = source
+	}
+}
+
+// addValue records the substitution of the value at path
+func (r *Report) addValue(original, expanded, path string) {
+	if expanded != original && !slices.Contains(r.Changed, path) {
+		r.Changed = append(r.Changed, path)
+	}
+	if expanded == "" && original != "" && !slices.Contains(r.Empty, path) {
+		r.Empty = append(r.Empty, path)
+	}
+}
@@ -0,0 +1,136 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoWithReport(t *testing.T) {
+	os.Setenv("DB_HOST", "db.internal")
+	os.Setenv("EMPTY_VAR", "")
+	os.Setenv("JWT_SECRET", "secret")
+	defer func() {
+		os.Unsetenv("DB_HOST")
+		os.Unsetenv("EMPTY_VAR")
+		os.Unsetenv("JWT_SECRET")
+	}()
+
+	config := &struct {
+		Database struct {
+			URL      string
+			Replicas []string
+		}
+		Secret string
+		Token  string
+		Port   string `envsubst:"default=8080"`
+		Static string
+	}{}
+	config.Database.URL = "postgres://$DB_HOST/app"
+	config.Database.Replicas = []string{"$DB_HOST", "$EMPTY_VAR"}
+	config.Secret = "$JWT_SECRET"
+	config.Token = "$API_TOKEN"
+	config.Port = "$PORT"
+	config.Static = "static"
+
+	report, err := goenvsubst.DoWithReport(config)
+	if err != nil {
+		t.Fatalf("DoWithReport() error = %v", err)
+	}
+
+	expected := &goenvsubst.Report{
+		Referenced: []string{"DB_HOST", "EMPTY_VAR", "JWT_SECRET", "API_TOKEN", "PORT"},
+		Resolved:   []string{"DB_HOST", "EMPTY_VAR", "JWT_SECRET"},
+		Unset:      []string{"API_TOKEN", "PORT"},
+		Sources:    map[string]string{"DB_HOST": "env", "EMPTY_VAR": "env", "JWT_SECRET": "env"},
+		Changed:    []string{"Database.URL", "Database.Replicas[0]", "Database.Replicas[1]", "Secret", "Token", "Port"},
+		Empty:      []string{"Database.Replicas[1]", "Token"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("DoWithReport() = %+v, want %+v", report, expected)
+	}
+}
+
+func TestDoWithSources(t *testing.T) {
+	dir := t.TempDir()
+	dotenv := filepath.Join(dir, ".env")
+	os.WriteFile(dotenv, []byte("DB_HOST=file-host\nDB_PORT=5433\nLOG_LEVEL=debug\n"), 0o600)
+	t.Setenv("DB_HOST", "env-host")
+
+	config := &struct{ Host, Port, Level, Timeout, Password, Missing string }{
+		"$DB_HOST", "$DB_PORT", "$LOG_LEVEL", "$TIMEOUT", "${secrets:db#password}", "$MISSING_VAR",
+	}
+	report, err := goenvsubst.DoWithReport(config,
+		goenvsubst.WithSources(
+			goenvsubst.Env(),
+			goenvsubst.Dotenv(dotenv),
+			goenvsubst.Source{Name: "defaults", Resolver: goenvsubst.MapResolver{"DB_PORT": "5432", "TIMEOUT": "30s"}},
+		),
+		goenvsubst.WithScheme("secrets", goenvsubst.MapResolver{"db#password": "hunter2"}))
+	if err != nil {
+		t.Fatalf("DoWithReport() error = %v", err)
+	}
+
+	expected := &struct{ Host, Port, Level, Timeout, Password, Missing string }{"env-host", "5433", "debug", "30s", "hunter2", ""}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("DoWithReport() = %+v, want %+v", config, expected)
+	}
+	sources := map[string]string{
+		"DB_HOST":             "env",
+		"DB_PORT":             "dotenv",
+		"LOG_LEVEL":           "dotenv",
+		"TIMEOUT":             "defaults",
+		"secrets:db#password": "secrets",
+	}
+	if !reflect.DeepEqual(report.Sources, sources) {
+		t.Errorf("Report.Sources = %v, want %v", report.Sources, sources)
+	}
+
+	failing := goenvsubst.Source{Name: "vault", Resolver: goenvsubst.ResolverFunc(func(string) (string, bool, error) {
+		return "", false, errors.New("sealed")
+	})}
+	err = goenvsubst.Do(&[]string{"$MISSING_VAR"}, goenvsubst.WithSources(goenvsubst.Env(), failing))
+	if want := "goenvsubst: [0]: resolve MISSING_VAR: vault: sealed"; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+}
+
+func TestDoWithOnSubstitute(t *testing.T) {
+	vars := goenvsubst.MapResolver{"DATABASE_URL": "postgres://db", "DB_PASSWORD": "hunter2", "HOST": "db"}
+	config := struct {
+		Database struct{ URL, Password string }
+		Ports    []string
+		Pending  string
+	}{}
+	config.Database.URL = "$DATABASE_URL"
+	config.Database.Password = "${DB_PASSWORD}"
+	config.Ports = []string{"${PORT:-8080}"}
+	config.Pending = "$LATER"
+
+	var got []string
+	onSubstitute := func(path, varName, oldValue, newValue string) {
+		got = append(got, path+" <- "+oldValue+" ("+varName+") = "+newValue)
+	}
+	err := goenvsubst.Do(&config, goenvsubst.WithResolver(vars), goenvsubst.WithKeepUnset(),
+		goenvsubst.WithSecretVars("DB_PASSWORD"), goenvsubst.WithOnSubstitute(onSubstitute))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := []string{
+		"Database.URL <- $DATABASE_URL (DATABASE_URL) = postgres://db",
+		"Database.Password <- ${DB_PASSWORD} (DB_PASSWORD) = <DB_PASSWORD sha256:f52fbd32>",
+		"Ports[0] <- ${PORT:-8080} (PORT) = 8080",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("WithOnSubstitute calls = %q, want %q", got, expected)
+	}
+
+	got = nil
+	if _, err := goenvsubst.Check(&[]string{"$HOST"}, goenvsubst.WithResolver(vars), goenvsubst.WithOnSubstitute(onSubstitute)); err != nil || got != nil {
+		t.Errorf("Check() = %v, called WithOnSubstitute with %q", err, got)
+	}
+}
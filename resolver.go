@@ -0,0 +1,307 @@
+package goenvsubst
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Resolver finds the value of a variable. ok reports whether the variable is
+// set, with the same meaning as for os.LookupEnv; a non-nil error aborts the
+// substitution, for sources that can fail such as remote stores.
+type Resolver interface {
+	Resolve(name string) (value string, ok bool, err error)
+}
+
+// ResolverFunc adapts an ordinary function to the Resolver interface.
+type ResolverFunc func(name string) (string, bool, error)
+
+// Resolve calls f(name).
+func (f ResolverFunc) Resolve(name string) (string, bool, error) {
+	return f(name)
+}
+
+// EnvResolver resolves variables from the process environment. It is what
+// Do uses when no resolver is configured.
+type EnvResolver struct{}
+
+// Resolve looks name up with os.LookupEnv.
+func (EnvResolver) Resolve(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	return value, ok, nil
+}
+
+func (EnvResolver) resolveSource(name string) (string, bool, string, error) {
+	value, ok := os.LookupEnv(name)
+	return value, ok, envSource, nil
+}
+
+// MapResolver resolves variables from a map: a variable is set only if the
+// map has the key.
+type MapResolver map[string]string
+
+// Resolve looks name up in m.
+func (m MapResolver) Resolve(name string) (string, bool, error) {
+	value, ok := m[name]
+	return value, ok, nil
+}
+
+// FileResolver resolves names as the paths of files and returns their
+// contents without the trailing newline, for secrets mounted as files as
+// Docker Swarm and Kubernetes deliver them. Give it a scheme to reference
+// files as ${file:/run/secrets/db_password}:
+//
+//	goenvsubst.WithScheme("file", goenvsubst.FileResolver{})
+//
+// A file that does not exist is unset; other errors, such as a directory or
+// a file that cannot be read, make the call fail. With Root set, paths are
+// resolved relative to it and may not leave it, so templates from other
+// sources cannot read arbitrary files.
+type FileResolver struct {
+	Root string
+}
+
+// Resolve reads the file at name.
+func (f FileResolver) Resolve(name string) (string, bool, error) {
+	var data []byte
+	var err error
+	if f.Root == "" {
+		data, err = os.ReadFile(name)
+	} else {
+		data, err = readFileIn(f.Root, strings.TrimPrefix(name, "/"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return trimNewline(string(data)), true, nil
+}
+
+// trimNewline removes the line break that ends s, if any
+func trimNewline(s string) string {
+	if s, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(s, "\r")
+	}
+	return s
+}
+
+// readFileIn reads the file at name within the directory root
+func readFileIn(root, name string) ([]byte, error) {
+	r, err := os.OpenRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	file, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// ChainResolver tries its resolvers in order and uses the first one that has
+// the variable, so sources can be layered, for example explicit values, then
+// the environment, then defaults:
+//
+//	goenvsubst.ChainResolver{
+//		goenvsubst.MapResolver(overrides),
+//		goenvsubst.EnvResolver{},
+//		goenvsubst.MapResolver(defaults),
+//	}
+//
+// An error from any resolver stops the chain.
+type ChainResolver []Resolver
+
+// Resolve returns the value from the first resolver that has name.
+func (c ChainResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(c.resolveSource(name))
+}
+
+func (c ChainResolver) resolveSource(name string) (string, bool, string, error) {
+	for _, r := range c {
+		value, ok, source, err := resolveFrom(r, name)
+		if err != nil || ok {
+			return value, ok, source, err
+		}
+	}
+	return "", false, "", nil
+}
+
+// prefixResolver looks names up with a prefix and, with fallback, without it
+type prefixResolver struct {
+	base     Resolver
+	prefix   string
+	fallback bool
+}
+
+func (p *prefixResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(p.resolveSource(name))
+}
+
+func (p *prefixResolver) resolveSource(name string) (string, bool, string, error) {
+	base := p.base
+	if base == nil {
+		base = EnvResolver{}
+	}
+	value, ok, source, err := resolveFrom(base, p.prefix+name)
+	if err != nil || ok || !p.fallback {
+		return value, ok, source, err
+	}
+	return resolveFrom(base, name)
+}
+
+// profileResolver looks names up with the suffix of a profile, given
+// directly or by the variable named variable, and falls back to the bare name
+type profileResolver struct {
+	base     Resolver
+	profile  string
+	variable string
+}
+
+func (p *profileResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(p.resolveSource(name))
+}
+
+func (p *profileResolver) resolveSource(name string) (string, bool, string, error) {
+	base := p.base
+	if base == nil {
+		base = EnvResolver{}
+	}
+	profile := p.profile
+	if p.variable != "" && name != p.variable {
+		var err error
+		if profile, _, err = base.Resolve(p.variable); err != nil {
+			return "", false, "", err
+		}
+	}
+	if profile != "" {
+		value, ok, source, err := resolveFrom(base, name+"_"+strings.ToUpper(profile))
+		if err != nil || ok {
+			return value, ok, source, err
+		}
+	}
+	return resolveFrom(base, name)
+}
+
+// schemeResolver resolves the references of a scheme with r and the others
+// with base
+type schemeResolver struct {
+	base   Resolver
+	scheme string
+	r      Resolver
+}
+
+func (s *schemeResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(s.resolveSource(name))
+}
+
+// resolveSource reports the scheme as the source of its references
+func (s *schemeResolver) resolveSource(name string) (string, bool, string, error) {
+	if path, ok := strings.CutPrefix(name, s.scheme+":"); ok {
+		value, ok, err := s.r.Resolve(path)
+		return value, ok, s.scheme, err
+	}
+	base := s.base
+	if base == nil {
+		base = EnvResolver{}
+	}
+	return resolveFrom(base, name)
+}
+
+// fileFallbackResolver reads unset variables from the files named by their
+// _FILE variables
+type fileFallbackResolver struct {
+	base Resolver
+}
+
+func (f *fileFallbackResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(f.resolveSource(name))
+}
+
+// resolveSource reports the source of the _FILE variable as the source of
+// the value read from the file
+func (f *fileFallbackResolver) resolveSource(name string) (string, bool, string, error) {
+	base := f.base
+	if base == nil {
+		base = EnvResolver{}
+	}
+	value, ok, source, err := resolveFrom(base, name)
+	if err != nil || ok {
+		return value, ok, source, err
+	}
+	path, ok, source, err := resolveFrom(base, name+"_FILE")
+	if err != nil || !ok {
+		return "", false, "", err
+	}
+	value, ok, err = FileResolver{}.Resolve(path)
+	if err == nil && !ok {
+		err = fmt.Errorf("%s_FILE: %w", name, fs.ErrNotExist)
+	}
+	if err != nil {
+		return "", false, "", err
+	}
+	return value, true, source, nil
+}
+
+// foldResolver looks names up case-insensitively
+type foldResolver struct {
+	base Resolver
+}
+
+func (f *foldResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(f.resolveSource(name))
+}
+
+func (f *foldResolver) resolveSource(name string) (string, bool, string, error) {
+	base := f.base
+	if base == nil {
+		base = EnvResolver{}
+	}
+	value, ok, source, err := resolveFrom(base, name)
+	if err != nil || ok {
+		return value, ok, source, err
+	}
+	for _, spelling := range spellings(base, name) {
+		value, ok, source, err := resolveFrom(base, spelling)
+		if err != nil || ok {
+			return value, ok, source, err
+		}
+	}
+	return "", false, "", nil
+}
+
+// spellings returns the other spellings of name that base may know, in a
+// stable order. The names of the environment and of a MapResolver are
+// searched; for other resolvers the upper and lower case forms are tried.
+func spellings(base Resolver, name string) []string {
+	var keys []string
+	switch base := base.(type) {
+	case EnvResolver:
+		for _, kv := range os.Environ() {
+			key, _, _ := strings.Cut(kv, "=")
+			keys = append(keys, key)
+		}
+	case MapResolver:
+		for key := range base {
+			keys = append(keys, key)
+		}
+	default:
+		keys = []string{strings.ToUpper(name), strings.ToLower(name)}
+	}
+
+	var found []string
+	for _, key := range keys {
+		if key != name && strings.EqualFold(key, name) && !slices.Contains(found, key) {
+			found = append(found, key)
+		}
+	}
+	slices.Sort(found)
+	return found
+}
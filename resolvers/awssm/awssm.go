@@ -0,0 +1,142 @@
+// Package awssm resolves references to secrets stored in AWS Secrets
+// Manager, for use with goenvsubst.WithScheme:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("eu-west-1"))
+//	if err != nil {
+//		return err
+//	}
+//	err = goenvsubst.Do(&appConfig, goenvsubst.WithScheme("awssm", awssm.NewFromConfig(cfg)))
+//
+// A reference names a secret by its name or ARN, as in ${awssm:prod/db}, and
+// may extract a key from a secret whose value is a JSON object, as in
+// ${awssm:prod/db#password}.
+package awssm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// Client is the part of *secretsmanager.Client that Resolver uses, so that
+// tests can provide a fake.
+type Client interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithTTL makes the Resolver fetch a secret again once ttl has passed since
+// it was fetched, for long-lived resolvers of rotated secrets. Without it,
+// secrets are fetched once for the lifetime of the Resolver.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithContext sets the context of the requests to Secrets Manager, which
+// otherwise use context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(r *Resolver) {
+		r.ctx = ctx
+	}
+}
+
+// Resolver resolves references to secrets of AWS Secrets Manager. It
+// implements goenvsubst.Resolver and is safe for concurrent use.
+type Resolver struct {
+	client Client
+	ctx    context.Context
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]secret
+}
+
+// secret is a fetched secret
+type secret struct {
+	value   string
+	found   bool
+	fetched time.Time
+}
+
+// New returns a Resolver that fetches secrets with client, usually a
+// *secretsmanager.Client.
+func New(client Client, opts ...Option) *Resolver {
+	r := &Resolver{client: client, ctx: context.Background(), cache: map[string]secret{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewFromConfig returns a Resolver that fetches secrets with the region and
+// credentials of cfg.
+func NewFromConfig(cfg aws.Config, opts ...Option) *Resolver {
+	return New(secretsmanager.NewFromConfig(cfg), opts...)
+}
+
+// Resolve returns the value of the secret named by name, the name or ARN of
+// a secret optionally followed by # and a key of the JSON object it holds.
+// A secret that does not exist, or an object without the key, is unset.
+// String values of keys are returned as is, and other JSON values as JSON.
+func (r *Resolver) Resolve(name string) (string, bool, error) {
+	id, key, hasKey := strings.Cut(name, "#")
+	s, err := r.secret(id)
+	if err != nil || !s.found || !hasKey {
+		return s.value, s.found, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s.value), &fields); err != nil {
+		return "", false, fmt.Errorf("awssm: secret %s is not a JSON object: %w", id, err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", false, nil
+	}
+	var value string
+	if err := json.Unmarshal(field, &value); err != nil {
+		return string(field), true, nil
+	}
+	return value, true, nil
+}
+
+// secret returns the secret id, from the cache while it is fresh
+func (r *Resolver) secret(id string) (secret, error) {
+	// The lock only guards the cache, so slow fetches of different secrets
+	// do not wait for each other
+	r.mu.Lock()
+	s, ok := r.cache[id]
+	r.mu.Unlock()
+	if ok && (r.ttl <= 0 || time.Since(s.fetched) < r.ttl) {
+		return s, nil
+	}
+
+	out, err := r.client.GetSecretValue(r.ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	s = secret{fetched: time.Now()}
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+	case err != nil:
+		return secret{}, fmt.Errorf("awssm: get secret %s: %w", id, err)
+	case out.SecretString != nil:
+		s.value, s.found = *out.SecretString, true
+	default:
+		s.value, s.found = string(out.SecretBinary), true
+	}
+	r.mu.Lock()
+	r.cache[id] = s
+	r.mu.Unlock()
+	return s, nil
+}
@@ -0,0 +1,111 @@
+package awssm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/resolvers/awssm"
+)
+
+// fakeClient serves secrets from a map and counts the requests
+type fakeClient struct {
+	secrets  map[string]string
+	requests int
+	err      error
+}
+
+func (c *fakeClient) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	c.requests++
+	if c.err != nil {
+		return nil, c.err
+	}
+	value, ok := c.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("not found")}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func TestResolver(t *testing.T) {
+	client := &fakeClient{secrets: map[string]string{
+		"prod/db":  `{"username": "app", "password": "p@ss", "port": 5432}`,
+		"prod/api": "t0ken",
+	}}
+	config := &struct{ DSN, Token, Missing string }{
+		"postgres://${awssm:prod/db#username}:${awssm:prod/db#password}@db:${awssm:prod/db#port}/app",
+		"${awssm:prod/api}",
+		"${awssm:prod/cache:-none}${awssm:prod/db#other}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("awssm", awssm.New(client))); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.DSN != "postgres://app:p@ss@db:5432/app" || config.Token != "t0ken" || config.Missing != "none" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if client.requests != 3 {
+		t.Errorf("requests = %d, want one per secret", client.requests)
+	}
+
+	if _, _, err := awssm.New(client).Resolve("prod/api#token"); err == nil {
+		t.Error("Resolve() of a key of a secret that is not JSON error = nil")
+	}
+}
+
+func TestResolverTTL(t *testing.T) {
+	client := &fakeClient{secrets: map[string]string{"prod/api": "t0ken"}}
+	r := awssm.New(client, awssm.WithTTL(time.Millisecond))
+	for range 2 {
+		if value, ok, err := r.Resolve("prod/api"); value != "t0ken" || !ok || err != nil {
+			t.Fatalf("Resolve() = %q, %v, %v", value, ok, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if client.requests != 2 {
+		t.Errorf("requests = %d, want the secret fetched again after the TTL", client.requests)
+	}
+}
+
+func TestResolverError(t *testing.T) {
+	errDenied := errors.New("access denied")
+	err := goenvsubst.Do(&[]string{"${awssm:prod/db}"}, goenvsubst.WithScheme("awssm", awssm.New(&fakeClient{err: errDenied})))
+	if !errors.Is(err, errDenied) {
+		t.Errorf("Do() error = %v, want %v", err, errDenied)
+	}
+}
+
+// slowClient blocks the request for the secret slow until release is closed
+type slowClient struct {
+	release chan struct{}
+}
+
+func (c *slowClient) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if aws.ToString(params.SecretId) == "slow" {
+		<-c.release
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: params.SecretId}, nil
+}
+
+func TestResolverConcurrentFetches(t *testing.T) {
+	client := &slowClient{release: make(chan struct{})}
+	r := awssm.New(client)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Resolve("slow")
+	}()
+
+	// A slow fetch does not hold up the others
+	time.Sleep(10 * time.Millisecond)
+	if value, ok, err := r.Resolve("fast"); err != nil || !ok || value != "fast" {
+		t.Errorf("Resolve() = %q, %v, %v, want fast", value, ok, err)
+	}
+	close(client.release)
+	<-done
+}
@@ -0,0 +1,141 @@
+// Package azkeyvault resolves references to secrets stored in Azure Key
+// Vault, for use with goenvsubst.WithScheme:
+//
+//	credential, err := azidentity.NewDefaultAzureCredential(nil)
+//	if err != nil {
+//		return err
+//	}
+//	client, err := azsecrets.NewClient("https://my-vault.vault.azure.net", credential, nil)
+//	if err != nil {
+//		return err
+//	}
+//	err = goenvsubst.Do(&appConfig, goenvsubst.WithScheme("azkv", azkeyvault.New(client)))
+//
+// A reference names a secret of the vault, as in ${azkv:db-password}. The
+// latest version is read unless one follows an @, as in
+// ${azkv:db-password@4f1a9c}, and a key can be extracted from a secret that
+// holds a JSON object, as in ${azkv:db#password}.
+package azkeyvault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// Client is the part of *azsecrets.Client that Resolver uses, so that tests
+// can provide a fake.
+type Client interface {
+	GetSecret(ctx context.Context, name string, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
+}
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithTTL makes the Resolver read a secret again once ttl has passed since
+// it was read, for long-lived resolvers of rotated secrets. Without it,
+// secrets are read once for the lifetime of the Resolver.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithContext sets the context of the requests to Key Vault, which
+// otherwise use context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(r *Resolver) {
+		r.ctx = ctx
+	}
+}
+
+// Resolver resolves references to secrets of a Key Vault. It implements
+// goenvsubst.Resolver and is safe for concurrent use.
+type Resolver struct {
+	client Client
+	ctx    context.Context
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]secret
+}
+
+// secret is a version of a secret that was read
+type secret struct {
+	value   string
+	found   bool
+	fetched time.Time
+}
+
+// New returns a Resolver that reads secrets with client, usually an
+// *azsecrets.Client, which holds the URL of the vault and the credential.
+func New(client Client, opts ...Option) *Resolver {
+	r := &Resolver{client: client, ctx: context.Background(), cache: map[string]secret{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve returns the value of the secret named by name, optionally
+// followed by @ and a version, and by # and a key of the JSON object it
+// holds. A secret or version that does not exist, or an object without the
+// key, is unset. String values of keys are returned as is, and other JSON
+// values as JSON.
+func (r *Resolver) Resolve(name string) (string, bool, error) {
+	secretName, key, hasKey := strings.Cut(name, "#")
+	s, err := r.secret(secretName)
+	if err != nil || !s.found || !hasKey {
+		return s.value, s.found, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s.value), &fields); err != nil {
+		return "", false, fmt.Errorf("azkeyvault: secret %s is not a JSON object: %w", secretName, err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", false, nil
+	}
+	var value string
+	if err := json.Unmarshal(field, &value); err != nil {
+		return string(field), true, nil
+	}
+	return value, true, nil
+}
+
+// secret returns the secret name, from the cache while it is fresh
+func (r *Resolver) secret(name string) (secret, error) {
+	// The lock only guards the cache, so slow fetches of different secrets
+	// do not wait for each other
+	r.mu.Lock()
+	s, ok := r.cache[name]
+	r.mu.Unlock()
+	if ok && (r.ttl <= 0 || time.Since(s.fetched) < r.ttl) {
+		return s, nil
+	}
+
+	secretName, version, _ := strings.Cut(name, "@")
+	resp, err := r.client.GetSecret(r.ctx, secretName, version, nil)
+	s = secret{fetched: time.Now()}
+	var respErr *azcore.ResponseError
+	switch {
+	case errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound:
+	case err != nil:
+		return secret{}, fmt.Errorf("azkeyvault: get secret %s: %w", name, err)
+	case resp.Value != nil:
+		s.value, s.found = *resp.Value, true
+	}
+	r.mu.Lock()
+	r.cache[name] = s
+	r.mu.Unlock()
+	return s, nil
+}
@@ -0,0 +1,65 @@
+package azkeyvault_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/resolvers/azkeyvault"
+)
+
+// fakeClient serves secrets from a map, keyed by name and version, and
+// counts the requests
+type fakeClient struct {
+	secrets  map[string]string
+	requests int
+	err      error
+}
+
+func (c *fakeClient) GetSecret(_ context.Context, name, version string, _ *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	c.requests++
+	if c.err != nil {
+		return azsecrets.GetSecretResponse{}, c.err
+	}
+	value, ok := c.secrets[name+"@"+version]
+	if !ok {
+		return azsecrets.GetSecretResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "SecretNotFound"}
+	}
+	return azsecrets.GetSecretResponse{Secret: azsecrets.Secret{Value: &value}}, nil
+}
+
+func TestResolver(t *testing.T) {
+	client := &fakeClient{secrets: map[string]string{
+		"db@":           `{"user": "app", "password": "p@ss"}`,
+		"api-token@":    "t0ken",
+		"api-token@4f1": "old-t0ken",
+	}}
+	config := &struct{ DSN, Token, OldToken, Missing string }{
+		"postgres://${azkv:db#user}:${azkv:db#password}@db/app",
+		"${azkv:api-token}",
+		"${azkv:api-token@4f1}",
+		"${azkv:cache:-none}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("azkv", azkeyvault.New(client))); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.DSN != "postgres://app:p@ss@db/app" || config.Token != "t0ken" || config.OldToken != "old-t0ken" || config.Missing != "none" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if client.requests != 4 {
+		t.Errorf("requests = %d, want one per secret version", client.requests)
+	}
+}
+
+func TestResolverError(t *testing.T) {
+	errDenied := &azcore.ResponseError{StatusCode: http.StatusForbidden, ErrorCode: "Forbidden"}
+	err := goenvsubst.Do(&[]string{"${azkv:db}"}, goenvsubst.WithScheme("azkv", azkeyvault.New(&fakeClient{err: errDenied})))
+	if !errors.Is(err, errDenied) {
+		t.Errorf("Do() error = %v, want %v", err, errDenied)
+	}
+}
@@ -0,0 +1,110 @@
+// Package consul resolves references to keys of the Consul KV store, for
+// use with goenvsubst.WithScheme:
+//
+//	client, err := api.NewClient(api.DefaultConfig())
+//	if err != nil {
+//		return err
+//	}
+//	err = goenvsubst.Do(&appConfig, goenvsubst.WithScheme("consul", consul.New(client.KV())))
+//
+// A reference names a key, as in ${consul:config/app/feature-x}, and
+// expands to its value.
+package consul
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// KV is the part of *api.KV that Resolver uses, so that tests can provide a
+// fake.
+type KV interface {
+	Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error)
+}
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithTTL makes the Resolver read a key again once ttl has passed since it
+// was read, for long-lived resolvers of values that change. Without it,
+// keys are read once for the lifetime of the Resolver.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithContext sets the context of the requests to Consul, which otherwise
+// use context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(r *Resolver) {
+		r.ctx = ctx
+	}
+}
+
+// WithQueryOptions sets the options of the requests to Consul, such as the
+// datacenter, the namespace or the consistency mode.
+func WithQueryOptions(q api.QueryOptions) Option {
+	return func(r *Resolver) {
+		r.query = q
+	}
+}
+
+// Resolver resolves references to keys of Consul KV. It implements
+// goenvsubst.Resolver and is safe for concurrent use.
+type Resolver struct {
+	kv    KV
+	ctx   context.Context
+	query api.QueryOptions
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]value
+}
+
+// value is the value of a key that was read
+type value struct {
+	value   string
+	found   bool
+	fetched time.Time
+}
+
+// New returns a Resolver that reads keys with kv, usually the *api.KV of a
+// client.
+func New(kv KV, opts ...Option) *Resolver {
+	r := &Resolver{kv: kv, ctx: context.Background(), cache: map[string]value{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve returns the value of the key name. A key that does not exist is
+// unset.
+func (r *Resolver) Resolve(name string) (string, bool, error) {
+	// The lock only guards the cache, so slow reads of different keys
+	// do not wait for each other
+	r.mu.Lock()
+	v, ok := r.cache[name]
+	r.mu.Unlock()
+	if ok && (r.ttl <= 0 || time.Since(v.fetched) < r.ttl) {
+		return v.value, v.found, nil
+	}
+
+	pair, _, err := r.kv.Get(name, r.query.WithContext(r.ctx))
+	if err != nil {
+		return "", false, fmt.Errorf("consul: get %s: %w", name, err)
+	}
+	v = value{fetched: time.Now()}
+	if pair != nil {
+		v.value, v.found = string(pair.Value), true
+	}
+	r.mu.Lock()
+	r.cache[name] = v
+	r.mu.Unlock()
+	return v.value, v.found, nil
+}
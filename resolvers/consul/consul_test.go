@@ -0,0 +1,71 @@
+package consul_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/resolvers/consul"
+)
+
+// fakeKV serves keys from a map and counts the requests
+type fakeKV struct {
+	values     map[string]string
+	requests   int
+	datacenter string
+	err        error
+}
+
+func (kv *fakeKV) Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error) {
+	kv.requests++
+	kv.datacenter = q.Datacenter
+	if kv.err != nil {
+		return nil, nil, kv.err
+	}
+	value, ok := kv.values[key]
+	if !ok {
+		return nil, &api.QueryMeta{}, nil
+	}
+	return &api.KVPair{Key: key, Value: []byte(value)}, &api.QueryMeta{}, nil
+}
+
+func TestResolver(t *testing.T) {
+	kv := &fakeKV{values: map[string]string{"config/app/feature-x": "on", "config/app/replicas": "3"}}
+	config := &struct{ Feature, Replicas, Missing string }{
+		"${consul:config/app/feature-x}",
+		"${consul:config/app/replicas}/${consul:config/app/replicas}",
+		"${consul:config/app/feature-y:-off}",
+	}
+	resolver := consul.New(kv, consul.WithQueryOptions(api.QueryOptions{Datacenter: "eu1"}))
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("consul", resolver)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Feature != "on" || config.Replicas != "3/3" || config.Missing != "off" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if kv.requests != 3 || kv.datacenter != "eu1" {
+		t.Errorf("requests = %d to %q, want one per key to eu1", kv.requests, kv.datacenter)
+	}
+}
+
+func TestResolverTTL(t *testing.T) {
+	kv := &fakeKV{values: map[string]string{"config/app/feature-x": "on"}}
+	r := consul.New(kv, consul.WithTTL(time.Millisecond))
+	r.Resolve("config/app/feature-x")
+	time.Sleep(2 * time.Millisecond)
+	kv.values["config/app/feature-x"] = "off"
+	if value, ok, err := r.Resolve("config/app/feature-x"); value != "off" || !ok || err != nil {
+		t.Errorf("Resolve() after the TTL = %q, %v, %v, want the new value", value, ok, err)
+	}
+}
+
+func TestResolverError(t *testing.T) {
+	errUnavailable := errors.New("no cluster leader")
+	err := goenvsubst.Do(&[]string{"${consul:config/app}"}, goenvsubst.WithScheme("consul", consul.New(&fakeKV{err: errUnavailable})))
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("Do() error = %v, want %v", err, errUnavailable)
+	}
+}
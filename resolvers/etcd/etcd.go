@@ -0,0 +1,107 @@
+// Package etcd resolves references to keys of etcd, for use with
+// goenvsubst.WithScheme:
+//
+//	client, err := clientv3.New(clientv3.Config{Endpoints: []string{"localhost:2379"}})
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	err = goenvsubst.Do(&appConfig, goenvsubst.WithScheme("etcd", etcd.New(client)))
+//
+// A reference names a key, as in ${etcd:/config/app/feature-x}, and expands
+// to its value.
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithTTL makes the Resolver read a key again once ttl has passed since it
+// was read, for long-lived resolvers of values that change. Without it,
+// keys are read once for the lifetime of the Resolver.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithContext sets the context of the requests to etcd, which otherwise use
+// context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(r *Resolver) {
+		r.ctx = ctx
+	}
+}
+
+// WithPrefix prepends prefix to the keys of references, so that
+// ${etcd:feature-x} reads /config/app/feature-x with
+// WithPrefix("/config/app/").
+func WithPrefix(prefix string) Option {
+	return func(r *Resolver) {
+		r.prefix = prefix
+	}
+}
+
+// Resolver resolves references to keys of etcd. It implements
+// goenvsubst.Resolver and is safe for concurrent use.
+type Resolver struct {
+	kv     clientv3.KV
+	ctx    context.Context
+	prefix string
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]value
+}
+
+// value is the value of a key that was read
+type value struct {
+	value   string
+	found   bool
+	fetched time.Time
+}
+
+// New returns a Resolver that reads keys with kv, usually a
+// *clientv3.Client.
+func New(kv clientv3.KV, opts ...Option) *Resolver {
+	r := &Resolver{kv: kv, ctx: context.Background(), cache: map[string]value{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve returns the value of the key name. A key that does not exist is
+// unset.
+func (r *Resolver) Resolve(name string) (string, bool, error) {
+	key := r.prefix + name
+	// The lock only guards the cache, so slow reads of different keys
+	// do not wait for each other
+	r.mu.Lock()
+	v, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && (r.ttl <= 0 || time.Since(v.fetched) < r.ttl) {
+		return v.value, v.found, nil
+	}
+
+	resp, err := r.kv.Get(r.ctx, key)
+	if err != nil {
+		return "", false, fmt.Errorf("etcd: get %s: %w", key, err)
+	}
+	v = value{fetched: time.Now()}
+	if len(resp.Kvs) > 0 {
+		v.value, v.found = string(resp.Kvs[0].Value), true
+	}
+	r.mu.Lock()
+	r.cache[key] = v
+	r.mu.Unlock()
+	return v.value, v.found, nil
+}
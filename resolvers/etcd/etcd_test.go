@@ -0,0 +1,65 @@
+package etcd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/resolvers/etcd"
+)
+
+// fakeKV serves keys from a map and counts the requests; its other methods
+// are not used
+type fakeKV struct {
+	clientv3.KV
+	values   map[string]string
+	requests int
+	err      error
+}
+
+func (kv *fakeKV) Get(_ context.Context, key string, _ ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	kv.requests++
+	if kv.err != nil {
+		return nil, kv.err
+	}
+	resp := &clientv3.GetResponse{}
+	if value, ok := kv.values[key]; ok {
+		resp.Kvs = []*mvccpb.KeyValue{{Key: []byte(key), Value: []byte(value)}}
+	}
+	return resp, nil
+}
+
+func TestResolver(t *testing.T) {
+	kv := &fakeKV{values: map[string]string{"/config/app/feature-x": "on", "/config/app/replicas": "3"}}
+	config := &struct{ Feature, Replicas, Missing string }{
+		"${etcd:/config/app/feature-x}",
+		"${etcd:/config/app/replicas}/${etcd:/config/app/replicas}",
+		"${etcd:/config/app/feature-y:-off}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("etcd", etcd.New(kv))); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Feature != "on" || config.Replicas != "3/3" || config.Missing != "off" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if kv.requests != 3 {
+		t.Errorf("requests = %d, want one per key", kv.requests)
+	}
+
+	r := etcd.New(kv, etcd.WithPrefix("/config/app/"))
+	if value, ok, err := r.Resolve("feature-x"); value != "on" || !ok || err != nil {
+		t.Errorf("Resolve() with WithPrefix = %q, %v, %v", value, ok, err)
+	}
+}
+
+func TestResolverError(t *testing.T) {
+	errUnavailable := errors.New("etcdserver: no leader")
+	err := goenvsubst.Do(&[]string{"${etcd:/config/app}"}, goenvsubst.WithScheme("etcd", etcd.New(&fakeKV{err: errUnavailable})))
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("Do() error = %v, want %v", err, errUnavailable)
+	}
+}
@@ -0,0 +1,168 @@
+// Package gcpsm resolves references to secrets stored in Google Cloud
+// Secret Manager, for use with goenvsubst.WithScheme:
+//
+//	client, err := secretmanager.NewClient(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	resolver := gcpsm.New(client, gcpsm.WithProject("my-project"))
+//	err = goenvsubst.Do(&appConfig, goenvsubst.WithScheme("gcpsm", resolver))
+//
+// A reference names a secret of the project, as in ${gcpsm:db-password}, or
+// by its resource name, as in ${gcpsm:projects/my-project/secrets/db-password}.
+// The latest version is read unless one follows an @, as in
+// ${gcpsm:db-password@3}, and a key can be extracted from a secret that
+// holds a JSON object, as in ${gcpsm:db#password}.
+package gcpsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client is the part of *secretmanager.Client that Resolver uses, so that
+// tests can provide a fake.
+type Client interface {
+	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error)
+}
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithProject sets the project of the secrets that references name by
+// their ID alone.
+func WithProject(project string) Option {
+	return func(r *Resolver) {
+		r.project = project
+	}
+}
+
+// WithTTL makes the Resolver read a secret again once ttl has passed since
+// it was read, for long-lived resolvers of rotated secrets. Without it,
+// secrets are read once for the lifetime of the Resolver.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithContext sets the context of the requests to Secret Manager, which
+// otherwise use context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(r *Resolver) {
+		r.ctx = ctx
+	}
+}
+
+// Resolver resolves references to secrets of Secret Manager. It implements
+// goenvsubst.Resolver and is safe for concurrent use.
+type Resolver struct {
+	client  Client
+	ctx     context.Context
+	project string
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]secret
+}
+
+// secret is a version of a secret that was read
+type secret struct {
+	value   string
+	found   bool
+	fetched time.Time
+}
+
+// New returns a Resolver that reads secrets with client, usually a
+// *secretmanager.Client.
+func New(client Client, opts ...Option) *Resolver {
+	r := &Resolver{client: client, ctx: context.Background(), cache: map[string]secret{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve returns the value of the secret version named by name, optionally
+// followed by # and a key of the JSON object it holds. A secret or version
+// that does not exist, or an object without the key, is unset. String
+// values of keys are returned as is, and other JSON values as JSON.
+func (r *Resolver) Resolve(name string) (string, bool, error) {
+	secretName, key, hasKey := strings.Cut(name, "#")
+	version, err := r.versionName(secretName)
+	if err != nil {
+		return "", false, err
+	}
+	s, err := r.secret(version)
+	if err != nil || !s.found || !hasKey {
+		return s.value, s.found, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s.value), &fields); err != nil {
+		return "", false, fmt.Errorf("gcpsm: secret %s is not a JSON object: %w", secretName, err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", false, nil
+	}
+	var value string
+	if err := json.Unmarshal(field, &value); err != nil {
+		return string(field), true, nil
+	}
+	return value, true, nil
+}
+
+// versionName returns the resource name of the version that name refers to
+func (r *Resolver) versionName(name string) (string, error) {
+	name, version, hasVersion := strings.Cut(name, "@")
+	if !hasVersion {
+		version = "latest"
+	}
+	if !strings.HasPrefix(name, "projects/") {
+		if r.project == "" {
+			return "", fmt.Errorf("gcpsm: secret %s needs a project, see WithProject", name)
+		}
+		name = "projects/" + r.project + "/secrets/" + name
+	}
+	if strings.Contains(name, "/versions/") {
+		return name, nil
+	}
+	return name + "/versions/" + version, nil
+}
+
+// secret returns the secret version, from the cache while it is fresh
+func (r *Resolver) secret(version string) (secret, error) {
+	// The lock only guards the cache, so slow fetches of different secrets
+	// do not wait for each other
+	r.mu.Lock()
+	s, ok := r.cache[version]
+	r.mu.Unlock()
+	if ok && (r.ttl <= 0 || time.Since(s.fetched) < r.ttl) {
+		return s, nil
+	}
+
+	resp, err := r.client.AccessSecretVersion(r.ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: version})
+	s = secret{fetched: time.Now()}
+	switch {
+	case status.Code(err) == codes.NotFound:
+	case err != nil:
+		return secret{}, fmt.Errorf("gcpsm: access %s: %w", version, err)
+	default:
+		s.value, s.found = string(resp.GetPayload().GetData()), true
+	}
+	r.mu.Lock()
+	r.cache[version] = s
+	r.mu.Unlock()
+	return s, nil
+}
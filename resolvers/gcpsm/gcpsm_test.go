@@ -0,0 +1,57 @@
+package gcpsm_test
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/resolvers/gcpsm"
+)
+
+// fakeClient serves secret versions from a map and counts the requests
+type fakeClient struct {
+	versions map[string]string
+	requests int
+}
+
+func (c *fakeClient) AccessSecretVersion(_ context.Context, req *secretmanagerpb.AccessSecretVersionRequest, _ ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	c.requests++
+	value, ok := c.versions[req.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{Payload: &secretmanagerpb.SecretPayload{Data: []byte(value)}}, nil
+}
+
+func TestResolver(t *testing.T) {
+	client := &fakeClient{versions: map[string]string{
+		"projects/app/secrets/db/versions/latest":         `{"user": "app", "password": "p@ss"}`,
+		"projects/app/secrets/api-token/versions/2":       "old-t0ken",
+		"projects/shared/secrets/license/versions/latest": "L1C3NS3",
+	}}
+	config := &struct{ DSN, Token, License, Missing string }{
+		"postgres://${gcpsm:db#user}:${gcpsm:db#password}@db/app",
+		"${gcpsm:api-token@2}",
+		"${gcpsm:projects/shared/secrets/license}",
+		"${gcpsm:cache:-none}",
+	}
+	resolver := gcpsm.New(client, gcpsm.WithProject("app"))
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("gcpsm", resolver)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.DSN != "postgres://app:p@ss@db/app" || config.Token != "old-t0ken" || config.License != "L1C3NS3" || config.Missing != "none" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if client.requests != 4 {
+		t.Errorf("requests = %d, want one per secret version", client.requests)
+	}
+
+	if _, _, err := gcpsm.New(client).Resolve("db"); err == nil {
+		t.Error("Resolve() of a secret ID without a project error = nil")
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/iamolegga/goenvsubst/resolvers/gcpsm
+
+go 1.24.4
+
+require (
+	cloud.google.com/go/secretmanager v1.14.5
+	github.com/googleapis/gax-go/v2 v2.14.1
+	github.com/iamolegga/goenvsubst v1.0.0
+	google.golang.org/grpc v1.70.0
+)
+
+require (
+	cloud.google.com/go/iam v1.3.1 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/api v0.220.0 // indirect
+	google.golang.org/genproto v0.0.0-20250122153221-138b5a5a4fd4 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20250207221924-e9438ea467c6 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250127172529-29210b9bc287 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+)
@@ -0,0 +1,173 @@
+// Package vault resolves references to secrets stored in HashiCorp Vault,
+// for use with goenvsubst.WithScheme:
+//
+//	client, err := api.NewClient(api.DefaultConfig())
+//	if err != nil {
+//		return err
+//	}
+//	err = goenvsubst.Do(&appConfig, goenvsubst.WithScheme("vault", vault.New(client)))
+//
+// A reference names the path of a secret and a key of its data, as in
+// ${vault:kv/data/app#db_password}. Secrets of version 2 of the KV engine are
+// read at their data path, and their key is looked up in the secret's data
+// rather than in its envelope.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithTTL makes the Resolver read a secret again once ttl has passed since
+// it was read, for long-lived resolvers of rotated secrets. Without it,
+// secrets are read once for the lifetime of the Resolver. Either way, a
+// secret is read again once its lease expires.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+// WithContext sets the context of the requests to Vault, which otherwise
+// use context.Background.
+func WithContext(ctx context.Context) Option {
+	return func(r *Resolver) {
+		r.ctx = ctx
+	}
+}
+
+// WithReauth sets a function that logs the client in again, for example
+// with AppRole or Kubernetes auth, when Vault rejects its token as expired
+// or revoked. The request is retried once after fn succeeds. Without it,
+// permission errors fail the call.
+func WithReauth(fn func(ctx context.Context, client *api.Client) error) Option {
+	return func(r *Resolver) {
+		r.reauth = fn
+	}
+}
+
+// Resolver resolves references to secrets of Vault. It implements
+// goenvsubst.Resolver and is safe for concurrent use.
+type Resolver struct {
+	client *api.Client
+	ctx    context.Context
+	ttl    time.Duration
+	reauth func(ctx context.Context, client *api.Client) error
+
+	mu    sync.Mutex
+	cache map[string]secret
+	// reauthMu keeps concurrent reads rejected together from logging the
+	// client in at the same time
+	reauthMu sync.Mutex
+}
+
+// secret is the data of a secret that was read
+type secret struct {
+	data    map[string]any
+	found   bool
+	expires time.Time
+}
+
+// New returns a Resolver that reads secrets with client, which holds the
+// address of Vault and the token.
+func New(client *api.Client, opts ...Option) *Resolver {
+	r := &Resolver{client: client, ctx: context.Background(), cache: map[string]secret{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Resolve returns the value of the key of the secret named by name, a path
+// followed by # and the key. A secret that does not exist, or that has no
+// such key, is unset. String values are returned as is, and other values as
+// JSON; a path without a key returns all the data as a JSON object.
+func (r *Resolver) Resolve(name string) (string, bool, error) {
+	path, key, hasKey := strings.Cut(name, "#")
+	s, err := r.secret(path)
+	if err != nil || !s.found {
+		return "", false, err
+	}
+
+	var value any = s.data
+	if hasKey {
+		var ok bool
+		if value, ok = s.data[key]; !ok {
+			return "", false, nil
+		}
+	}
+	if value, ok := value.(string); ok {
+		return value, true, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", false, fmt.Errorf("vault: encode %s: %w", name, err)
+	}
+	return string(encoded), true, nil
+}
+
+// secret returns the secret at path, from the cache while it is fresh
+func (r *Resolver) secret(path string) (secret, error) {
+	// The lock only guards the cache, so slow reads of different secrets
+	// do not wait for each other
+	r.mu.Lock()
+	s, ok := r.cache[path]
+	r.mu.Unlock()
+	if ok && (s.expires.IsZero() || time.Now().Before(s.expires)) {
+		return s, nil
+	}
+
+	read, err := r.client.Logical().ReadWithContext(r.ctx, path)
+	var respErr *api.ResponseError
+	if r.reauth != nil && errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden {
+		r.reauthMu.Lock()
+		authErr := r.reauth(r.ctx, r.client)
+		r.reauthMu.Unlock()
+		if authErr != nil {
+			return secret{}, fmt.Errorf("vault: log in again: %w", authErr)
+		}
+		read, err = r.client.Logical().ReadWithContext(r.ctx, path)
+	}
+	if err != nil {
+		return secret{}, fmt.Errorf("vault: read %s: %w", path, err)
+	}
+
+	s = secret{}
+	if read != nil && read.Data != nil {
+		s.data, s.found = read.Data, true
+		// Version 2 of the KV engine wraps the data with its metadata
+		if data, ok := read.Data["data"].(map[string]any); ok && read.Data["metadata"] != nil {
+			s.data = data
+		}
+	}
+	if ttl := r.expiry(read); ttl > 0 {
+		s.expires = time.Now().Add(ttl)
+	}
+	r.mu.Lock()
+	r.cache[path] = s
+	r.mu.Unlock()
+	return s, nil
+}
+
+// expiry returns how long the secret read may be cached, or 0 for ever
+func (r *Resolver) expiry(read *api.Secret) time.Duration {
+	ttl := r.ttl
+	if read != nil && read.LeaseDuration > 0 {
+		lease := time.Duration(read.LeaseDuration) * time.Second
+		if ttl <= 0 || lease < ttl {
+			ttl = lease
+		}
+	}
+	return ttl
+}
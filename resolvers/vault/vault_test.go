@@ -0,0 +1,88 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/resolvers/vault"
+)
+
+// newServer returns a client of a fake Vault serving the given secrets to
+// the token "valid", and a counter of the reads
+func newServer(t *testing.T, secrets map[string]any) (*api.Client, *int) {
+	t.Helper()
+	reads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reads++
+		if req.Header.Get("X-Vault-Token") != "valid" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		data, ok := secrets[req.URL.Path[len("/v1/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(server.Close)
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("valid")
+	return client, &reads
+}
+
+func TestResolver(t *testing.T) {
+	client, reads := newServer(t, map[string]any{
+		"kv/data/app": map[string]any{
+			"data":     map[string]any{"db_password": "hunter2", "port": 5432},
+			"metadata": map[string]any{"version": 3},
+		},
+		"secret/api": map[string]any{"token": "t0ken"},
+	})
+	config := &struct{ Password, Port, Token, Missing string }{
+		"${vault:kv/data/app#db_password}",
+		"${vault:kv/data/app#port}",
+		"${vault:secret/api#token}",
+		"${vault:kv/data/other#key:-none}${vault:secret/api#other}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithScheme("vault", vault.New(client))); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Password != "hunter2" || config.Port != "5432" || config.Token != "t0ken" || config.Missing != "none" {
+		t.Errorf("Do() = %+v", config)
+	}
+	if *reads != 3 {
+		t.Errorf("reads = %d, want one per secret", *reads)
+	}
+}
+
+func TestResolverReauth(t *testing.T) {
+	client, _ := newServer(t, map[string]any{"secret/api": map[string]any{"token": "t0ken"}})
+	client.SetToken("expired")
+
+	if _, _, err := vault.New(client).Resolve("secret/api#token"); err == nil {
+		t.Fatal("Resolve() with an expired token error = nil")
+	}
+
+	r := vault.New(client, vault.WithReauth(func(ctx context.Context, client *api.Client) error {
+		client.SetToken("valid")
+		return nil
+	}))
+	if value, ok, err := r.Resolve("secret/api#token"); value != "t0ken" || !ok || err != nil {
+		t.Errorf("Resolve() = %q, %v, %v", value, ok, err)
+	}
+}
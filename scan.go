@@ -0,0 +1,118 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// scanner walks a value without modifying it, the way Do walks it, for the
+// functions that inspect values after substitution. Types skipped by default
+// or with WithSkipTypes and fields tagged envsubst:"-" are passed over, and
+// every pointer, map and slice is walked once however often it is reachable,
+// so cycles end.
+type scanner struct {
+	w *walker
+	// leaf is called with every value that holds no others, such as a
+	// string or a number, together with its path, as in error messages, and
+	// the field names, keys and indexes leading to it
+	leaf func(v reflect.Value, path string, elems []string)
+}
+
+// value scans v, found at path
+func (s *scanner) value(v reflect.Value, path string, elems []string) error {
+	if !v.IsValid() || s.w.skipsType(v.Type()) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || !s.w.visit(v) {
+			return nil
+		}
+		return s.value(v.Elem(), path, elems)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return s.value(v.Elem(), path, elems)
+	case reflect.Struct:
+		if m, ok := concurrentMap(v); ok {
+			return s.concurrentMap(m, path, elems)
+		}
+		return s.fields(v, path, elems)
+	case reflect.Slice:
+		if v.Len() == 0 || !s.w.visit(v) {
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := s.value(v.Index(i), indexPath(path, i), appendPath(elems, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() || !s.w.visit(v) {
+			return nil
+		}
+		for _, key := range sortedMapKeys(v) {
+			if err := s.value(v.MapIndex(key), keyPath(path, key.Interface()), appendPath(elems, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+		}
+	default:
+		s.leaf(v, path, elems)
+	}
+	return nil
+}
+
+// fields scans the fields of the struct v
+func (s *scanner) fields(v reflect.Value, path string, elems []string) error {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := t.Field(i)
+		fpath := fieldPath(path, field.Name)
+		if !field.IsExported() {
+			if s.w.unexportedEmbeds && field.Anonymous {
+				if err := s.value(v.Field(i), fpath, appendPath(elems, field.Name)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		tag, err := parseTag(field, fpath, s.w.referenceSyntax())
+		if err != nil {
+			return err
+		}
+		if tag.skip {
+			continue
+		}
+		if err := s.value(v.Field(i), fpath, appendPath(elems, field.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// concurrentMap scans the values of m in the order of their keys' text
+func (s *scanner) concurrentMap(m ConcurrentMap, path string, elems []string) error {
+	type entry struct{ key, value any }
+	var entries []entry
+	m.Range(func(key, value any) bool {
+		entries = append(entries, entry{key, value})
+		return true
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return fmt.Sprint(entries[i].key) < fmt.Sprint(entries[j].key)
+	})
+
+	for _, e := range entries {
+		if err := s.value(reflect.ValueOf(e.value), keyPath(path, e.key), appendPath(elems, fmt.Sprint(e.key))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
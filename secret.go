@@ -0,0 +1,259 @@
+package goenvsubst
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithSecretVars marks variables whose values must never appear in the
+// messages of errors and issues, such as passwords and tokens. Where a
+// message would quote the value of one of them, for example because it
+// does not parse as the int its field holds, the value is replaced by the
+// variable's name and a fingerprint, as in <DB_PASSWORD sha256:9f86d081>,
+// which tells whether two failures saw the same value without revealing
+// it. Only the messages are redacted: the errors they wrap are unchanged.
+// Reports never hold values. Repeated options add to the list.
+func WithSecretVars(names ...string) Option {
+	return func(c *config) {
+		c.secrets = append(c.secrets, names...)
+	}
+}
+
+// redactedError is an error whose message has the values of secret
+// variables replaced
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redact returns err with the values of the variables of WithSecretVars
+// removed from its message. A *PathError stays one, so its Path is still at
+// hand without unwrapping.
+func (w *walker) redact(err error) error {
+	if err == nil || len(w.secrets) == 0 {
+		return err
+	}
+	if pathErr, ok := err.(*PathError); ok {
+		inner := w.redact(pathErr.Err)
+		if inner == pathErr.Err {
+			return err
+		}
+		return &PathError{Path: pathErr.Path, Err: inner}
+	}
+	var rerr *resolveError
+	if errors.As(err, &rerr) {
+		return err
+	}
+
+	msg := err.Error()
+	redacted := w.redactString(msg)
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{msg: redacted, err: err}
+}
+
+// redactString replaces the values of the variables of WithSecretVars in s
+// with their names and fingerprints
+func (w *walker) redactString(s string) string {
+	if len(w.secrets) == 0 {
+		return s
+	}
+	// A resolver failing now leaves out the variables it cannot resolve,
+	// rather than hiding the error being reported
+	secrets, _ := w.secretValues(func(name, value string) string {
+		return "<" + name + " sha256:" + fingerprint(value) + ">"
+	})
+	// Messages such as those of strconv quote the value
+	var pairs []string
+	for i := 0; i < len(secrets); i += 2 {
+		pairs = append(pairs, secrets[i], secrets[i+1])
+		if quoted := strconv.Quote(secrets[i]); quoted[1:len(quoted)-1] != secrets[i] {
+			pairs = append(pairs, quoted[1:len(quoted)-1], secrets[i+1])
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// secretValues returns the values of the variables of WithSecretVars that
+// are set and not empty, each followed by its replacement. Longer values
+// come first, so that a strings.Replacer does not leave a value containing
+// another partly visible. The values of other variables are returned along
+// with a resolver error.
+func (w *walker) secretValues(replacement func(name, value string) string) ([]string, error) {
+	type secret struct{ value, replacement string }
+	var secrets []secret
+	var resolveErr error
+	for _, name := range w.secrets {
+		value, ok, err := w.resolve(name)
+		if err != nil {
+			resolveErr = cmp.Or(resolveErr, err)
+			continue
+		}
+		if !ok || value == "" {
+			continue
+		}
+		secrets = append(secrets, secret{value, replacement(name, value)})
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i].value) > len(secrets[j].value)
+	})
+
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s.value, s.replacement)
+	}
+	return pairs, resolveErr
+}
+
+// fingerprint returns a short hash of value, enough to tell values apart
+func fingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Redact is the reverse of Do for the variables of WithSecretVars: it
+// replaces every string of v whose whole value equals one of their values
+// with a reference to the variable, as in $DB_PASSWORD, so that the
+// effective configuration can be written to logs or served on a debug
+// endpoint. Only values that are set and not empty are replaced, and v is
+// walked like Do walks it, except that struct tags do not apply. opts apply
+// as in Do; without WithSecretVars, v is left unchanged.
+//
+// Secrets embedded in longer strings, such as the password of a DSN, are
+// only replaced with WithRedactSubstrings.
+func Redact(v any, opts ...Option) error {
+	w := New(opts...).walker()
+	rv := reflect.ValueOf(v)
+	if err := checkSettable(rv); err != nil {
+		return err
+	}
+	secrets, err := w.secretValues(func(name, _ string) string {
+		return "$" + name
+	})
+	if err != nil || len(secrets) == 0 {
+		return err
+	}
+
+	var replace func(string) string
+	if w.redactSubstrings {
+		replace = strings.NewReplacer(secrets...).Replace
+	} else {
+		references := make(map[string]string, len(secrets)/2)
+		for i := len(secrets) - 2; i >= 0; i -= 2 {
+			// The first variable with a value wins
+			references[secrets[i]] = secrets[i+1]
+		}
+		replace = func(s string) string {
+			if reference, ok := references[s]; ok {
+				return reference
+			}
+			return s
+		}
+	}
+	w.redactValue(rv, replace)
+	return nil
+}
+
+// WithRedactSubstrings makes Redact also replace the values of secret
+// variables where they occur within longer strings, such as the password
+// in postgres://admin:hunter2@db, longer values first. It suits secrets
+// long enough not to occur by chance: a short value such as 1 or true
+// would be replaced in unrelated strings too.
+func WithRedactSubstrings() Option {
+	return func(c *config) {
+		c.redactSubstrings = true
+	}
+}
+
+// redactValue replaces the strings of v with what replace returns for them
+func (w *walker) redactValue(v reflect.Value, replace func(string) string) {
+	if w.skipsType(v.Type()) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && w.visit(v) {
+			w.redactValue(v.Elem(), replace)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := v.Elem()
+		switch elem.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice:
+			w.redactValue(elem, replace)
+			return
+		}
+		if v.CanSet() {
+			copied := reflect.New(elem.Type()).Elem()
+			copied.Set(elem)
+			w.redactValue(copied, replace)
+			v.Set(copied)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(replace(v.String()))
+		}
+	case reflect.Struct:
+		if m, ok := concurrentMap(v); ok {
+			// Store after Range, which may hold a lock
+			values := map[any]any{}
+			m.Range(func(key, value any) bool {
+				values[key] = value
+				return true
+			})
+			for key, value := range values {
+				holder := reflect.New(reflect.TypeFor[any]()).Elem()
+				if value != nil {
+					holder.Set(reflect.ValueOf(value))
+				}
+				w.redactValue(holder, replace)
+				if !reflect.DeepEqual(holder.Interface(), value) {
+					m.Store(key, holder.Interface())
+				}
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				w.redactValue(v.Field(i), replace)
+			}
+		}
+	case reflect.Slice:
+		if v.Len() == 0 || !w.visit(v) {
+			return
+		}
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			w.redactValue(v.Index(i), replace)
+		}
+	case reflect.Map:
+		if v.IsNil() || !w.visit(v) {
+			return
+		}
+		for _, key := range v.MapKeys() {
+			copied := reflect.New(v.Type().Elem()).Elem()
+			copied.Set(v.MapIndex(key))
+			w.redactValue(copied, replace)
+			v.SetMapIndex(key, copied)
+		}
+	}
+}
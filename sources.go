@@ -0,0 +1,119 @@
+package goenvsubst
+
+import "fmt"
+
+// The names of the built-in sources
+const (
+	envSource    = "env"
+	dotenvSource = "dotenv"
+)
+
+// Source is a named source of variables for WithSources, such as the
+// environment, .env files or a secret store.
+type Source struct {
+	// Name identifies the source in a Report, such as "env" or "vault".
+	Name string
+	// Resolver looks the variables of the source up.
+	Resolver Resolver
+}
+
+// Env is the process environment as a Source named "env".
+func Env() Source {
+	return Source{Name: envSource, Resolver: EnvResolver{}}
+}
+
+// Dotenv is the variables of the given .env files, by default .env in the
+// working directory, as a Source named "dotenv". Later files override
+// earlier ones, and the files are read as for WithDotenv.
+func Dotenv(paths ...string) Source {
+	if len(paths) == 0 {
+		paths = []string{".env"}
+	}
+	return Source{Name: dotenvSource, Resolver: &dotenvResolver{base: MapResolver{}, paths: paths}}
+}
+
+// WithSources resolves variables from sources in order of precedence: each
+// variable is taken from the first source that has it, so an override
+// order such as
+//
+//	goenvsubst.WithSources(
+//		goenvsubst.Env(),
+//		goenvsubst.Dotenv(".env.local", ".env"),
+//		goenvsubst.Source{Name: "defaults", Resolver: goenvsubst.MapResolver(defaults)},
+//	)
+//
+// lets the environment override the files and the files the defaults. An
+// error from a source stops the lookup. The Report of DoWithReport records
+// which source supplied each variable in Sources. Like WithResolver, it
+// replaces the resolver configured so far, so give WithPrefix, WithScheme
+// and the like after it.
+//
+// A field tagged envsource:"vault" is resolved from the source named vault
+// alone, whatever the order, so that credentials can never come from plain
+// environment variables; a name that is not among the sources makes the
+// call fail. Options such as WithPrefix and WithScheme given after
+// WithSources apply to that source too.
+func WithSources(sources ...Source) Option {
+	return func(c *config) {
+		c.resolver = sourcesResolver(sources)
+		c.sources = sources
+		c.wrappers = nil
+	}
+}
+
+// sourceNamed returns the resolver of the source of WithSources named name,
+// with the options such as WithPrefix given after WithSources
+func (c *config) sourceNamed(name string) (Resolver, error) {
+	for _, source := range c.sources {
+		if source.Name == name {
+			var r Resolver = sourcesResolver{source}
+			for _, wrap := range c.wrappers {
+				r = wrap(r)
+			}
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown source %q", name)
+}
+
+// sourcesResolver resolves variables from the first of its sources that has
+// them
+type sourcesResolver []Source
+
+func (s sourcesResolver) Resolve(name string) (string, bool, error) {
+	return dropSource(s.resolveSource(name))
+}
+
+func (s sourcesResolver) resolveSource(name string) (string, bool, string, error) {
+	for _, source := range s {
+		value, ok, err := source.Resolver.Resolve(name)
+		if err != nil {
+			return "", false, "", fmt.Errorf("%s: %w", source.Name, err)
+		}
+		if ok {
+			return value, true, source.Name, nil
+		}
+	}
+	return "", false, "", nil
+}
+
+// sourcedResolver is implemented by the resolvers that can tell which
+// source supplied a variable
+type sourcedResolver interface {
+	resolveSource(name string) (value string, ok bool, source string, err error)
+}
+
+// resolveFrom resolves name with r, along with the name of the source that
+// supplied it if r knows it
+func resolveFrom(r Resolver, name string) (string, bool, string, error) {
+	if r, ok := r.(sourcedResolver); ok {
+		return r.resolveSource(name)
+	}
+	value, ok, err := r.Resolve(name)
+	return value, ok, "", err
+}
+
+// dropSource leaves the source out of the results of resolveSource
+func dropSource(value string, ok bool, _ string, err error) (string, bool, error) {
+	return value, ok, err
+}
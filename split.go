@@ -0,0 +1,127 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// defaultSeparator separates the elements of a split value unless the tag
+// names another separator
+const defaultSeparator = ","
+
+// doSplit fills a slice field with the elements of a list held in one
+// value, such as ALLOWED_ORIGINS=https://a.example,https://b.example. The
+// list is the expansion of the field's env tag or template, or else of each
+// of its own elements, so []string{"$ALLOWED_ORIGINS"} grows to as many
+// elements as the variable lists. Elements are converted to the element
+// type like tag sources are. An empty source leaves the field unchanged.
+func (w *walker) doSplit(v reflect.Value, tag fieldTag, path string) error {
+	if v.Kind() == reflect.Map {
+		return w.doSplitMap(v, tag, path)
+	}
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("goenvsubst: field %s: split option requires a slice or map, got %s", path, v.Type())
+	}
+
+	var parts []string
+	if tag.source != "" || tag.env != "" {
+		expanded, err := w.expandSource(tag, path)
+		if err != nil || expanded == "" {
+			return err
+		}
+		parts = splitList(expanded, tag.separator)
+	} else {
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("goenvsubst: field %s: split option without a source requires a slice of strings, got %s", path, v.Type())
+		}
+		for i := 0; i < v.Len(); i++ {
+			expanded, err := w.expand(v.Index(i).String(), indexPath(path, i))
+			if err != nil {
+				return err
+			}
+			parts = append(parts, splitList(expanded, tag.separator)...)
+		}
+	}
+
+	elems := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		if err := setString(elems.Index(i), part, indexPath(path, i)); err != nil {
+			return err
+		}
+	}
+	if !w.checking() {
+		v.Set(elems)
+	}
+	return nil
+}
+
+// doSplitMap fills a map field with the key=value pairs of a list held in
+// the expansion of its env tag or template, such as
+// LABELS=team=payments,tier=1. Keys and values are converted to the key and
+// element types, later pairs win over earlier ones with the same key, and
+// an empty source leaves the field unchanged.
+func (w *walker) doSplitMap(v reflect.Value, tag fieldTag, path string) error {
+	if tag.source == "" && tag.env == "" {
+		return fmt.Errorf("goenvsubst: field %s: split option on a map requires an env tag or a template", path)
+	}
+	expanded, err := w.expandSource(tag, path)
+	if err != nil || expanded == "" {
+		return err
+	}
+
+	pairs := splitList(expanded, tag.separator)
+	m := reflect.MakeMapWithSize(v.Type(), len(pairs))
+	for i, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("goenvsubst: field %s: element %d of the split value is not a key=value pair", path, i)
+		}
+		k := reflect.New(v.Type().Key()).Elem()
+		if err := setString(k, strings.TrimSpace(key), path); err != nil {
+			return err
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := setString(elem, strings.TrimSpace(value), keyPath(path, k.Interface())); err != nil {
+			return err
+		}
+		m.SetMapIndex(k, elem)
+	}
+	if !w.checking() {
+		v.Set(m)
+	}
+	return nil
+}
+
+// splitList splits s at every sep outside quotes. Elements are trimmed of
+// surrounding whitespace, and an element enclosed in double or single quotes
+// is taken as written between them, separators and spaces included. Empty
+// elements that are not quoted, as left by a trailing separator, are
+// dropped.
+func splitList(s, sep string) []string {
+	var parts []string
+	for s != "" {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			break
+		}
+
+		if quote := s[0]; quote == '"' || quote == '\'' {
+			if end := strings.IndexByte(s[1:], quote); end >= 0 {
+				rest := strings.TrimLeft(s[end+2:], " \t\r\n")
+				if rest == "" || strings.HasPrefix(rest, sep) {
+					parts = append(parts, s[1:end+1])
+					s = strings.TrimPrefix(rest, sep)
+					continue
+				}
+			}
+		}
+
+		part, rest, _ := strings.Cut(s, sep)
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+		s = rest
+	}
+	return parts
+}
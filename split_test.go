@@ -0,0 +1,122 @@
+package goenvsubst_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoSplitTag(t *testing.T) {
+	os.Setenv("ALLOWED_ORIGINS", " https://a.example , https://b.example,")
+	os.Setenv("QUOTED", `"a, b", 'c' ,d`)
+	os.Setenv("PORTS", "80;443")
+	os.Setenv("TIMEOUTS", "1s,1m")
+	defer func() {
+		for _, name := range []string{"ALLOWED_ORIGINS", "QUOTED", "PORTS", "TIMEOUTS"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	config := &struct {
+		Origins  []string        `env:"ALLOWED_ORIGINS" envsubst:"split"`
+		Quoted   []string        `env:"QUOTED" envsubst:"split"`
+		Ports    []int           `envsubst:"${PORTS},split=;"`
+		Timeouts []time.Duration `env:"TIMEOUTS" envsubst:"split"`
+		Content  []string        `envsubst:"split"`
+		Missing  []string        `env:"MISSING_VAR" envsubst:"split"`
+		Plain    []string
+	}{
+		Content: []string{"first", "$ALLOWED_ORIGINS"},
+		Missing: []string{"kept"},
+		Plain:   []string{"$PORTS"},
+	}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"Origins", config.Origins, []string{"https://a.example", "https://b.example"}},
+		{"Quoted", config.Quoted, []string{"a, b", "c", "d"}},
+		{"Ports", config.Ports, []int{80, 443}},
+		{"Timeouts", config.Timeouts, []time.Duration{time.Second, time.Minute}},
+		{"Content", config.Content, []string{"first", "https://a.example", "https://b.example"}},
+		{"Missing", config.Missing, []string{"kept"}},
+		{"Plain", config.Plain, []string{"80;443"}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestDoSplitTagErrors(t *testing.T) {
+	os.Setenv("PORTS", "80,http")
+	defer os.Unsetenv("PORTS")
+
+	invalid := &struct {
+		Ports []int `env:"PORTS" envsubst:"split"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "Ports[1]") {
+		t.Errorf("Do() error = %v, want a parse error naming the element", err)
+	}
+
+	notSlice := &struct {
+		Port int `env:"PORTS" envsubst:"split"`
+	}{}
+	if err := goenvsubst.Do(notSlice); err == nil {
+		t.Error("Do() expected error for a field that is not a slice")
+	}
+}
+
+func TestDoSplitTagMap(t *testing.T) {
+	os.Setenv("LABELS", "team=payments, tier = 1,'note=a,b'")
+	os.Setenv("WEIGHTS", "a=1;b=2")
+	defer func() {
+		os.Unsetenv("LABELS")
+		os.Unsetenv("WEIGHTS")
+	}()
+
+	config := &struct {
+		Labels  map[string]string `env:"LABELS" envsubst:"split"`
+		Weights map[string]int    `envsubst:"${WEIGHTS},split=;"`
+		Missing map[string]string `env:"MISSING_VAR" envsubst:"split"`
+	}{Missing: map[string]string{"kept": "yes"}}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if want := map[string]string{"team": "payments", "tier": "1", "note": "a,b"}; !reflect.DeepEqual(config.Labels, want) {
+		t.Errorf("Labels = %v, want %v", config.Labels, want)
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(config.Weights, want) {
+		t.Errorf("Weights = %v, want %v", config.Weights, want)
+	}
+	if want := map[string]string{"kept": "yes"}; !reflect.DeepEqual(config.Missing, want) {
+		t.Errorf("Missing = %v, want %v", config.Missing, want)
+	}
+
+	os.Setenv("LABELS", "team=payments,secret-token")
+	invalid := &struct {
+		Labels map[string]string `env:"LABELS" envsubst:"split"`
+	}{}
+	err := goenvsubst.Do(invalid)
+	if err == nil || !strings.Contains(err.Error(), "field Labels") || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Do() error = %v, want an error naming the field but not the value", err)
+	}
+
+	noSource := &struct {
+		Labels map[string]string `envsubst:"split"`
+	}{}
+	if err := goenvsubst.Do(noSource); err == nil {
+		t.Error("Do() expected error for a split map without a source")
+	}
+}
@@ -0,0 +1,199 @@
+package goenvsubst
+
+import (
+	"errors"
+	"io"
+)
+
+// streamChunk is how much ExpandReader reads from its source at a time
+const streamChunk = 32 * 1024
+
+// maxStreamReference is the longest reference ExpandReader holds back
+// while it waits for its end
+const maxStreamReference = 64 * 1024
+
+// ExpandReader substitutes the references in the text read from src, like
+// Expand, and writes the result to dst without holding the whole text in
+// memory, for inputs such as large generated manifests. References split
+// across reads are handled; only the text of a single reference, of at most
+// 64 KiB, is held back until it is complete. A $ that would start a longer
+// reference is read as it is, which ShellSyntax reports as a syntax error.
+// With a syntax other than ShellSyntax, LenientShellSyntax and those of
+// ShellFormatSyntax, the whole text is read first, since only the shell
+// syntaxes tell where references may end.
+//
+// Output is written as it is produced, so on error, including the
+// *UnsetError of Strict, which can only be reported at the end, dst holds a
+// partial result that should be discarded.
+func ExpandReader(dst io.Writer, src io.Reader, opts ...Option) error {
+	return New(opts...).ExpandReader(dst, src)
+}
+
+// ExpandReader is like the package-level ExpandReader with the Expander's
+// options.
+func (e *Expander) ExpandReader(dst io.Writer, src io.Reader) error {
+	w := e.walker()
+	scanner := &streamScanner{escapes: true, start: -1}
+	if w.syntax != nil {
+		shell, ok := w.syntax.(shellSyntax)
+		if !ok {
+			return w.expandAll(dst, src)
+		}
+		scanner.escapes = shell.escapes
+	}
+
+	var pending []byte
+	// consumed is where pending starts in the text
+	consumed := Position{Offset: 0, Line: 1, Column: 1}
+	buf := make([]byte, streamChunk)
+	for {
+		n, readErr := src.Read(buf)
+		pending = append(pending, buf[:n]...)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+
+		// At the end of the text everything is complete
+		complete := len(pending)
+		if readErr == nil {
+			complete = scanner.complete(pending)
+		}
+		if complete > 0 {
+			chunk := string(pending[:complete])
+			expanded, err := w.expandTemplate(chunk, "")
+			if err != nil {
+				return positionedAt(err, consumed)
+			}
+			if _, err := io.WriteString(dst, expanded); err != nil {
+				return err
+			}
+			consumed = consumed.Advance(chunk)
+			pending = append(pending[:0], pending[complete:]...)
+			scanner.shift(complete)
+		}
+
+		if readErr != nil {
+			return w.finish()
+		}
+	}
+}
+
+// expandAll substitutes the whole text read from src at once
+func (w *walker) expandAll(dst io.Writer, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	expanded, err := w.expandTemplate(string(data), "")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(dst, expanded); err != nil {
+		return err
+	}
+	return w.finish()
+}
+
+// streamScanner tells where the references of a stream in a shell syntax
+// end. It keeps its place between reads, so every byte is scanned once, and
+// a $ is scanned again only when its reference grows too long.
+type streamScanner struct {
+	// escapes tells whether $$ is an escaped $
+	escapes bool
+	// next is the offset in the pending text to scan from
+	next int
+	// start is the offset of the reference being scanned, or -1
+	start int
+	// depth counts the braces the reference being scanned has open, and is
+	// 0 for a bare $NAME
+	depth int
+}
+
+// complete scans the text read since the last call and returns the length
+// of the longest prefix of pending that ends outside any reference, so that
+// more text cannot change how it reads
+func (s *streamScanner) complete(pending []byte) int {
+	i := s.next
+	for i < len(pending) {
+		if s.start >= 0 && i-s.start > maxStreamReference {
+			i, s.start, s.depth = s.start+1, -1, 0
+			continue
+		}
+
+		c := pending[i]
+		switch {
+		case s.start >= 0 && s.depth == 0:
+			if isNameChar(c, false) {
+				i++
+			} else {
+				s.start = -1
+			}
+		case s.start >= 0:
+			// Braces are matched the way Parse matches them
+			switch {
+			case c == '$' && i+1 == len(pending):
+				s.next = i
+				return s.start
+			case c == '$' && pending[i+1] == '$':
+				i += 2
+			case c == '$' && pending[i+1] == '{':
+				s.depth++
+				i += 2
+			case c == '}':
+				if s.depth--; s.depth == 0 {
+					s.start = -1
+				}
+				i++
+			default:
+				i++
+			}
+		case c != '$':
+			i++
+		case i+1 == len(pending):
+			s.next = i
+			return i
+		case s.escapes && pending[i+1] == '$':
+			i += 2
+		case pending[i+1] == '{':
+			s.start, s.depth = i, 1
+			i += 2
+		case isNameChar(pending[i+1], true):
+			s.start = i
+			i += 2
+		default:
+			i++
+		}
+	}
+
+	s.next = i
+	if s.start >= 0 {
+		return s.start
+	}
+	return len(pending)
+}
+
+// shift accounts for the first n bytes of the pending text having been
+// consumed
+func (s *streamScanner) shift(n int) {
+	s.next -= n
+	if s.start >= 0 {
+		s.start -= n
+	}
+}
+
+// positionedAt makes the position of a *SyntaxError in a chunk starting at
+// start relative to the whole text. Errors in the values of variables, with
+// WithRecursion, keep their positions.
+func positionedAt(err error, start Position) error {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+	pos := syntaxErr.Pos
+	if pos.Line == 1 {
+		pos.Column += start.Column - 1
+	}
+	pos.Line += start.Line - 1
+	pos.Offset += start.Offset
+	return &SyntaxError{Pos: pos, Msg: syntaxErr.Msg}
+}
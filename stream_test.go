@@ -0,0 +1,137 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestExpandReader(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"no references", "plain text\n", "plain text\n"},
+		{"simple", "a: $TEST_VAR\nb: ${TEST_VAR}\n", "a: test_value\nb: test_value\n"},
+		{"reference at the end", "value=$TEST_VAR", "value=test_value"},
+		{"escape", "cost: $$5 $$TEST_VAR", "cost: $5 $TEST_VAR"},
+		{"lone dollar", "50$ and $", "50$ and $"},
+		{"operators", "${MISSING_VAR:-${TEST_VAR}-default}", "test_value-default"},
+		{"long text", strings.Repeat("x${TEST_VAR}y\n", 10000), strings.Repeat("xtest_valuey\n", 10000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var whole, bytewise strings.Builder
+			if err := goenvsubst.ExpandReader(&whole, strings.NewReader(tt.input)); err != nil {
+				t.Fatalf("ExpandReader() error = %v", err)
+			}
+			// Reading a byte at a time splits every reference
+			if err := goenvsubst.ExpandReader(&bytewise, iotest.OneByteReader(strings.NewReader(tt.input))); err != nil {
+				t.Fatalf("ExpandReader() error = %v", err)
+			}
+			if whole.String() != tt.expected || bytewise.String() != tt.expected {
+				t.Errorf("ExpandReader() = %q and %q one byte at a time, want %q", whole.String(), bytewise.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandReaderErrors(t *testing.T) {
+	var out strings.Builder
+	err := goenvsubst.ExpandReader(&out, iotest.OneByteReader(strings.NewReader("line one\nline ${TWO")))
+	var syntaxErr *goenvsubst.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("ExpandReader() error = %v, want *SyntaxError", err)
+	}
+	if syntaxErr.Pos.Line != 2 || syntaxErr.Pos.Column != 6 || syntaxErr.Pos.Offset != 14 {
+		t.Errorf("Pos = %+v, want line 2, column 6, offset 14", syntaxErr.Pos)
+	}
+
+	err = goenvsubst.ExpandReader(&out, strings.NewReader("$MISSING_A\n$MISSING_B"), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("ExpandReader() error = %v, want *UnsetError", err)
+	}
+	if len(unsetErr.Names) != 2 {
+		t.Errorf("Names = %v, want both variables", unsetErr.Names)
+	}
+
+	if err := goenvsubst.ExpandReader(&out, iotest.ErrReader(errors.New("boom"))); err == nil || err.Error() != "boom" {
+		t.Errorf("ExpandReader() error = %v, want the read error", err)
+	}
+}
+
+func TestExpandReaderWithSyntax(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	var out strings.Builder
+	err := goenvsubst.ExpandReader(&out, iotest.OneByteReader(strings.NewReader("%TEST_VAR% $TEST_VAR")), goenvsubst.WithSyntax(goenvsubst.WindowsSyntax))
+	if err != nil {
+		t.Fatalf("ExpandReader() error = %v", err)
+	}
+	if want := "test_value $TEST_VAR"; out.String() != want {
+		t.Errorf("ExpandReader() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestExpandReaderShellSyntaxes(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	tests := []struct {
+		name     string
+		syntax   goenvsubst.Syntax
+		input    string
+		expected string
+	}{
+		{"lenient", goenvsubst.LenientShellSyntax, "${arr[@]} ${TEST_VAR} $$5 ${", "${arr[@]} test_value $5 ${"},
+		{"shell format", goenvsubst.ShellFormatSyntax("TEST_VAR"), "$$TEST_VAR $HOST", "$test_value $HOST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := goenvsubst.ExpandReader(&out, iotest.OneByteReader(strings.NewReader(tt.input)), goenvsubst.WithSyntax(tt.syntax)); err != nil {
+				t.Fatalf("ExpandReader() error = %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("ExpandReader() = %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+
+	// The text is written as it is read rather than read whole first
+	var out strings.Builder
+	src := io.MultiReader(strings.NewReader("$TEST_VAR\n"), iotest.ErrReader(errors.New("boom")))
+	if err := goenvsubst.ExpandReader(&out, src, goenvsubst.WithSyntax(goenvsubst.LenientShellSyntax)); err == nil || out.String() != "test_value\n" {
+		t.Errorf("ExpandReader() = %q, %v, want the first line and the read error", out.String(), err)
+	}
+}
+
+func TestExpandReaderLongReference(t *testing.T) {
+	input := "a ${" + strings.Repeat("x", 100*1024) + "}"
+
+	var out strings.Builder
+	err := goenvsubst.ExpandReader(&out, strings.NewReader(input))
+	var syntaxErr *goenvsubst.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Pos.Offset != 2 {
+		t.Fatalf("ExpandReader() error = %v, want a *SyntaxError at offset 2", err)
+	}
+
+	out.Reset()
+	if err := goenvsubst.ExpandReader(&out, strings.NewReader(input), goenvsubst.WithSyntax(goenvsubst.LenientShellSyntax)); err != nil || out.String() != input {
+		t.Errorf("ExpandReader() = %d bytes, %v, want the input unchanged", out.Len(), err)
+	}
+}
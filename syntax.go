@@ -0,0 +1,308 @@
+package goenvsubst
+
+import "strings"
+
+// Syntax is a way of writing references in templates. Select one with
+// WithSyntax; without it, ShellSyntax is used. Implement it for formats of
+// your own, such as @{NAME}@ or __NAME__, to reuse the traversal, resolvers
+// and options with them; SegmentSyntax implements it from a function that
+// splits templates into segments.
+type Syntax interface {
+	// Find returns the placeholders in s, in order, or an error if s is
+	// malformed. Placeholders need a Name and should record their Start and
+	// End.
+	Find(s string) ([]Segment, error)
+	// Replace returns s with every placeholder replaced by the result of
+	// expand, which is given the placeholder and the text it is written as
+	// in s, and returns the first error of expand. expand resolves the
+	// variable named by the placeholder, applies its Op and Arg as
+	// described at Segment, and returns text as is for an unset variable
+	// with WithKeepUnset.
+	Replace(s string, expand func(placeholder Segment, text string) (string, error)) (string, error)
+}
+
+var (
+	// ShellSyntax is the default syntax described at Parse: $NAME, ${NAME}
+	// and the operators of braced references, with $$ as an escaped $.
+	ShellSyntax Syntax = shellSyntax{SegmentSyntax(Parse), true}
+
+	// LenientShellSyntax is ShellSyntax that never fails: a $ that does not
+	// start a well-formed reference, such as the one of ${arr[@]} or of an
+	// unterminated ${, is literal text, the way GNU envsubst copies what it
+	// does not recognize. It suits templates that embed shell scripts.
+	LenientShellSyntax Syntax = shellSyntax{SegmentSyntax(shellParser{lenient: true}.parse), true}
+
+	// K8sSyntax is the syntax of Kubernetes container env, command and
+	// args: $(NAME) references a variable, $$ is an escaped $, so $$(NAME)
+	// is the text $(NAME), and anything else, including a $( that does not
+	// enclose a valid name, is literal text. Kubernetes leaves references
+	// to unset variables as written; combine it with WithKeepUnset to do
+	// the same.
+	K8sSyntax Syntax = SegmentSyntax(parseK8s)
+
+	// WindowsSyntax is the syntax of cmd.exe and ExpandEnvironmentStrings:
+	// %NAME% references a variable and %% is an escaped %. NAME may contain
+	// any character but whitespace, % and =, as in %ProgramFiles(x86)%, so
+	// text such as "50% off, 20% more" is left alone. Windows matches names
+	// case-insensitively; combine it with WithCaseInsensitive to do the
+	// same.
+	WindowsSyntax Syntax = SegmentSyntax(parseWindows)
+
+	// ActionsSyntax is the syntax of GitHub Actions workflow expressions:
+	// ${{ env.NAME }} references a variable, with or without the spaces,
+	// and ${{ env.NAME || 'default' }} has a default, the ":-" operator of
+	// ShellSyntax, used when NAME is unset or empty. In the quoted default
+	// '' is an escaped '. Other expressions, such as ${{ secrets.TOKEN }}
+	// or ${{ github.sha }}, and every other $ are literal text, so workflow
+	// files can be rendered locally and still be read by GitHub.
+	ActionsSyntax Syntax = SegmentSyntax(parseActions)
+)
+
+// ShellFormatSyntax returns the syntax of GNU envsubst given a SHELL-FORMAT
+// argument such as '$HOST ${PORT}': only references to the variables names,
+// written as $NAME or as ${NAME} with the operators and modifiers of
+// ShellSyntax, are recognized. Everything else, including $$ and references
+// to other variables or that do not parse, is literal text, so templates
+// with $ syntax of their own, such as nginx configs with $host or shell
+// scripts, pass through untouched. The text of a reference that is not
+// recognized is still searched, so ${OTHER:-$HOST} holds a reference to
+// HOST.
+func ShellFormatSyntax(names ...string) Syntax {
+	// A nil list would recognize every variable
+	return shellSyntax{SegmentSyntax(shellParser{names: append([]string{}, names...)}.parse), false}
+}
+
+// shellSyntax is a syntax of the shell grammar, whose references ExpandReader
+// can tell the ends of in a stream
+type shellSyntax struct {
+	SegmentSyntax
+	// escapes tells whether $$ is an escaped $
+	escapes bool
+}
+
+// WithSyntax makes references be written in syntax instead of ShellSyntax.
+// It applies to every entry point, including the templates in struct tags.
+func WithSyntax(syntax Syntax) Option {
+	return func(c *config) {
+		c.syntax = syntax
+	}
+}
+
+// SegmentSyntax adapts a function that splits templates into literal and
+// placeholder segments, like Parse does, to the Syntax interface. Literal
+// segments are copied to the output, and the text of a placeholder is the
+// part of the template between its Start and End offsets.
+type SegmentSyntax func(s string) ([]Segment, error)
+
+// Find returns the placeholder segments of s.
+func (parse SegmentSyntax) Find(s string) ([]Segment, error) {
+	segments, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+	var placeholders []Segment
+	for _, segment := range segments {
+		if segment.Kind == PlaceholderSegment {
+			placeholders = append(placeholders, segment)
+		}
+	}
+	return placeholders, nil
+}
+
+// Replace concatenates the segments of s, with every placeholder replaced by
+// the result of expand.
+func (parse SegmentSyntax) Replace(s string, expand func(Segment, string) (string, error)) (string, error) {
+	segments, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, segment := range segments {
+		if segment.Kind == LiteralSegment {
+			b.WriteString(segment.Literal)
+			continue
+		}
+		value, err := expand(segment, s[segment.Start.Offset:segment.End.Offset])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+// parseK8s splits s into segments in K8sSyntax
+func parseK8s(s string) ([]Segment, error) {
+	return parseDelimited(s, '$', k8sForm, func(s string, i int) (string, int) {
+		if i+1 >= len(s) || s[i+1] != '(' {
+			return "", i
+		}
+		for j := i + 2; j < len(s); j++ {
+			if s[j] == ')' {
+				if name := s[i+2 : j]; isName(name) {
+					return name, j + 1
+				}
+				break
+			}
+		}
+		return "", i
+	})
+}
+
+// parseWindows splits s into segments in WindowsSyntax
+func parseWindows(s string) ([]Segment, error) {
+	return parseDelimited(s, '%', windowsForm, func(s string, i int) (string, int) {
+		for j := i + 1; j < len(s); j++ {
+			switch s[j] {
+			case '%':
+				if j == i+1 {
+					return "", i
+				}
+				return s[i+1 : j], j + 1
+			case ' ', '\t', '\n', '\r', '=':
+				return "", i
+			}
+		}
+		return "", i
+	})
+}
+
+// parseActions splits s into segments in ActionsSyntax
+func parseActions(s string) ([]Segment, error) {
+	var segments []Segment
+	positions := newPositioner(s)
+	literalStart := 0
+	for i := 0; i < len(s); {
+		open := strings.Index(s[i:], "${{")
+		if open < 0 {
+			break
+		}
+		start := i + open
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			break
+		}
+		end += start + len("}}")
+		name, op, arg, ok := parseActionsExpression(s[start+len("${{") : end-len("}}")])
+		if !ok {
+			i = start + 1
+			continue
+		}
+
+		if start > literalStart {
+			segments = append(segments, Segment{
+				Kind:    LiteralSegment,
+				Literal: s[literalStart:start],
+				Start:   positions.at(literalStart),
+				End:     positions.at(start),
+			})
+		}
+		segments = append(segments, Segment{
+			Kind:  PlaceholderSegment,
+			Name:  name,
+			Op:    op,
+			Arg:   arg,
+			Start: positions.at(start),
+			End:   positions.at(end),
+			form:  actionsForm,
+		})
+		i, literalStart = end, end
+	}
+	if literalStart < len(s) {
+		segments = append(segments, Segment{
+			Kind:    LiteralSegment,
+			Literal: s[literalStart:],
+			Start:   positions.at(literalStart),
+			End:     positions.at(len(s)),
+		})
+	}
+	return segments, nil
+}
+
+// parseActionsExpression parses the expression between ${{ and }} and
+// reports whether it is env.NAME, optionally followed by || and a quoted
+// default
+func parseActionsExpression(expr string) (name, op, arg string, ok bool) {
+	expr = strings.TrimSpace(expr)
+	rest, ok := strings.CutPrefix(expr, "env.")
+	if !ok {
+		return "", "", "", false
+	}
+	name, fallback, hasDefault := strings.Cut(rest, "||")
+	if name = strings.TrimSpace(name); !isName(name) {
+		return "", "", "", false
+	}
+	if !hasDefault {
+		return name, "", "", true
+	}
+
+	fallback = strings.TrimSpace(fallback)
+	if len(fallback) < 2 || fallback[0] != '\'' || fallback[len(fallback)-1] != '\'' {
+		return "", "", "", false
+	}
+	quoted := fallback[1 : len(fallback)-1]
+	if strings.Contains(strings.ReplaceAll(quoted, "''", ""), "'") {
+		return "", "", "", false
+	}
+	return name, ":-", strings.ReplaceAll(quoted, "''", "'"), true
+}
+
+// parseDelimited splits s into segments for syntaxes whose references start
+// with delim and that escape delim by doubling it. match is called at every
+// other delim, at s[i], and returns the name of the reference starting there
+// with the offset just past it, or an end equal to i if there is none. Its
+// placeholders are rendered in form.
+func parseDelimited(s string, delim byte, form segmentForm, match func(s string, i int) (name string, end int)) ([]Segment, error) {
+	var segments []Segment
+	positions := newPositioner(s)
+	literalStart := 0
+	flushLiteral := func(end int) {
+		if end > literalStart {
+			segments = append(segments, Segment{
+				Kind:    LiteralSegment,
+				Literal: s[literalStart:end],
+				Start:   positions.at(literalStart),
+				End:     positions.at(end),
+			})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != delim {
+			i++
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == delim {
+			flushLiteral(i)
+			segments = append(segments, Segment{
+				Kind:    LiteralSegment,
+				Literal: string(delim),
+				Escaped: true,
+				Start:   positions.at(i),
+				End:     positions.at(i + 2),
+			})
+			i += 2
+			literalStart = i
+			continue
+		}
+
+		name, end := match(s, i)
+		if end == i {
+			i++
+			continue
+		}
+		flushLiteral(i)
+		segments = append(segments, Segment{
+			Kind:  PlaceholderSegment,
+			Name:  name,
+			Start: positions.at(i),
+			End:   positions.at(end),
+			form:  form,
+		})
+		i, literalStart = end, end
+	}
+	flushLiteral(len(s))
+	return segments, nil
+}
@@ -0,0 +1,275 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestExpandWithLenientShellSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"HOME": "/home/app", "USER": "app"}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for f in ${files[@]}; do cp $f $HOME; done", "for f in ${files[@]}; do cp  /home/app; done"},
+		{"${USER:-nobody} ${1ABC} ${}", "app ${1ABC} ${}"},
+		{"${UNTERMINATED $USER", "${UNTERMINATED app"},
+		{"${A:-${}} $$HOME", "${A:-${}} $HOME"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := goenvsubst.ExpandWith(tt.input, vars, goenvsubst.WithSyntax(goenvsubst.LenientShellSyntax))
+			if err != nil {
+				t.Fatalf("ExpandWith() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("ExpandWith() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandWithShellFormatSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"HOST": "db.internal", "PORT": "5432"}
+	syntax := goenvsubst.ShellFormatSyntax("HOST", "PORT")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"server_name $HOST; proxy_set_header Host $host;", "server_name db.internal; proxy_set_header Host $host;"},
+		{"${HOST}:${PORT:-80} ${OTHER} $OTHER", "db.internal:5432 ${OTHER} $OTHER"},
+		{"${OTHER:-$HOST} ${arr[@]} $$HOST ${BROKEN", "${OTHER:-db.internal} ${arr[@]} $db.internal ${BROKEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := goenvsubst.ExpandWith(tt.input, vars, goenvsubst.WithSyntax(syntax))
+			if err != nil {
+				t.Fatalf("ExpandWith() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("ExpandWith() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if got, _ := goenvsubst.ExpandWith("$HOST", vars, goenvsubst.WithSyntax(goenvsubst.ShellFormatSyntax())); got != "$HOST" {
+		t.Errorf("ExpandWith() without names = %q, want $HOST", got)
+	}
+}
+
+func TestDoWithK8sSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"DB_HOST": "db", "PORT": "5432"}
+
+	config := &struct {
+		Args []string
+		Port int `env:"PORT"`
+	}{
+		Args: []string{
+			"--dsn=postgres://$(DB_HOST):$(PORT)",
+			"$$(DB_HOST)",
+			"$DB_HOST ${DB_HOST}",
+			"$(not a name) $( $(MISSING)",
+		},
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithSyntax(goenvsubst.K8sSyntax)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := []string{
+		"--dsn=postgres://db:5432",
+		"$(DB_HOST)",
+		"$DB_HOST ${DB_HOST}",
+		"$(not a name) $( ",
+	}
+	if !reflect.DeepEqual(config.Args, expected) {
+		t.Errorf("Args = %q, want %q", config.Args, expected)
+	}
+	if config.Port != 5432 {
+		t.Errorf("Port = %d, want 5432", config.Port)
+	}
+
+	kept, err := goenvsubst.ExpandWith("$(MISSING)/$(DB_HOST)", vars, goenvsubst.WithSyntax(goenvsubst.K8sSyntax), goenvsubst.WithKeepUnset())
+	if err != nil {
+		t.Fatalf("ExpandWith() error = %v", err)
+	}
+	if kept != "$(MISSING)/db" {
+		t.Errorf("ExpandWith() = %q, want %q", kept, "$(MISSING)/db")
+	}
+}
+
+func TestDoWithWindowsSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"APPDATA": `C:\Users\me\AppData\Roaming`, "ProgramFiles(x86)": `C:\Program Files (x86)`}
+
+	config := &struct{ A, B, C, D string }{
+		`%APPDATA%\app\config.ini`,
+		"%ProgramFiles(x86)%",
+		"50% off, 20% more, 100%% sure",
+		"$APPDATA %MISSING%|%",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithSyntax(goenvsubst.WindowsSyntax)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := &struct{ A, B, C, D string }{
+		`C:\Users\me\AppData\Roaming\app\config.ini`,
+		`C:\Program Files (x86)`,
+		"50% off, 20% more, 100% sure",
+		"$APPDATA |%",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Do() = %+v, want %+v", config, expected)
+	}
+
+	got, err := goenvsubst.ExpandWith("%AppData%", vars, goenvsubst.WithSyntax(goenvsubst.WindowsSyntax), goenvsubst.WithCaseInsensitive())
+	if err != nil {
+		t.Fatalf("ExpandWith() error = %v", err)
+	}
+	if got != vars["APPDATA"] {
+		t.Errorf("ExpandWith() = %q, want %q", got, vars["APPDATA"])
+	}
+}
+
+func TestDoWithActionsSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"DB_HOST": "db", "EMPTY": ""}
+
+	config := &struct{ A, B, C, D, E string }{
+		"postgres://${{ env.DB_HOST }}:${{env.DB_PORT || '5432'}}/app",
+		"${{ env.EMPTY || 'it''s empty' }} ${{ env.MISSING }}|",
+		"${{ secrets.TOKEN }} ${{ github.sha }} ${{ env.DB_HOST == 'db' }}",
+		"echo $$ $DB_HOST ${DB_HOST} ${{ env.DB_HOST",
+		"${{ env.DB_HOST || 'unterminated }}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithSyntax(goenvsubst.ActionsSyntax)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := &struct{ A, B, C, D, E string }{
+		"postgres://db:5432/app",
+		"it's empty |",
+		"${{ secrets.TOKEN }} ${{ github.sha }} ${{ env.DB_HOST == 'db' }}",
+		"echo $$ $DB_HOST ${DB_HOST} ${{ env.DB_HOST",
+		"${{ env.DB_HOST || 'unterminated }}",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Do() = %+v, want %+v", config, expected)
+	}
+
+	_, err := goenvsubst.ExpandWith("${{ env.DB_HOST }} ${{ env.MISSING }}", vars, goenvsubst.WithSyntax(goenvsubst.ActionsSyntax), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) || !reflect.DeepEqual(unsetErr.Names, []string{"MISSING"}) {
+		t.Errorf("ExpandWith() error = %v, want MISSING unset", err)
+	}
+}
+
+func TestDoWithTemplateSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"DB_HOST": "db", "DB_PORT": "5432", "DEBUG": "1"}
+
+	config := &struct {
+		DSN, Mode, Literal, Price string
+		Port                      int `env:"DB_PORT"`
+	}{
+		DSN:     `postgres://{{ .DB_HOST }}:{{ env "DB_PORT" }}/app`,
+		Mode:    `{{ if .DEBUG }}debug{{ else }}release{{ end }}-{{ .MISSING }}`,
+		Literal: "no template",
+		Price:   "$5",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithSyntax(goenvsubst.TemplateSyntax)); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.DSN != "postgres://db:5432/app" || config.Mode != "debug-" || config.Literal != "no template" || config.Price != "$5" || config.Port != 5432 {
+		t.Errorf("Do() = %+v", config)
+	}
+
+	// Variables read through env are only looked up when the template
+	// reaches them
+	report, err := goenvsubst.DoWithReport(&struct{ Value string }{`{{ if false }}{{ env "UNUSED" }}{{ end }}{{ env "MISSING" }}`},
+		goenvsubst.WithResolver(vars), goenvsubst.WithSyntax(goenvsubst.TemplateSyntax), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) || !reflect.DeepEqual(unsetErr.Names, []string{"MISSING"}) {
+		t.Errorf("DoWithReport() error = %v, want MISSING unset", err)
+	}
+	if !reflect.DeepEqual(report.Referenced, []string{"MISSING"}) {
+		t.Errorf("Referenced = %v, want [MISSING]", report.Referenced)
+	}
+
+	err = goenvsubst.Do(&struct{ Value string }{"{{ .DB_HOST"}, goenvsubst.WithSyntax(goenvsubst.TemplateSyntax))
+	if err == nil {
+		t.Error("Do() with a malformed template succeeded")
+	}
+
+	_, err = goenvsubst.ExpandWith(`{{ env "SECRET" }}`, vars, goenvsubst.WithSyntax(goenvsubst.TemplateSyntax), goenvsubst.WithAllowlist("DB_*"))
+	var deniedErr *goenvsubst.DeniedError
+	if !errors.As(err, &deniedErr) || deniedErr.Name != "SECRET" {
+		t.Errorf("ExpandWith() error = %v, want *DeniedError for SECRET", err)
+	}
+}
+
+// underscoreSyntax implements __NAME__ references directly
+type underscoreSyntax struct{}
+
+var underscorePattern = regexp.MustCompile(`__([A-Z][A-Z0-9_]*?)__`)
+
+func (underscoreSyntax) Find(s string) ([]goenvsubst.Segment, error) {
+	var placeholders []goenvsubst.Segment
+	for _, m := range underscorePattern.FindAllStringSubmatchIndex(s, -1) {
+		placeholders = append(placeholders, goenvsubst.Segment{
+			Kind:  goenvsubst.PlaceholderSegment,
+			Name:  s[m[2]:m[3]],
+			Start: goenvsubst.Position{Offset: m[0]},
+			End:   goenvsubst.Position{Offset: m[1]},
+		})
+	}
+	return placeholders, nil
+}
+
+func (syntax underscoreSyntax) Replace(s string, expand func(goenvsubst.Segment, string) (string, error)) (string, error) {
+	placeholders, _ := syntax.Find(s)
+	out, last := "", 0
+	for _, p := range placeholders {
+		value, err := expand(p, s[p.Start.Offset:p.End.Offset])
+		if err != nil {
+			return "", err
+		}
+		out += s[last:p.Start.Offset] + value
+		last = p.End.Offset
+	}
+	return out + s[last:], nil
+}
+
+func TestDoWithCustomSyntax(t *testing.T) {
+	vars := goenvsubst.MapResolver{"DB_HOST": "db", "PORT": "5432"}
+
+	config := &struct{ DSN, Kept string }{"postgres://__DB_HOST__:__PORT__/app", "__MISSING__ $DB_HOST"}
+	err := goenvsubst.Do(config, goenvsubst.WithResolver(vars), goenvsubst.WithSyntax(underscoreSyntax{}), goenvsubst.WithKeepUnset())
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.DSN != "postgres://db:5432/app" || config.Kept != "__MISSING__ $DB_HOST" {
+		t.Errorf("Do() = %+v", config)
+	}
+
+	_, err = goenvsubst.ExpandWith("__MISSING__", vars, goenvsubst.WithSyntax(underscoreSyntax{}), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Errorf("ExpandWith() error = %v, want *UnsetError", err)
+	}
+
+	// Operators given by a syntax apply as in the shell syntax
+	withDefault := goenvsubst.SegmentSyntax(func(s string) ([]goenvsubst.Segment, error) {
+		name, ok := strings.CutPrefix(s, "!")
+		if !ok {
+			return []goenvsubst.Segment{{Kind: goenvsubst.LiteralSegment, Literal: s}}, nil
+		}
+		return []goenvsubst.Segment{{Kind: goenvsubst.PlaceholderSegment, Name: name, Op: ":-", Arg: "fallback"}}, nil
+	})
+	got, err := goenvsubst.ExpandWith("!MISSING", vars, goenvsubst.WithSyntax(withDefault))
+	if err != nil || got != "fallback" {
+		t.Errorf("ExpandWith() = %q, %v, want %q", got, err, "fallback")
+	}
+}
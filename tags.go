@@ -0,0 +1,202 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// tagName is the struct tag that controls how a field is substituted
+const tagName = "envsubst"
+
+// envTagName is the struct tag that fills a field from a single variable, as
+// in env:"PORT"; it is shorthand for envsubst:"${PORT}"
+const envTagName = "env"
+
+// whenTagName is the struct tag that limits substitution of a field to
+// certain profiles, as in envwhen:"APP_ENV=production,staging"
+const whenTagName = "envwhen"
+
+// sourceTagName is the struct tag that binds a field to one of the sources of
+// WithSources, as in envsource:"vault"
+const sourceTagName = "envsource"
+
+// skipTag is the envsubst tag value that opts a field out of substitution
+const skipTag = "-"
+
+// fieldTag holds the parsed options of an envsubst struct tag
+type fieldTag struct {
+	// skip leaves the field untouched, as requested by envsubst:"-"
+	skip bool
+	// required makes references to unset variables in the field an error
+	required bool
+	// secret treats the variables referenced in the field as if they were
+	// given to WithSecretVars
+	secret bool
+	// defaultValue, if set, replaces references to unset or empty variables
+	// in the field
+	defaultValue *string
+	// source is a template given in the tag, such as $LISTEN_ADDR, whose
+	// expansion is assigned to the field instead of expanding its content
+	source string
+	// env is the variable named by the env tag, which is assigned to the
+	// field like a source
+	env string
+	// text round-trips the field through encoding.TextMarshaler and
+	// encoding.TextUnmarshaler
+	text bool
+	// fixed treats a [N]byte or [N]rune array as zero-padded text
+	fixed bool
+	// base64 decodes the expanded value into a []byte field
+	base64 bool
+	// json unmarshals the expanded value into the field
+	json bool
+	// separator, if set, splits the expanded value into the elements of a
+	// slice field
+	separator string
+	// when, if set, is the profile condition from the envwhen tag
+	when *profileCondition
+	// from, if set, is the source named by the envsource tag, the only one
+	// the variables of the field are resolved from
+	from string
+	// transforms lists the trim, upper, lower and quote options in the
+	// order they apply to the expanded value
+	transforms []string
+}
+
+// profileCondition holds when a field is substituted: only if the profile
+// variable has one of the listed values
+type profileCondition struct {
+	variable string
+	values   []string
+}
+
+// matches reports whether the profile variable, as found by resolve, has
+// one of the listed values
+func (c *profileCondition) matches(resolve func(string) (string, bool, error)) (bool, error) {
+	current, _, err := resolve(c.variable)
+	if err != nil {
+		return false, err
+	}
+	for _, value := range c.values {
+		if current == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseTag parses the comma-separated options of the envsubst tag of field,
+// found at path. The first element may be a template, written in syntax.
+func parseTag(field reflect.StructField, path string, syntax Syntax) (fieldTag, error) {
+	var tag fieldTag
+	if when, ok := field.Tag.Lookup(whenTagName); ok {
+		variable, values, found := strings.Cut(when, "=")
+		if !found || strings.TrimSpace(variable) == "" {
+			return tag, fmt.Errorf("goenvsubst: field %s: %s tag must look like VAR=value1,value2, got %q", path, whenTagName, when)
+		}
+		tag.when = &profileCondition{variable: strings.TrimSpace(variable)}
+		for _, value := range strings.Split(values, ",") {
+			tag.when.values = append(tag.when.values, strings.TrimSpace(value))
+		}
+	}
+
+	if from, ok := field.Tag.Lookup(sourceTagName); ok {
+		if from == "" {
+			return tag, fmt.Errorf("goenvsubst: field %s: %s tag must name a source", path, sourceTagName)
+		}
+		tag.from = from
+	}
+
+	// Other packages, such as caarlos0/env and envconfig, read env tags with
+	// options of their own, as in env:"PORT,required"; leave those to them
+	if name, ok := field.Tag.Lookup(envTagName); ok && isName(name) {
+		tag.env = name
+	}
+
+	value, ok := field.Tag.Lookup(tagName)
+	if !ok || value == "" {
+		return tag, nil
+	}
+	if value == skipTag {
+		tag.skip = true
+		return tag, nil
+	}
+
+	var opts []string
+	if template, rest, ok := cutTagTemplate(value, syntax); ok {
+		if tag.env != "" {
+			return tag, fmt.Errorf("goenvsubst: field %s: %s tag and a %s tag template cannot be combined", path, envTagName, tagName)
+		}
+		tag.source, value = template, rest
+	}
+	if value != "" {
+		opts = strings.Split(value, ",")
+	}
+
+	for i, opt := range opts {
+		// The default takes the rest of the tag, so it may contain commas
+		if value, ok := strings.CutPrefix(strings.TrimSpace(opt), "default="); ok {
+			value = strings.Join(append([]string{value}, opts[i+1:]...), ",")
+			tag.defaultValue = &value
+			break
+		}
+		if sep, ok := strings.CutPrefix(strings.TrimSpace(opt), "split="); ok && sep != "" {
+			tag.separator = sep
+			continue
+		}
+
+		switch strings.TrimSpace(opt) {
+		case "text":
+			tag.text = true
+		case "fixed":
+			tag.fixed = true
+		case "base64":
+			tag.base64 = true
+		case "json":
+			tag.json = true
+		case "split":
+			tag.separator = defaultSeparator
+		case "required":
+			tag.required = true
+		case "secret":
+			tag.secret = true
+		case "trim", "upper", "lower", "quote":
+			tag.transforms = append(tag.transforms, strings.TrimSpace(opt))
+		default:
+			return tag, fmt.Errorf("goenvsubst: field %s: unknown %s tag option %q", path, tagName, opt)
+		}
+	}
+	return tag, nil
+}
+
+// cutTagTemplate returns the template an envsubst tag starts with, written
+// in syntax, and the options after it. The template may contain commas, as
+// in ${HOSTS:-a,b}, so it ends at the first comma after which it parses
+// and references a variable. A first element that does not parse is taken
+// as the template, so that its syntax error is reported when it expands.
+func cutTagTemplate(value string, syntax Syntax) (template, rest string, ok bool) {
+	first, after, _ := strings.Cut(value, ",")
+	if trimmed := strings.TrimSpace(first); strings.HasPrefix(trimmed, "default=") || strings.HasPrefix(trimmed, "split=") {
+		return "", value, false
+	}
+
+	// Commas within the placeholders of the whole tag belong to them
+	spans, _ := syntax.Find(value)
+	for i := len(first); i <= len(value); i++ {
+		if i < len(value) && value[i] != ',' || slices.ContainsFunc(spans, func(p Segment) bool { return p.Start.Offset < i && i < p.End.Offset }) {
+			continue
+		}
+		// The template starts with the tag, so an option such as
+		// default=$HOST is not mistaken for the end of one
+		if placeholders, err := syntax.Find(value[:i]); err == nil && len(placeholders) > 0 && placeholders[0].Start.Offset < len(first) {
+			rest, _ = strings.CutPrefix(value[i:], ",")
+			return value[:i], rest, true
+		}
+	}
+	if _, err := syntax.Find(first); err != nil {
+		return first, after, true
+	}
+	return "", value, false
+}
@@ -0,0 +1,296 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestDoEnvWhenTag(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	type Config struct {
+		Always     string
+		Production string `envwhen:"APP_ENV=production"`
+		Deployed   string `envwhen:"APP_ENV=production, staging"`
+		Local      string `envwhen:"APP_ENV=,development"`
+	}
+
+	tests := []struct {
+		profile  string
+		expected Config
+	}{
+		{
+			profile:  "production",
+			expected: Config{"test_value", "test_value", "test_value", "$TEST_VAR"},
+		},
+		{
+			profile:  "staging",
+			expected: Config{"test_value", "$TEST_VAR", "test_value", "$TEST_VAR"},
+		},
+		{
+			profile:  "",
+			expected: Config{"test_value", "$TEST_VAR", "$TEST_VAR", "test_value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("APP_ENV="+tt.profile, func(t *testing.T) {
+			os.Setenv("APP_ENV", tt.profile)
+			defer os.Unsetenv("APP_ENV")
+
+			config := &Config{"$TEST_VAR", "$TEST_VAR", "$TEST_VAR", "$TEST_VAR"}
+			if err := goenvsubst.Do(config); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if *config != tt.expected {
+				t.Errorf("Do() = %+v, want %+v", *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDoSkipTag(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	type Nested struct{ Value string }
+	config := &struct {
+		Price    string `envsubst:"-"`
+		Pattern  string `envsubst:"-"`
+		Nested   Nested `envsubst:"-"`
+		Expanded string
+	}{"$5 per month", "^$TEST_VAR$", Nested{"$TEST_VAR"}, "$TEST_VAR"}
+
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if config.Price != "$5 per month" || config.Pattern != "^$TEST_VAR$" || config.Nested.Value != "$TEST_VAR" {
+		t.Errorf("Do() modified skipped fields: %+v", config)
+	}
+	if config.Expanded != "test_value" {
+		t.Errorf("Expanded = %q, want %q", config.Expanded, "test_value")
+	}
+}
+
+func TestDoRequiredTag(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	defer os.Unsetenv("TEST_VAR")
+
+	type Config struct {
+		Optional string
+		URL      string `envsubst:"required"`
+		Port     string `envsubst:"required"`
+	}
+
+	config := &Config{"$MISSING_VAR", "postgres://$TEST_VAR/app", "${PORT:-8080}"}
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if want := (Config{"", "postgres://test_value/app", "8080"}); *config != want {
+		t.Errorf("Do() = %+v, want %+v", *config, want)
+	}
+
+	config = &Config{"", "postgres://$DB_HOST/app", ""}
+	err := goenvsubst.Do(config)
+	var requiredErr *goenvsubst.RequiredError
+	if !errors.As(err, &requiredErr) {
+		t.Fatalf("Do() error = %v, want *RequiredError", err)
+	}
+	if requiredErr.Field != "URL" || requiredErr.Name != "DB_HOST" {
+		t.Errorf("RequiredError = %+v, want field URL and variable DB_HOST", requiredErr)
+	}
+	if want := "goenvsubst: field URL: DB_HOST is not set"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	source := &struct {
+		Listen string `envsubst:"$LISTEN_ADDR,required"`
+	}{}
+	if err := goenvsubst.Do(source); !errors.As(err, &requiredErr) || requiredErr.Name != "LISTEN_ADDR" {
+		t.Errorf("Do() error = %v, want LISTEN_ADDR required", err)
+	}
+}
+
+func TestDoDefaultTag(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("EMPTY_VAR", "")
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("EMPTY_VAR")
+	}()
+
+	type Config struct {
+		Port    string `envsubst:"default=8080"`
+		Empty   string `envsubst:"default=fallback"`
+		Set     string `envsubst:"default=fallback"`
+		Hosts   string `envsubst:"default=a,b,c"`
+		Static  string `envsubst:"default=unused"`
+		Listen  string `envsubst:"$LISTEN_ADDR,default=:8080"`
+		Level   string `envsubst:"required,default=info"`
+		Operand string `envsubst:"default=fallback"`
+	}
+
+	config := &Config{
+		Port:    "$PORT",
+		Empty:   "$EMPTY_VAR",
+		Set:     "$TEST_VAR",
+		Hosts:   "$HOSTS",
+		Static:  "static",
+		Level:   "$LOG_LEVEL",
+		Operand: "${MISSING_VAR:-explicit}",
+	}
+	if err := goenvsubst.Do(config, goenvsubst.Strict()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	expected := Config{"8080", "fallback", "test_value", "a,b,c", "static", ":8080", "info", "explicit"}
+	if *config != expected {
+		t.Errorf("Do() = %+v, want %+v", *config, expected)
+	}
+}
+
+func TestDoEnvWhenTagInvalid(t *testing.T) {
+	config := &struct {
+		Value string `envwhen:"production"`
+	}{"$TEST_VAR"}
+	if err := goenvsubst.Do(config); err == nil {
+		t.Error("Do() expected error for an envwhen tag without a variable")
+	}
+}
+
+func TestDoUnknownTagOption(t *testing.T) {
+	config := &struct {
+		Value string `envsubst:"txet"`
+	}{"$TEST_VAR"}
+	if err := goenvsubst.Do(config); err == nil {
+		t.Error("Do() expected error for an unknown tag option")
+	}
+}
+
+func TestDoSourceTag(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "from-env")
+	t.Setenv("DB_USER", "env-user")
+
+	type Config struct {
+		User     string
+		Password string `envsource:"vault"`
+		Backup   string `envsource:"vault" envsubst:"$DB_BACKUP_PASSWORD,required"`
+	}
+	vault := goenvsubst.Source{Name: "vault", Resolver: goenvsubst.MapResolver{"DB_PASSWORD": "from-vault", "DB_USER": "vault-user"}}
+	opts := []goenvsubst.Option{goenvsubst.WithSources(goenvsubst.Env(), vault)}
+
+	config := &Config{User: "$DB_USER", Password: "$DB_PASSWORD"}
+	err := goenvsubst.Do(config, opts...)
+	var required *goenvsubst.RequiredError
+	if !errors.As(err, &required) || required.Name != "DB_BACKUP_PASSWORD" {
+		t.Fatalf("Do() error = %v, want DB_BACKUP_PASSWORD required", err)
+	}
+
+	// The environment has precedence, but not for the fields bound to vault
+	t.Setenv("DB_BACKUP_PASSWORD", "from-env")
+	vault.Resolver.(goenvsubst.MapResolver)["DB_BACKUP_PASSWORD"] = "backup-from-vault"
+	config = &Config{User: "$DB_USER", Password: "$DB_PASSWORD"}
+	report, err := goenvsubst.DoWithReport(config, opts...)
+	if err != nil {
+		t.Fatalf("DoWithReport() error = %v", err)
+	}
+	if config.User != "env-user" || config.Password != "from-vault" || config.Backup != "backup-from-vault" {
+		t.Errorf("DoWithReport() = %+v", config)
+	}
+	if report.Sources["DB_PASSWORD"] != "vault" {
+		t.Errorf("Report.Sources = %v, want DB_PASSWORD from vault", report.Sources)
+	}
+
+	// Options given after WithSources apply to the bound source too
+	t.Setenv("APP_DB_PASSWORD", "prefixed-from-env")
+	vault.Resolver.(goenvsubst.MapResolver)["APP_DB_PASSWORD"] = "prefixed-from-vault"
+	config = &Config{User: "$DB_USER", Password: "$DB_PASSWORD"}
+	report, err = goenvsubst.DoWithReport(config, append(opts, goenvsubst.WithPrefix("APP_"))...)
+	if err != nil {
+		t.Fatalf("DoWithReport() error = %v", err)
+	}
+	if config.User != "env-user" || config.Password != "prefixed-from-vault" || config.Backup != "backup-from-vault" {
+		t.Errorf("DoWithReport() with WithPrefix = %+v", config)
+	}
+	if report.Sources["DB_PASSWORD"] != "vault" {
+		t.Errorf("Report.Sources = %v, want DB_PASSWORD from vault", report.Sources)
+	}
+
+	config = &Config{Password: "$DB_PASSWORD"}
+	err = goenvsubst.Do(config, goenvsubst.WithSources(goenvsubst.Env()))
+	if want := `goenvsubst: field Password: unknown source "vault"`; err == nil || err.Error() != want {
+		t.Errorf("Do() error = %v, want %s", err, want)
+	}
+}
+
+func TestDoTagTemplateSyntaxes(t *testing.T) {
+	vars := map[string]string{"PORT": "8080"}
+
+	t.Run("windows", func(t *testing.T) {
+		config := &struct {
+			Port int `envsubst:"%PORT%,required"`
+		}{}
+		if err := goenvsubst.DoWithMap(config, vars, goenvsubst.WithSyntax(goenvsubst.WindowsSyntax)); err != nil || config.Port != 8080 {
+			t.Errorf("DoWithMap() = %+v, %v, want Port 8080", *config, err)
+		}
+	})
+	t.Run("k8s", func(t *testing.T) {
+		config := &struct {
+			Port int `envsubst:"$(PORT),required"`
+		}{}
+		if err := goenvsubst.DoWithMap(config, vars, goenvsubst.WithSyntax(goenvsubst.K8sSyntax)); err != nil || config.Port != 8080 {
+			t.Errorf("DoWithMap() = %+v, %v, want Port 8080", *config, err)
+		}
+	})
+	t.Run("actions", func(t *testing.T) {
+		config := &struct {
+			Port    int `envsubst:"${{ env.PORT }}"`
+			Timeout int `envsubst:"${{ env.TIMEOUT || '30' }}"`
+		}{}
+		if err := goenvsubst.DoWithMap(config, vars, goenvsubst.WithSyntax(goenvsubst.ActionsSyntax)); err != nil || config.Port != 8080 || config.Timeout != 30 {
+			t.Errorf("DoWithMap() = %+v, %v, want Port 8080 and Timeout 30", *config, err)
+		}
+	})
+	t.Run("template", func(t *testing.T) {
+		config := &struct {
+			Port int `envsubst:"{{ .PORT }},required"`
+		}{}
+		if err := goenvsubst.DoWithMap(config, vars, goenvsubst.WithSyntax(goenvsubst.TemplateSyntax)); err != nil || config.Port != 8080 {
+			t.Errorf("DoWithMap() = %+v, %v, want Port 8080", *config, err)
+		}
+	})
+
+	// Options are not mistaken for templates
+	config := &struct {
+		Name string `envsubst:"trim,upper"`
+	}{Name: "%NAME%"}
+	if err := goenvsubst.DoWithMap(config, map[string]string{"NAME": " api "}, goenvsubst.WithSyntax(goenvsubst.WindowsSyntax)); err != nil || config.Name != "API" {
+		t.Errorf("DoWithMap() = %+v, %v, want Name API", *config, err)
+	}
+}
+
+func TestDoTagTemplateCommas(t *testing.T) {
+	type Config struct {
+		Hosts  []string `envsubst:"${HOSTS:-a,b},split"`
+		Origin string   `envsubst:"${SCHEME:-https,http}://${HOST}"`
+	}
+
+	config := &Config{}
+	if err := goenvsubst.DoWithMap(config, map[string]string{"HOST": "example.com"}); err != nil {
+		t.Fatalf("DoWithMap() error = %v", err)
+	}
+	if !slices.Equal(config.Hosts, []string{"a", "b"}) || config.Origin != "https,http://example.com" {
+		t.Errorf("DoWithMap() = %+v", *config)
+	}
+
+	config = &Config{}
+	err := goenvsubst.DoWithMap(config, nil, goenvsubst.WithSyntax(goenvsubst.LenientShellSyntax))
+	if err != nil || !slices.Equal(config.Hosts, []string{"a", "b"}) {
+		t.Errorf("DoWithMap() = %+v, %v with LenientShellSyntax", *config, err)
+	}
+}
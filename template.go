@@ -0,0 +1,181 @@
+package goenvsubst
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateSyntax treats strings as text/template templates, so values such
+// as "{{ .DB_HOST }}:{{ env "DB_PORT" }}" or "{{ if .DEBUG }}debug{{ end }}"
+// can be substituted in place by teams that already template their
+// configuration with Go templates. Variables are available as the fields of
+// dot, {{ .NAME }}, and through the env function, {{ env "NAME" }}, and are
+// resolved like any other reference, so the options such as Strict and
+// WithResolver apply. Variables read as fields are looked up before the
+// template runs, also when they are only used in branches that are not
+// taken; use env to look them up only when needed. The $ of other syntaxes
+// has no meaning.
+var TemplateSyntax Syntax = templateSyntax{}
+
+type templateSyntax struct{}
+
+// envFunc is the name of the function that reads a variable in TemplateSyntax
+const envFunc = "env"
+
+// parse parses s as a template; env is what the env function calls
+func (templateSyntax) parse(s string, env func(name string) (string, error)) (*template.Template, error) {
+	t, err := template.New("").Funcs(template.FuncMap{envFunc: env}).Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("goenvsubst: %w", err)
+	}
+	return t, nil
+}
+
+func (syntax templateSyntax) Find(s string) ([]Segment, error) {
+	t, err := syntax.parse(s, func(string) (string, error) { return "", nil })
+	if err != nil {
+		return nil, err
+	}
+	var placeholders []Segment
+	for _, ref := range templateRefs(t, s) {
+		placeholders = append(placeholders, ref.placeholder)
+	}
+	return placeholders, nil
+}
+
+func (syntax templateSyntax) Replace(s string, expand func(Segment, string) (string, error)) (string, error) {
+	// The function may fail after the template has wrapped its error, so
+	// keep the error as expand returned it
+	var expandErr error
+	env := func(name string) (string, error) {
+		value, err := expand(Segment{Kind: PlaceholderSegment, Name: name, form: templateForm}, templateText(envFunc+" "+strconv.Quote(name)))
+		if err != nil {
+			expandErr = err
+		}
+		return value, err
+	}
+	t, err := syntax.parse(s, env)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]string{}
+	for _, ref := range templateRefs(t, s) {
+		if _, ok := data[ref.placeholder.Name]; ok || !ref.field {
+			continue
+		}
+		value, err := expand(ref.placeholder, templateText("."+ref.placeholder.Name))
+		if err != nil {
+			return "", err
+		}
+		data[ref.placeholder.Name] = value
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		if expandErr != nil {
+			return "", expandErr
+		}
+		return "", fmt.Errorf("goenvsubst: %w", err)
+	}
+	return b.String(), nil
+}
+
+// templateText writes the action around a pipeline
+func templateText(pipeline string) string {
+	return "{{ " + pipeline + " }}"
+}
+
+// templateRef is a variable read by a template
+type templateRef struct {
+	placeholder Segment
+	// field tells {{ .NAME }} from {{ env "NAME" }}
+	field bool
+}
+
+// templateRefs returns the variables read by t, parsed from s, and the
+// templates it defines
+func templateRefs(t *template.Template, s string) []templateRef {
+	var refs []templateRef
+	for _, associated := range t.Templates() {
+		if associated.Tree != nil {
+			refs = appendTemplateRefs(refs, s, associated.Root)
+		}
+	}
+
+	// Nodes are mostly met in the order they are written, so positions are
+	// computed in a single pass once their offsets are known
+	positions := newPositioner(s)
+	for i := range refs {
+		refs[i].placeholder.Start = positions.at(refs[i].placeholder.Start.Offset)
+		refs[i].placeholder.End = positions.at(refs[i].placeholder.End.Offset)
+	}
+	return refs
+}
+
+// appendTemplateRefs appends the variables that node and the nodes below it
+// read
+func appendTemplateRefs(refs []templateRef, s string, node parse.Node) []templateRef {
+	ref := func(name string, node parse.Node, field bool) templateRef {
+		start := int(node.Position())
+		end := min(start+len(node.String()), len(s))
+		return templateRef{
+			placeholder: Segment{Kind: PlaceholderSegment, Name: name, Start: Position{Offset: start}, End: Position{Offset: end}, form: templateForm},
+			field:       field,
+		}
+	}
+
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return refs
+		}
+		for _, child := range node.Nodes {
+			refs = appendTemplateRefs(refs, s, child)
+		}
+	case *parse.ActionNode:
+		refs = appendTemplateRefs(refs, s, node.Pipe)
+	case *parse.IfNode:
+		refs = appendBranchRefs(refs, s, &node.BranchNode)
+	case *parse.RangeNode:
+		refs = appendBranchRefs(refs, s, &node.BranchNode)
+	case *parse.WithNode:
+		refs = appendBranchRefs(refs, s, &node.BranchNode)
+	case *parse.TemplateNode:
+		refs = appendTemplateRefs(refs, s, node.Pipe)
+	case *parse.PipeNode:
+		if node == nil {
+			return refs
+		}
+		for _, cmd := range node.Cmds {
+			refs = appendTemplateRefs(refs, s, cmd)
+		}
+	case *parse.CommandNode:
+		if len(node.Args) == 2 {
+			ident, isIdent := node.Args[0].(*parse.IdentifierNode)
+			name, isString := node.Args[1].(*parse.StringNode)
+			if isIdent && isString && ident.Ident == envFunc {
+				return append(refs, ref(name.Text, node, false))
+			}
+		}
+		for _, arg := range node.Args {
+			refs = appendTemplateRefs(refs, s, arg)
+		}
+	case *parse.ChainNode:
+		refs = appendTemplateRefs(refs, s, node.Node)
+	case *parse.FieldNode:
+		refs = append(refs, ref(node.Ident[0], node, true))
+	}
+	return refs
+}
+
+// appendBranchRefs appends the variables read in an if, range or with
+// action
+func appendBranchRefs(refs []templateRef, s string, node *parse.BranchNode) []templateRef {
+	refs = appendTemplateRefs(refs, s, node.Pipe)
+	refs = appendTemplateRefs(refs, s, node.List)
+	return appendTemplateRefs(refs, s, node.ElseList)
+}
@@ -0,0 +1,46 @@
+package goenvsubst
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// doText expands a field through its text form: the value is marshaled with
+// encoding.TextMarshaler, expanded, and unmarshaled back with
+// encoding.TextUnmarshaler. This reaches wrapper types that keep templated
+// strings in unexported fields.
+func (w *walker) doText(v reflect.Value, path string) error {
+	ptr := v
+	if v.Kind() != reflect.Ptr {
+		ptr = v.Addr()
+	} else if v.IsNil() {
+		return nil
+	}
+
+	marshaler, ok := ptr.Interface().(encoding.TextMarshaler)
+	unmarshaler, ok2 := ptr.Interface().(encoding.TextUnmarshaler)
+	if !ok || !ok2 {
+		return fmt.Errorf("goenvsubst: field %s: %s must implement encoding.TextMarshaler and encoding.TextUnmarshaler", path, v.Type())
+	}
+
+	text, err := marshaler.MarshalText()
+	if err != nil {
+		return fmt.Errorf("goenvsubst: field %s: %w", path, err)
+	}
+	expanded, err := w.expand(string(text), path)
+	if err != nil {
+		return err
+	}
+	if expanded == string(text) {
+		return nil
+	}
+	if w.checking() {
+		// Unmarshal into a scratch value to find text it rejects
+		unmarshaler = reflect.New(ptr.Type().Elem()).Interface().(encoding.TextUnmarshaler)
+	}
+	if err := unmarshaler.UnmarshalText([]byte(expanded)); err != nil {
+		return fmt.Errorf("goenvsubst: field %s: %w", path, err)
+	}
+	return nil
+}
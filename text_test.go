@@ -0,0 +1,79 @@
+package goenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// secretRef keeps its templated value in an unexported field and is only
+// reachable through its text form
+type secretRef struct {
+	ref string
+}
+
+func (s secretRef) MarshalText() ([]byte, error) { return []byte(s.ref), nil }
+
+func (s *secretRef) UnmarshalText(text []byte) error {
+	if strings.ContainsAny(string(text), " \t") {
+		return errors.New("secret must not contain whitespace")
+	}
+	s.ref = string(text)
+	return nil
+}
+
+func TestDoTextTag(t *testing.T) {
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("SPACED_VAR", "has spaces")
+	defer func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("SPACED_VAR")
+	}()
+
+	config := &struct {
+		Tagged   secretRef  `envsubst:"text"`
+		Pointer  *secretRef `envsubst:"text"`
+		Nil      *secretRef `envsubst:"text"`
+		Untagged secretRef
+	}{
+		Tagged:   secretRef{"$TEST_VAR"},
+		Pointer:  &secretRef{"$TEST_VAR"},
+		Untagged: secretRef{"$TEST_VAR"},
+	}
+
+	if err := goenvsubst.Do(config); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if config.Tagged.ref != "test_value" {
+		t.Errorf("Tagged = %q, want %q", config.Tagged.ref, "test_value")
+	}
+	if config.Pointer.ref != "test_value" {
+		t.Errorf("Pointer = %q, want %q", config.Pointer.ref, "test_value")
+	}
+	if config.Nil != nil {
+		t.Errorf("Nil = %+v, want nil", config.Nil)
+	}
+	if config.Untagged.ref != "$TEST_VAR" {
+		t.Errorf("Untagged = %q, want %q", config.Untagged.ref, "$TEST_VAR")
+	}
+
+	failing := &struct {
+		Value secretRef `envsubst:"text"`
+	}{secretRef{"$SPACED_VAR"}}
+	if err := goenvsubst.Do(failing); err == nil || !strings.Contains(err.Error(), "Value") {
+		t.Errorf("Do() error = %v, want an UnmarshalText error naming the field", err)
+	}
+}
+
+func TestDoTextTagUnsupportedType(t *testing.T) {
+	config := &struct {
+		Value int `envsubst:"text"`
+	}{}
+	if err := goenvsubst.Do(config); err == nil {
+		t.Error("Do() expected error for a type without text methods")
+	}
+}
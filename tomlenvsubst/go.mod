@@ -0,0 +1,8 @@
+module github.com/iamolegga/goenvsubst/tomlenvsubst
+
+go 1.24.4
+
+require github.com/iamolegga/goenvsubst v1.0.0
+
+require github.com/pelletier/go-toml/v2 v2.4.3
+
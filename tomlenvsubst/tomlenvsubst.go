@@ -0,0 +1,196 @@
+// Package tomlenvsubst replaces environment variable references in TOML
+// documents. It parses the document and rewrites only the bytes of string
+// values, so keys, comments, ordering and formatting are kept exactly.
+package tomlenvsubst
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// Do replaces environment variable references in the string values of the
+// TOML document in data and returns the rewritten document. Keys, numbers,
+// booleans and dates are never modified, and everything but the substituted
+// strings is copied byte for byte. A substituted string keeps its quoting
+// when the new value can be written with it, and is written as a basic
+// string with escapes otherwise. opts apply as in goenvsubst.Do; with
+// goenvsubst.Strict, the unset variables of all strings are reported
+// together.
+func Do(data []byte, opts ...goenvsubst.Option) ([]byte, error) {
+	var v map[string]any
+	if err := toml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("tomlenvsubst: %w", err)
+	}
+
+	r := &rewriter{expander: goenvsubst.New(opts...), in: data, arrayTables: map[string]int{}}
+	p := &unstable.Parser{}
+	p.Reset(data)
+	for p.NextExpression() {
+		if err := r.expression(p.Expression()); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.Error(); err != nil {
+		return nil, fmt.Errorf("tomlenvsubst: %w", err)
+	}
+	if r.unset != nil {
+		return nil, fmt.Errorf("tomlenvsubst: %w", r.unset)
+	}
+	return append(r.out, data[r.pos:]...), nil
+}
+
+// rewriter copies a TOML document while expanding string values
+type rewriter struct {
+	expander *goenvsubst.Expander
+	in       []byte
+	pos      int
+	out      []byte
+	// table is the path of the current table
+	table string
+	// arrayTables counts the elements of each array of tables
+	arrayTables map[string]int
+	// unset collects the unset variables of Strict across all strings, so
+	// that they are reported together
+	unset *goenvsubst.UnsetError
+}
+
+// expression processes a top-level table header or key/value pair
+func (r *rewriter) expression(n *unstable.Node) error {
+	switch n.Kind {
+	case unstable.Table:
+		r.table = keyPath("", n.Key())
+	case unstable.ArrayTable:
+		key := keyPath("", n.Key())
+		r.table = key + "[" + strconv.Itoa(r.arrayTables[key]) + "]"
+		r.arrayTables[key]++
+	case unstable.KeyValue:
+		return r.value(n.Value(), keyPath(r.table, n.Key()))
+	}
+	return nil
+}
+
+// value processes a value and the values nested in it; path names it in
+// errors
+func (r *rewriter) value(n *unstable.Node, path string) error {
+	switch n.Kind {
+	case unstable.String:
+		return r.str(n, path)
+	case unstable.Array:
+		it := n.Children()
+		for i := 0; it.Next(); i++ {
+			if err := r.value(it.Node(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case unstable.InlineTable:
+		it := n.Children()
+		for it.Next() {
+			kv := it.Node()
+			if err := r.value(kv.Value(), keyPath(path, kv.Key())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// str expands a string value and replaces its bytes if it changed
+func (r *rewriter) str(n *unstable.Node, path string) error {
+	original := string(n.Data)
+	expanded, err := r.expander.Expand(original)
+	var unset *goenvsubst.UnsetError
+	if errors.As(err, &unset) {
+		if r.unset == nil {
+			r.unset = &goenvsubst.UnsetError{Paths: map[string][]string{}}
+		}
+		for _, name := range unset.Names {
+			if !slices.Contains(r.unset.Names, name) {
+				r.unset.Names = append(r.unset.Names, name)
+			}
+			r.unset.Paths[name] = append(r.unset.Paths[name], path)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("tomlenvsubst: %s: %w", path, err)
+	}
+	if expanded == original {
+		return nil
+	}
+
+	start, end := int(n.Raw.Offset), int(n.Raw.Offset+n.Raw.Length)
+	r.out = append(r.out, r.in[r.pos:start]...)
+	r.out = append(r.out, quote(string(r.in[start:end]), expanded)...)
+	r.pos = end
+	return nil
+}
+
+// keyPath appends the dotted key of it to path
+func keyPath(path string, it unstable.Iterator) string {
+	for it.Next() {
+		if path != "" {
+			path += "."
+		}
+		path += string(it.Node().Data)
+	}
+	return path
+}
+
+// quote writes s as a TOML string, in the quoting of raw, the original
+// string, if s can be written with it
+func quote(raw, s string) string {
+	// Literal strings cannot hold control characters other than tabs, and
+	// only multi-line ones newlines
+	control := func(newline bool) bool {
+		return strings.ContainsFunc(s, func(c rune) bool {
+			return c < ' ' && c != '\t' && (c != '\n' || !newline) || c == 0x7f
+		})
+	}
+	switch {
+	case strings.HasPrefix(raw, "'''"):
+		// A newline right after the opening quotes would be trimmed
+		if !control(true) && !strings.Contains(s, "'''") && !strings.HasPrefix(s, "\n") && !strings.HasSuffix(s, "'") {
+			return "'''" + s + "'''"
+		}
+	case strings.HasPrefix(raw, "'"):
+		if !control(false) && !strings.Contains(s, "'") {
+			return "'" + s + "'"
+		}
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < ' ' || c == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package tomlenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/tomlenvsubst"
+)
+
+func setenv(t *testing.T) {
+	t.Helper()
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("TRICKY_VAR", "it's \"quoted\"\nand \\ split")
+	os.Setenv("MULTILINE_VAR", "one\ntwo")
+	t.Cleanup(func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("TRICKY_VAR")
+		os.Unsetenv("MULTILINE_VAR")
+	})
+}
+
+func TestDo(t *testing.T) {
+	setenv(t)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "values are expanded, keys are not",
+			input:    "\"$TEST_VAR\" = \"$TEST_VAR\"\n",
+			expected: "\"$TEST_VAR\" = \"test_value\"\n",
+		},
+		{
+			name:     "comments and ordering are kept",
+			input:    "# app\nname = \"app\" # name\n\n[db]\nport = 5432\nhost = \"${TEST_VAR}\"  # primary\n",
+			expected: "# app\nname = \"app\" # name\n\n[db]\nport = 5432\nhost = \"test_value\"  # primary\n",
+		},
+		{
+			name:     "quoting is kept",
+			input:    "a = '$TEST_VAR'\nb = '''\n$TEST_VAR'''\nc = \"\"\"$TEST_VAR\"\"\"\n",
+			expected: "a = 'test_value'\nb = '''test_value'''\nc = \"test_value\"\n",
+		},
+		{
+			name:     "values are escaped",
+			input:    "a = '$TRICKY_VAR'\nb = \"$TRICKY_VAR\"\n",
+			expected: "a = \"it's \\\"quoted\\\"\\nand \\\\ split\"\nb = \"it's \\\"quoted\\\"\\nand \\\\ split\"\n",
+		},
+		{
+			name:     "multi-line literal strings keep newlines",
+			input:    "a = '''$MULTILINE_VAR'''\n",
+			expected: "a = '''one\ntwo'''\n",
+		},
+		{
+			name:     "arrays, inline tables and arrays of tables",
+			input:    "hosts = [\"$TEST_VAR\", 1, { name = \"$TEST_VAR\" }]\n[[servers]]\nname = \"a\"\n[[servers]]\nname = \"$TEST_VAR\"\n",
+			expected: "hosts = [\"test_value\", 1, { name = \"test_value\" }]\n[[servers]]\nname = \"a\"\n[[servers]]\nname = \"test_value\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tomlenvsubst.Do([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("Do() = %q, want %q", out, tt.expected)
+			}
+			var v map[string]any
+			if err := toml.Unmarshal(out, &v); err != nil {
+				t.Errorf("Do() = %q, not valid TOML: %v", out, err)
+			}
+		})
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	if _, err := tomlenvsubst.Do([]byte("a = \"unclosed\n")); err == nil {
+		t.Error("Do() expected error for invalid TOML")
+	}
+
+	// The unset variables of every string are reported together
+	input := "[[servers]]\nhosts = [\"ok\"]\n[[servers]]\nhosts = [\"ok\", \"$MISSING_VAR\"]\nuser = \"$MISSING_USER\"\n"
+	_, err := tomlenvsubst.Do([]byte(input), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+	if want := "tomlenvsubst: goenvsubst: unset variables: MISSING_VAR (servers[1].hosts[1]), MISSING_USER (servers[1].user)"; err.Error() != want {
+		t.Errorf("Do() error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,47 @@
+package goenvsubst
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// applyTransforms applies the trim, upper, lower and quote options of a
+// field's tag to s, in order
+func applyTransforms(s string, transforms []string) string {
+	for _, transform := range transforms {
+		switch transform {
+		case "trim":
+			s = strings.TrimSpace(s)
+		case "upper":
+			s = strings.ToUpper(s)
+		case "lower":
+			s = strings.ToLower(s)
+		case "quote":
+			s = strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// changeCase applies the case operator op, as in ${NAME^^}, to value
+func changeCase(op, value string) string {
+	switch op {
+	case "^^":
+		return strings.ToUpper(value)
+	case ",,":
+		return strings.ToLower(value)
+	}
+
+	first, size := utf8.DecodeRuneInString(value)
+	if size == 0 {
+		return value
+	}
+	if op == "^" {
+		first = unicode.ToUpper(first)
+	} else {
+		first = unicode.ToLower(first)
+	}
+	return string(first) + value[size:]
+}
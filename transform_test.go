@@ -0,0 +1,62 @@
+package goenvsubst_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+func TestExpandCaseOperators(t *testing.T) {
+	vars := goenvsubst.WithResolver(goenvsubst.MapResolver{"HOST": "Db.Internal", "NAME": "élodie", "EMPTY": ""})
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"${HOST^^}", "DB.INTERNAL"},
+		{"${HOST,,}", "db.internal"},
+		{"${NAME^}", "Élodie"},
+		{"${HOST,}", "db.Internal"},
+		{"${EMPTY^}${MISSING_VAR^^}", ""},
+		{"${MISSING_VAR:-${HOST,,}}", "db.internal"},
+	}
+	for _, tt := range tests {
+		got, err := goenvsubst.Expand(tt.input, vars)
+		if err != nil || got != tt.expected {
+			t.Errorf("Expand(%q) = %q, %v, want %q", tt.input, got, err, tt.expected)
+		}
+	}
+
+	// Like plain references, case operators do not handle unset variables
+	if _, err := goenvsubst.Expand("${MISSING_VAR^^}", vars, goenvsubst.Strict()); err == nil {
+		t.Error("Expand() in strict mode error = nil for an unset variable")
+	}
+	if got, _ := goenvsubst.Expand("${MISSING_VAR,,}", vars, goenvsubst.WithKeepUnset()); got != "${MISSING_VAR,,}" {
+		t.Errorf("Expand() with WithKeepUnset = %q", got)
+	}
+}
+
+func TestDoTransformTags(t *testing.T) {
+	vars := goenvsubst.WithResolver(goenvsubst.MapResolver{"HOST": "  Db.Internal\n", "REGION": " EU-West "})
+	type Config struct {
+		Host   string   `envsubst:"trim,lower"`
+		Region string   `env:"REGION" envsubst:"trim,lower"`
+		Quoted string   `envsubst:"$HOST,trim,quote"`
+		Hosts  []string `envsubst:"upper,trim"`
+		Plain  string
+	}
+	config := &Config{Host: "$HOST", Hosts: []string{"$HOST", " static "}, Plain: "$REGION"}
+	if err := goenvsubst.Do(config, vars); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	expected := Config{
+		Host:   "db.internal",
+		Region: "eu-west",
+		Quoted: `"Db.Internal"`,
+		Hosts:  []string{"DB.INTERNAL", "STATIC"},
+		Plain:  " EU-West ",
+	}
+	if !reflect.DeepEqual(*config, expected) {
+		t.Errorf("Do() = %+v, want %+v", *config, expected)
+	}
+}
@@ -0,0 +1,75 @@
+package goenvsubst
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithURLEscaping percent-encodes the values that references insert into
+// the userinfo or a query value of a URL written in the template, so that a
+// password containing @ or : does not silently produce an invalid DSN:
+// "postgres://app:$DB_PASSWORD@db/app" becomes
+// postgres://app:p%40ss@db/app for the password p@ss. A reference counts as
+// part of a URL when the text before it holds a scheme followed by :// with
+// no space in between; it is in the userinfo if an @ follows it before the
+// host ends, and in a query value if it comes after the = of a field
+// following the ?. Other references, such as those for the host or the
+// whole query, and those with modifiers such as ${NAME|urlencode}, are
+// inserted unchanged.
+func WithURLEscaping() Option {
+	return func(c *config) {
+		c.urlEscaping = true
+	}
+}
+
+// escapeURLComponent percent-encodes value for the userinfo, path or query
+// of a URL, escaping spaces as %20, which each of them accepts
+func escapeURLComponent(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// inURLComponent reports whether placeholder, written as text in s, is in
+// the userinfo or in a query value of a URL, as WithURLEscaping describes.
+// Placeholders of a Syntax that does not locate them in s are not.
+func inURLComponent(s string, placeholder Segment, text string) bool {
+	start, end := placeholder.Start.Offset, placeholder.End.Offset
+	if start < 0 || end > len(s) || start > end || s[start:end] != text {
+		return false
+	}
+	before, after := s[:start], s[end:]
+	i := strings.LastIndex(before, "://")
+	if i <= 0 || !isSchemeEnd(before[i-1]) {
+		return false
+	}
+	rest := before[i+len("://"):]
+	if strings.ContainsAny(rest, " \t\r\n\"'") {
+		// The URL ended before the placeholder
+		return false
+	}
+
+	if !strings.ContainsAny(rest, "/?#@") {
+		j := strings.IndexAny(after, "/?#@ \t\r\n\"'")
+		return j >= 0 && after[j] == '@'
+	}
+	query := strings.LastIndex(rest, "?")
+	if query < 0 || strings.Contains(rest[query:], "#") {
+		return false
+	}
+	field := rest[query+1:]
+	if j := strings.LastIndex(field, "&"); j >= 0 {
+		field = field[j+1:]
+	}
+	return strings.Contains(field, "=")
+}
+
+// isSchemeEnd reports whether c may end the scheme of a URL, which may be
+// given by a reference such as ${SCHEME}
+func isSchemeEnd(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	case c == '+', c == '-', c == '.', c == '}':
+		return true
+	}
+	return false
+}
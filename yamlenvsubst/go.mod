@@ -0,0 +1,8 @@
+module github.com/iamolegga/goenvsubst/yamlenvsubst
+
+go 1.24.4
+
+require (
+	github.com/iamolegga/goenvsubst v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
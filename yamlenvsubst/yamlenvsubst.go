@@ -0,0 +1,166 @@
+// Package yamlenvsubst replaces environment variable references in YAML
+// documents. Unlike text substitution, it parses the documents first and
+// only substitutes string scalars, so values containing ':' or '#' cannot
+// change the structure of the document.
+package yamlenvsubst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/iamolegga/goenvsubst"
+)
+
+// The resolved tags of string scalars and of the << merge key
+const (
+	strTag   = "!!str"
+	mergeTag = "!!merge"
+)
+
+// indent is the indentation of the re-serialized documents
+const indent = 2
+
+// Do replaces environment variable references in the string scalars of the
+// YAML documents in data and returns them re-serialized. Mapping keys and
+// scalars of other types, such as numbers and booleans, are never modified.
+// Comments, anchors and aliases are kept; a value shared through an alias is
+// substituted once, at its anchor. Plain scalars are typed again after
+// substitution, as if the text had been substituted, so port: ${PORT}
+// becomes a number, while quoted scalars stay strings. The output is
+// indented by two spaces and may otherwise differ in formatting from data.
+// opts apply as in goenvsubst.Do; with goenvsubst.Strict, the unset
+// variables of all documents are reported together.
+func Do(data []byte, opts ...goenvsubst.Option) ([]byte, error) {
+	sub := &substitution{expander: goenvsubst.New(opts...)}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(indent)
+	for docs := 0; ; docs++ {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				if docs == 0 {
+					// The encoder cannot write an empty stream
+					return nil, nil
+				}
+				break
+			}
+			return nil, fmt.Errorf("yamlenvsubst: %w", err)
+		}
+		if err := sub.node(&doc, ""); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return nil, fmt.Errorf("yamlenvsubst: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("yamlenvsubst: %w", err)
+	}
+	if sub.unset != nil {
+		return nil, fmt.Errorf("yamlenvsubst: %w", sub.unset)
+	}
+	return out.Bytes(), nil
+}
+
+// substitution carries the expander of a single Do call and the unset
+// variables of Strict, which are collected across all scalars so that they
+// are reported together
+type substitution struct {
+	expander *goenvsubst.Expander
+	unset    *goenvsubst.UnsetError
+}
+
+// addUnset records the variables of err, found at path, if it is an
+// *UnsetError, and reports whether it is
+func (s *substitution) addUnset(err error, path string) bool {
+	var unset *goenvsubst.UnsetError
+	if !errors.As(err, &unset) {
+		return false
+	}
+	if s.unset == nil {
+		s.unset = &goenvsubst.UnsetError{Paths: map[string][]string{}}
+	}
+	for _, name := range unset.Names {
+		if !slices.Contains(s.unset.Names, name) {
+			s.unset.Names = append(s.unset.Names, name)
+		}
+		if path != "" {
+			s.unset.Paths[name] = append(s.unset.Paths[name], path)
+		}
+	}
+	return true
+}
+
+// node substitutes the string scalars of n and the nodes below it; path
+// names n in errors
+func (s *substitution) node(n *yaml.Node, path string) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, child := range n.Content {
+			if err := s.node(child, path); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range n.Content {
+			if err := s.node(child, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		// Content alternates keys and values; keys are never modified
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Tag == mergeTag && n.Content[i].Style&yaml.TaggedStyle == 0 {
+				// The encoder would write the implicit tag of << out
+				n.Content[i].Tag = ""
+			}
+			key := n.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			if err := s.node(n.Content[i+1], key); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		return s.scalar(n, path)
+	}
+	// Aliases are substituted at their anchors
+	return nil
+}
+
+// scalar substitutes n if it is a string
+func (s *substitution) scalar(n *yaml.Node, path string) error {
+	if n.ShortTag() != strTag {
+		return nil
+	}
+	expanded, err := s.expander.Expand(n.Value)
+	if s.addUnset(err, path) {
+		return nil
+	}
+	if err != nil {
+		if path == "" {
+			path = "(root)"
+		}
+		return fmt.Errorf("yamlenvsubst: %s: %w", path, err)
+	}
+	if expanded == n.Value {
+		return nil
+	}
+	n.Value = expanded
+	if n.Style == 0 && n.Tag == strTag {
+		// Let the encoder type the plain scalar again; it quotes the value
+		// if it cannot be written plain
+		n.Tag = ""
+	}
+	return nil
+}
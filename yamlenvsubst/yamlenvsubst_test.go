@@ -0,0 +1,96 @@
+package yamlenvsubst_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/iamolegga/goenvsubst"
+	"github.com/iamolegga/goenvsubst/yamlenvsubst"
+)
+
+func setenv(t *testing.T) {
+	t.Helper()
+	os.Setenv("TEST_VAR", "test_value")
+	os.Setenv("TRICKY_VAR", "a: b # not a comment")
+	os.Setenv("PORT_VAR", "8080")
+	t.Cleanup(func() {
+		os.Unsetenv("TEST_VAR")
+		os.Unsetenv("TRICKY_VAR")
+		os.Unsetenv("PORT_VAR")
+	})
+}
+
+func TestDo(t *testing.T) {
+	setenv(t)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "values are expanded, keys are not",
+			input:    "$TEST_VAR: $TEST_VAR\n",
+			expected: "$TEST_VAR: test_value\n",
+		},
+		{
+			name:     "values that look like YAML are quoted",
+			input:    "message: $TRICKY_VAR\nlist: [$TRICKY_VAR]\n",
+			expected: "message: 'a: b # not a comment'\nlist: ['a: b # not a comment']\n",
+		},
+		{
+			name:     "plain scalars are typed again",
+			input:    "port: ${PORT_VAR}\nquoted: \"${PORT_VAR}\"\n",
+			expected: "port: 8080\nquoted: \"8080\"\n",
+		},
+		{
+			name:     "other scalars are copied",
+			input:    "count: 42\nenabled: true\nnothing: null\n",
+			expected: "count: 42\nenabled: true\nnothing: null\n",
+		},
+		{
+			name:     "comments, anchors and aliases are kept",
+			input:    "# database\nbase: &base\n  host: $TEST_VAR # primary\nreplica:\n  <<: *base\n  hosts:\n    - *base\n",
+			expected: "# database\nbase: &base\n  host: test_value # primary\nreplica:\n  <<: *base\n  hosts:\n    - *base\n",
+		},
+		{
+			name:     "multiple documents",
+			input:    "a: $TEST_VAR\n---\nb: $TEST_VAR\n",
+			expected: "a: test_value\n---\nb: test_value\n",
+		},
+		{
+			name:     "empty input",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := yamlenvsubst.Do([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("Do() = %q, want %q", out, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	if _, err := yamlenvsubst.Do([]byte("a: [unclosed\n")); err == nil {
+		t.Error("Do() expected error for invalid YAML")
+	}
+
+	// The unset variables of every scalar are reported together
+	_, err := yamlenvsubst.Do([]byte("db:\n  hosts:\n    - ok\n    - $MISSING_VAR\n  user: $MISSING_USER\n---\n$MISSING_VAR\n"), goenvsubst.Strict())
+	var unsetErr *goenvsubst.UnsetError
+	if !errors.As(err, &unsetErr) {
+		t.Fatalf("Do() error = %v, want *UnsetError", err)
+	}
+	if want := "yamlenvsubst: goenvsubst: unset variables: MISSING_VAR (db.hosts[1]), MISSING_USER (db.user)"; err.Error() != want {
+		t.Errorf("Do() error = %q, want %q", err, want)
+	}
+}